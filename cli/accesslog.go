@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// accessLineRe parses Traefik's CLF access line (stacks/traefik keeps only
+// User-Agent and Referer, in the combined-log positions):
+//
+//	ip - user [time] "GET /path HTTP/2.0" 403 12 "referer" "ua" 7 "router@kubernetes" "http://10.x:80" 3ms
+//
+// UA and Referer are client-controlled: they are counted and shown, never
+// interpreted.
+var accessLineRe = regexp.MustCompile(`^(\S+) \S+ \S+ \[[^\]]*\] "(\S+) (\S+)[^"]*" (\d{3}) \S+ "(?:[^"\\]|\\.)*" "((?:[^"\\]|\\.)*)" \S+ "([^"]*)"`)
+
+// accessLine is the part of a Traefik access line `crowdsec explain`
+// summarises.
+type accessLine struct {
+	IP, Method, Path, Status, UA, Router string
+}
+
+func parseAccessLine(line string) (accessLine, bool) {
+	m := accessLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return accessLine{}, false
+	}
+	path := m[3]
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i] // query strings make every probe unique
+	}
+	return accessLine{IP: m[1], Method: m[2], Path: path, Status: m[4], UA: m[5], Router: strings.TrimSuffix(m[6], "@kubernetes")}, true
+}
+
+// accessLogQuery selects the Traefik access lines from ip. The regexp is
+// anchored on the client field, so 1.2.3.4 doesn't also match 11.2.3.4.
+func accessLogQuery(ip string) string {
+	return "{namespace=\"traefik\"} |~ `^" + regexp.QuoteMeta(ip) + " `"
+}
+
+// countEntry is one value and how many lines had it.
+type countEntry struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// accessSummary is what an address did, by router (≈ host), path, status
+// and user agent, each most frequent first.
+type accessSummary struct {
+	Lines    int          `json:"lines"`
+	Unparsed int          `json:"unparsed"`
+	Routers  []countEntry `json:"routers"`
+	Paths    []countEntry `json:"paths"`
+	Statuses []countEntry `json:"statuses"`
+	Agents   []countEntry `json:"user_agents"`
+}
+
+func topCounts(m map[string]int) []countEntry {
+	out := make([]countEntry, 0, len(m))
+	for v, n := range m {
+		out = append(out, countEntry{v, n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
+
+func summariseAccess(lines []string) accessSummary {
+	routers, paths, statuses, agents := map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}
+	s := accessSummary{Lines: len(lines)}
+	for _, l := range lines {
+		a, ok := parseAccessLine(l)
+		if !ok {
+			s.Unparsed++
+			continue
+		}
+		routers[a.Router]++
+		paths[a.Method+" "+a.Path]++
+		statuses[a.Status]++
+		agents[a.UA]++
+	}
+	s.Routers, s.Paths, s.Statuses, s.Agents = topCounts(routers), topCounts(paths), topCounts(statuses), topCounts(agents)
+	return s
+}
+
+// formatAccessSummary renders the top entries of each breakdown.
+func formatAccessSummary(s accessSummary, top int) string {
+	if s.Lines == 0 {
+		return "no Traefik access lines from this address in the window\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d Traefik access lines", s.Lines)
+	if s.Unparsed > 0 {
+		fmt.Fprintf(&b, " (%d not in the access-log format)", s.Unparsed)
+	}
+	b.WriteString("\n")
+	section := func(title string, list []countEntry) {
+		fmt.Fprintf(&b, "  %s:\n", title)
+		for i, c := range list {
+			if i == top {
+				fmt.Fprintf(&b, "    … %d more\n", len(list)-top)
+				break
+			}
+			v := c.Value
+			if r := []rune(v); len(r) > 100 {
+				v = string(r[:99]) + "…"
+			}
+			fmt.Fprintf(&b, "    %6d  %s\n", c.Count, v)
+		}
+	}
+	section("routers (host)", s.Routers)
+	section("status codes", s.Statuses)
+	section("paths", s.Paths)
+	section("user agents", s.Agents)
+	return b.String()
+}
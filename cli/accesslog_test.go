@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const accessFixture = `1.2.3.4 - - [19/Oct/2026:10:00:00 +0000] "GET /wp-login.php?redirect=1 HTTP/1.1" 404 19 "-" "Mozilla/5.0 (compatible; scan)" 812 "websecure-immich-immich-viktorbarzin-me@kubernetes" "http://10.10.1.2:2283" 3ms`
+
+func TestParseAccessLine(t *testing.T) {
+	a, ok := parseAccessLine(accessFixture)
+	if !ok {
+		t.Fatal("fixture did not parse")
+	}
+	want := accessLine{IP: "1.2.3.4", Method: "GET", Path: "/wp-login.php", Status: "404",
+		UA: "Mozilla/5.0 (compatible; scan)", Router: "websecure-immich-immich-viktorbarzin-me"}
+	if a != want {
+		t.Fatalf("got %+v\nwant %+v", a, want)
+	}
+	// Traefik escapes a quote inside the UA; the parser must span it rather
+	// than misalign the router field.
+	esc := strings.Replace(accessFixture, `(compatible; scan)`, `\"x\"`, 1)
+	if a, ok := parseAccessLine(esc); !ok || a.UA != `Mozilla/5.0 \"x\"` || a.Router != want.Router {
+		t.Fatalf("escaped quote: %+v, %v", a, ok)
+	}
+	if _, ok := parseAccessLine("time=... level=info msg=\"plugin loaded\""); ok {
+		t.Fatal("a non-access line should not parse")
+	}
+}
+
+func TestAccessLogQueryAnchorsTheIP(t *testing.T) {
+	if got := accessLogQuery("1.2.3.4"); got != "{namespace=\"traefik\"} |~ `^1\\.2\\.3\\.4 `" {
+		t.Fatalf("query = %s", got)
+	}
+}
+
+func TestSummariseAccess(t *testing.T) {
+	ok200 := strings.NewReplacer(`/wp-login.php?redirect=1`, `/api/ping`, `" 404 `, `" 200 `).Replace(accessFixture)
+	s := summariseAccess([]string{accessFixture, accessFixture, ok200, "garbage"})
+	if s.Lines != 4 || s.Unparsed != 1 {
+		t.Fatalf("lines=%d unparsed=%d", s.Lines, s.Unparsed)
+	}
+	if s.Paths[0] != (countEntry{"GET /wp-login.php", 2}) || s.Statuses[0] != (countEntry{"404", 2}) {
+		t.Fatalf("most frequent first: paths=%v statuses=%v", s.Paths, s.Statuses)
+	}
+	out := formatAccessSummary(s, 1)
+	for _, want := range []string{"4 Traefik access lines (1 not in the access-log format)", "routers (host):", "… 1 more", "Mozilla/5.0 (compatible; scan)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+	if got := formatAccessSummary(accessSummary{}, 10); !strings.HasPrefix(got, "no Traefik access lines") {
+		t.Fatalf("empty summary = %q", got)
+	}
+}
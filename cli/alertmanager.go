@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// amMatcher is an Alertmanager v2 API label matcher.
+type amMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+func (m amMatcher) String() string {
+	op := "="
+	switch {
+	case m.IsRegex && m.IsEqual:
+		op = "=~"
+	case m.IsRegex:
+		op = "!~"
+	case !m.IsEqual:
+		op = "!="
+	}
+	return m.Name + op + `"` + m.Value + `"`
+}
+
+var matcherRe = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*(.*?)\s*$`)
+
+// parseMatchers reads PromQL-style matchers — `alertname=Foo`,
+// `namespace=~"a|b"`, `severity!=info` — from one or more args, each of which
+// may itself be a `{a="x",b="y"}` selector or comma-separated list.
+func parseMatchers(args []string) ([]amMatcher, error) {
+	var out []amMatcher
+	for _, a := range args {
+		a = strings.TrimSpace(a)
+		a = strings.TrimSuffix(strings.TrimPrefix(a, "{"), "}")
+		for _, part := range splitMatchers(a) {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			m := matcherRe.FindStringSubmatch(part)
+			if m == nil {
+				return nil, fmt.Errorf("bad matcher %q: want label=value, label!=value, label=~regex or label!~regex", part)
+			}
+			val := m[3]
+			if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+				val = val[1 : len(val)-1]
+			}
+			out = append(out, amMatcher{Name: m[1], Value: val, IsRegex: strings.HasSuffix(m[2], "~"), IsEqual: m[2][0] == '='})
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no matchers given")
+	}
+	// A silence whose every matcher also matches the empty string would mute
+	// every alert; Alertmanager rejects it too, but say why up front.
+	for _, m := range out {
+		if !m.IsRegex {
+			if m.IsEqual && m.Value != "" {
+				return out, nil
+			}
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("bad regex in %s: %w", m, err)
+		}
+		if m.IsEqual && !re.MatchString("") {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("matchers %s would silence every alert; add one that requires a value (e.g. alertname=…)", joinMatchers(out))
+}
+
+// splitMatchers splits on commas outside double quotes, so a regex value like
+// "a|b,c" survives.
+func splitMatchers(s string) []string {
+	var parts []string
+	var cur strings.Builder
+	inQuote := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			cur.WriteRune(r)
+		case r == ',' && !inQuote:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	return append(parts, cur.String())
+}
+
+func joinMatchers(ms []amMatcher) string {
+	s := make([]string, len(ms))
+	for i, m := range ms {
+		s[i] = m.String()
+	}
+	return "{" + strings.Join(s, ",") + "}"
+}
+
+// amSilence is a silence as the v2 API returns it (ID/Status absent on create).
+type amSilence struct {
+	ID        string      `json:"id,omitempty"`
+	Matchers  []amMatcher `json:"matchers"`
+	StartsAt  time.Time   `json:"startsAt"`
+	EndsAt    time.Time   `json:"endsAt"`
+	CreatedBy string      `json:"createdBy"`
+	Comment   string      `json:"comment"`
+	Status    *struct {
+		State string `json:"state"` // active | pending | expired
+	} `json:"status,omitempty"`
+}
+
+func (s amSilence) state() string {
+	if s.Status == nil {
+		return "unknown"
+	}
+	return s.Status.State
+}
+
+// claimTagRe finds the presence label a silence was tied to. The tag lives in
+// the comment because that is the one free-text field every Alertmanager
+// client shows, so whoever sees the silence in the UI also sees the claim.
+var claimTagRe = regexp.MustCompile(`\[claim ([a-z]+:[^\]\s]+)\]`)
+
+// silenceComment appends the presence tag to the user's comment.
+func silenceComment(comment, claim string) string {
+	if claim == "" {
+		return comment
+	}
+	return comment + " [claim " + claim + "]"
+}
+
+// silenceClaim returns the presence label tagged in a silence comment, or "".
+func silenceClaim(comment string) string {
+	if m := claimTagRe.FindStringSubmatch(comment); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// resolveSilenceID expands a unique ID prefix (IDs are UUIDs; the listing
+// shows the first 8 characters) among silences that are not yet expired.
+func resolveSilenceID(prefix string, silences []amSilence) (amSilence, error) {
+	var hits []amSilence
+	for _, s := range silences {
+		if s.state() == "expired" {
+			continue
+		}
+		if s.ID == prefix {
+			return s, nil
+		}
+		if strings.HasPrefix(s.ID, prefix) {
+			hits = append(hits, s)
+		}
+	}
+	switch len(hits) {
+	case 0:
+		return amSilence{}, fmt.Errorf("no active or pending silence with ID %q (see `homelab alerts silences`)", prefix)
+	case 1:
+		return hits[0], nil
+	}
+	return amSilence{}, fmt.Errorf("silence ID prefix %q is ambiguous (%d matches); give more characters", prefix, len(hits))
+}
+
+// formatSilences lists silences soonest-ending first, expired ones last.
+func formatSilences(silences []amSilence, now time.Time) string {
+	if len(silences) == 0 {
+		return "(no silences)\n"
+	}
+	sorted := append([]amSilence(nil), silences...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ei, ej := sorted[i].state() == "expired", sorted[j].state() == "expired"
+		if ei != ej {
+			return ej
+		}
+		return sorted[i].EndsAt.Before(sorted[j].EndsAt)
+	})
+	var b strings.Builder
+	for _, s := range sorted {
+		id := s.ID
+		if len(id) > 8 {
+			id = id[:8]
+		}
+		when := "ends in " + humanDuration(s.EndsAt.Sub(now).Round(time.Minute))
+		switch s.state() {
+		case "pending":
+			when = "starts in " + humanDuration(s.StartsAt.Sub(now).Round(time.Minute))
+		case "expired":
+			when = "ended " + humanDuration(now.Sub(s.EndsAt).Round(time.Minute)) + " ago"
+		}
+		fmt.Fprintf(&b, "%-8s  %-7s  %-18s  %-10s  %s  — %s\n", id, s.state(), when, s.CreatedBy, joinMatchers(s.Matchers), s.Comment)
+	}
+	return b.String()
+}
+
+// amAlert is the subset of a /api/v2/alerts entry the inhibited view reads.
+type amAlert struct {
+	Fingerprint string            `json:"fingerprint"`
+	Labels      map[string]string `json:"labels"`
+	StartsAt    time.Time         `json:"startsAt"`
+	Status      struct {
+		State       string   `json:"state"`
+		InhibitedBy []string `json:"inhibitedBy"`
+		SilencedBy  []string `json:"silencedBy"`
+	} `json:"status"`
+}
+
+// formatInhibited lists the alerts an inhibition rule is suppressing, each
+// with the alert(s) doing the inhibiting — resolved from the same listing by
+// fingerprint, so the reader sees "NodeDown is hiding these".
+func formatInhibited(alerts []amAlert) string {
+	byFP := map[string]amAlert{}
+	for _, a := range alerts {
+		byFP[a.Fingerprint] = a
+	}
+	var lines []string
+	for _, a := range alerts {
+		if len(a.Status.InhibitedBy) == 0 {
+			continue
+		}
+		var by []string
+		for _, fp := range a.Status.InhibitedBy {
+			if src, ok := byFP[fp]; ok {
+				id := map[string]string{"__name__": src.Labels["alertname"]}
+				for _, k := range []string{"namespace", "instance", "node"} {
+					if v := src.Labels[k]; v != "" {
+						id[k] = v
+					}
+				}
+				by = append(by, labelStr(id))
+			} else {
+				by = append(by, fp)
+			}
+		}
+		labels := map[string]string{}
+		for k, v := range a.Labels {
+			if k != "alertname" {
+				labels[k] = v
+			}
+		}
+		labels["__name__"] = a.Labels["alertname"]
+		lines = append(lines, fmt.Sprintf("%s\n    inhibited by %s", labelStr(labels), strings.Join(by, ", ")))
+	}
+	if len(lines) == 0 {
+		return "(no inhibited alerts)\n"
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// decodeAM unmarshals an Alertmanager API body into v.
+func decodeAM(body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("cannot parse Alertmanager response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMatchers(t *testing.T) {
+	got, err := parseMatchers([]string{`alertname=NodeDown`, `{namespace=~"immich|tripit", severity!='info'}`, `job!~"a,b"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []amMatcher{
+		{Name: "alertname", Value: "NodeDown", IsEqual: true},
+		{Name: "namespace", Value: "immich|tripit", IsRegex: true, IsEqual: true},
+		{Name: "severity", Value: "info"},
+		{Name: "job", Value: "a,b", IsRegex: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMatchers = %+v\nwant %+v", got, want)
+	}
+	if s := joinMatchers(got); s != `{alertname="NodeDown",namespace=~"immich|tripit",severity!="info",job!~"a,b"}` {
+		t.Errorf("joinMatchers = %s", s)
+	}
+	for _, bad := range [][]string{
+		nil,
+		{"NodeDown"},        // no operator
+		{"severity!=info"},  // only negative: mutes everything else
+		{`alertname=~".*"`}, // matches the empty string
+		{`alertname=~"("`},  // invalid regex
+	} {
+		if _, err := parseMatchers(bad); err == nil {
+			t.Errorf("parseMatchers(%q) accepted", bad)
+		}
+	}
+}
+
+func TestSilenceClaimTag(t *testing.T) {
+	c := silenceComment("kernel upgrade on node3", "node:k8s-node3")
+	if c != "kernel upgrade on node3 [claim node:k8s-node3]" {
+		t.Errorf("silenceComment = %q", c)
+	}
+	if got := silenceClaim(c); got != "node:k8s-node3" {
+		t.Errorf("silenceClaim = %q", got)
+	}
+	if silenceComment("x", "") != "x" || silenceClaim("no tag here") != "" {
+		t.Error("untagged comment mishandled")
+	}
+}
+
+func silencesFixture(t *testing.T) []amSilence {
+	t.Helper()
+	var ss []amSilence
+	body := `[
+	 {"id":"8f1c2d3e-aaaa-4bbb-8ccc-000000000001","status":{"state":"active"},"matchers":[{"name":"alertname","value":"NodeDown","isRegex":false,"isEqual":true}],
+	  "startsAt":"2026-10-19T10:00:00Z","endsAt":"2026-10-19T13:30:00Z","createdBy":"viktor","comment":"reboot [claim node:k8s-node3]"},
+	 {"id":"8f1c9999-aaaa-4bbb-8ccc-000000000002","status":{"state":"active"},"matchers":[{"name":"namespace","value":"immich","isRegex":false,"isEqual":true}],
+	  "startsAt":"2026-10-19T10:00:00Z","endsAt":"2026-10-19T12:15:00Z","createdBy":"emo","comment":"db migration"},
+	 {"id":"0abc0000-aaaa-4bbb-8ccc-000000000003","status":{"state":"expired"},"matchers":[{"name":"job","value":"x","isRegex":false,"isEqual":true}],
+	  "startsAt":"2026-10-18T10:00:00Z","endsAt":"2026-10-19T11:00:00Z","createdBy":"viktor","comment":"old"}
+	]`
+	if err := decodeAM([]byte(body), &ss); err != nil {
+		t.Fatal(err)
+	}
+	return ss
+}
+
+func TestResolveSilenceID(t *testing.T) {
+	ss := silencesFixture(t)
+	if s, err := resolveSilenceID("8f1c2", ss); err != nil || s.CreatedBy != "viktor" {
+		t.Errorf("unique prefix = %+v, %v", s, err)
+	}
+	if _, err := resolveSilenceID("8f1c", ss); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("ambiguous prefix err = %v", err)
+	}
+	if _, err := resolveSilenceID("0abc", ss); err == nil {
+		t.Error("expired silence resolved")
+	}
+}
+
+func TestFormatSilences(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	out := formatSilences(silencesFixture(t), now)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines:\n%s", out)
+	}
+	for i, want := range []string{
+		`8f1c9999  active   ends in 15m         emo         {namespace="immich"}  — db migration`,
+		`8f1c2d3e  active   ends in 1h30m       viktor      {alertname="NodeDown"}  — reboot [claim node:k8s-node3]`,
+		`0abc0000  expired  ended 1h ago`,
+	} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("line %d = %q, want prefix %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestFormatInhibited(t *testing.T) {
+	var alerts []amAlert
+	body := `[
+	 {"fingerprint":"aaa","labels":{"alertname":"NodeDown","node":"k8s-node3"},"status":{"state":"active","inhibitedBy":[],"silencedBy":[]}},
+	 {"fingerprint":"bbb","labels":{"alertname":"PodNotReady","namespace":"immich","pod":"immich-server-0"},"status":{"state":"suppressed","inhibitedBy":["aaa"],"silencedBy":[]}},
+	 {"fingerprint":"ccc","labels":{"alertname":"TargetDown","job":"x"},"status":{"state":"suppressed","inhibitedBy":["zzz"],"silencedBy":[]}}
+	]`
+	if err := json.Unmarshal([]byte(body), &alerts); err != nil {
+		t.Fatal(err)
+	}
+	out := formatInhibited(alerts)
+	for _, want := range []string{
+		"PodNotReady{namespace=immich,pod=immich-server-0}\n    inhibited by NodeDown{node=k8s-node3}",
+		"TargetDown{job=x}\n    inhibited by zzz",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "NodeDown{node=k8s-node3}\n") && strings.HasPrefix(out, "NodeDown") {
+		t.Errorf("the inhibiting alert itself was listed:\n%s", out)
+	}
+	if got := formatInhibited(alerts[:1]); got != "(no inhibited alerts)\n" {
+		t.Errorf("none = %q", got)
+	}
+}
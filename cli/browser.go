@@ -187,8 +187,11 @@ func cdpHealthy(jsonBody []byte) (browser string, healthy bool, err error) {
 // tunnels API-server→pod, so it bypasses the NetworkPolicy that gates in-cluster
 // callers and works for a named pod under the existing pods/portforward grant.
 func buildPortForwardArgs(target string, localPort, remotePort int) []string {
-	return []string{"-n", chromeServiceNamespace, "port-forward",
-		target, fmt.Sprintf("%d:%d", localPort, remotePort)}
+	return portForwardArgsIn(chromeServiceNamespace, target, localPort, remotePort)
+}
+
+func portForwardArgsIn(ns, target string, localPort, remotePort int) []string {
+	return []string{"-n", ns, "port-forward", target, fmt.Sprintf("%d:%d", localPort, remotePort)}
 }
 
 // browserClientPackageJSON is the auto-managed manifest for the pinned node CDP
@@ -323,11 +326,16 @@ func waitForCDP(cdpURL string, timeout time.Duration) (string, error) {
 // local port, in its own process group so the whole tree dies on teardown. It
 // does NOT wait for readiness — the caller polls the specific endpoint.
 func startForward(target string, remotePort int) (localPort int, teardown func(), logbuf *strings.Builder, err error) {
+	return startForwardIn(chromeServiceNamespace, target, remotePort)
+}
+
+// startForwardIn is startForward for a target in any namespace.
+func startForwardIn(ns, target string, remotePort int) (localPort int, teardown func(), logbuf *strings.Builder, err error) {
 	localPort, err = freePort()
 	if err != nil {
 		return 0, nil, nil, fmt.Errorf("pick local port: %w", err)
 	}
-	pf := exec.Command("kubectl", buildPortForwardArgs(target, localPort, remotePort)...)
+	pf := exec.Command("kubectl", portForwardArgsIn(ns, target, localPort, remotePort)...)
 	pf.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	logbuf = &strings.Builder{}
 	pf.Stdout = logbuf
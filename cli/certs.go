@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The HTTP probes dial with InsecureSkipVerify (see clientDialingIP), so they
+// say nothing about the certificate. The cert leg fetches the served chain the
+// same way — dial a chosen IP, keep the host as SNI — and then verifies it
+// explicitly, so a bad chain is *reported* rather than turned into a dial error
+// that hides the expiry date.
+
+// certReport describes the certificate one leg served for one host.
+type certReport struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	SANs        []string  `json:"sans"`
+	NotAfter    time.Time `json:"not_after"`
+	SANMatch    bool      `json:"san_match"`
+	ChainError  string    `json:"chain_error,omitempty"` // "" when the chain verifies against the system roots
+	Fingerprint string    `json:"sha256"`
+}
+
+// fetchCertChain completes a TLS handshake with ip:443 using host as SNI and
+// returns the presented chain, leaf first.
+func fetchCertChain(ip, host string, timeout time.Duration) ([]*x509.Certificate, error) {
+	d := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(d, "tcp", net.JoinHostPort(ip, "443"),
+		&tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	chain := conn.ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%s presented no certificate", ip)
+	}
+	return chain, nil
+}
+
+// inspectCert verifies chain (leaf first) at now against roots (nil = system
+// pool) and checks the leaf's SANs against host. Chain validity and SAN match
+// are reported separately: a wildcard served for the wrong zone has a valid
+// chain but the wrong names, and that is a different fix.
+func inspectCert(chain []*x509.Certificate, host string, now time.Time, roots *x509.CertPool) certReport {
+	leaf := chain[0]
+	inter := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		inter.AddCert(c)
+	}
+	sum := sha256.Sum256(leaf.Raw)
+	r := certReport{
+		Subject:     leaf.Subject.CommonName,
+		Issuer:      certIssuer(leaf),
+		SANs:        leaf.DNSNames,
+		NotAfter:    leaf.NotAfter,
+		SANMatch:    leaf.VerifyHostname(host) == nil,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+	if r.Subject == "" && len(r.SANs) > 0 {
+		r.Subject = r.SANs[0]
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: inter, CurrentTime: now}); err != nil {
+		r.ChainError = err.Error()
+	}
+	return r
+}
+
+// certIssuer renders the issuer as "CN (O)", e.g. "R11 (Let's Encrypt)".
+func certIssuer(c *x509.Certificate) string {
+	cn := c.Issuer.CommonName
+	if len(c.Issuer.Organization) > 0 && c.Issuer.Organization[0] != cn {
+		if cn == "" {
+			return c.Issuer.Organization[0]
+		}
+		return cn + " (" + c.Issuer.Organization[0] + ")"
+	}
+	return cn
+}
+
+// daysLeft is whole days until notAfter; negative once it has expired.
+func daysLeft(notAfter, now time.Time) int {
+	return int(math.Floor(notAfter.Sub(now).Hours() / 24))
+}
+
+// fmtCert is the one-line cert verdict under a `net check` leg.
+func fmtCert(r certReport, now time.Time) string {
+	var parts []string
+	if r.ChainError == "" {
+		parts = append(parts, "chain ok")
+	} else {
+		parts = append(parts, "chain INVALID ("+r.ChainError+")")
+	}
+	if r.SANMatch {
+		parts = append(parts, "SAN ok")
+	} else {
+		parts = append(parts, "SAN MISMATCH (cert for "+strings.Join(r.SANs, ", ")+")")
+	}
+	parts = append(parts, "issuer "+r.Issuer)
+	if d := daysLeft(r.NotAfter, now); d < 0 {
+		parts = append(parts, fmt.Sprintf("EXPIRED %s (%dd ago)", r.NotAfter.Format("2006-01-02"), -d))
+	} else {
+		parts = append(parts, fmt.Sprintf("expires %s (%dd)", r.NotAfter.Format("2006-01-02"), d))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// hostCert is one `net certs` result.
+type hostCert struct {
+	Host string      `json:"host"`
+	Cert *certReport `json:"cert,omitempty"`
+	Err  string      `json:"error,omitempty"`
+}
+
+// certGroup is one distinct certificate and every host serving it — a
+// wildcard expiring shows up once, not once per app.
+type certGroup struct {
+	report certReport
+	hosts  []string
+}
+
+// formatCertSweep renders `net certs`: certificates expiring within days
+// (grouped by fingerprint, soonest first), then hosts whose chain or SAN is
+// wrong or whose handshake failed, then a footer. expiring is the number of
+// distinct certificates inside the window, broken the number of hosts with a
+// chain, SAN or handshake problem.
+func formatCertSweep(results []hostCert, days int, now time.Time) (out string, expiring, broken int) {
+	groups := map[string]*certGroup{}
+	var problems []string
+	distinct := 0
+	for _, hc := range results {
+		if hc.Cert == nil {
+			problems = append(problems, fmt.Sprintf("  %s — handshake failed: %s", hc.Host, hc.Err))
+			continue
+		}
+		g, ok := groups[hc.Cert.Fingerprint]
+		if !ok {
+			g = &certGroup{report: *hc.Cert}
+			groups[hc.Cert.Fingerprint] = g
+			distinct++
+		}
+		g.hosts = append(g.hosts, hc.Host)
+		switch {
+		case hc.Cert.ChainError != "":
+			problems = append(problems, fmt.Sprintf("  %s — chain invalid: %s", hc.Host, hc.Cert.ChainError))
+		case !hc.Cert.SANMatch:
+			problems = append(problems, fmt.Sprintf("  %s — SAN mismatch (cert for %s)", hc.Host, strings.Join(hc.Cert.SANs, ", ")))
+		}
+	}
+	var soon []*certGroup
+	for _, g := range groups {
+		if daysLeft(g.report.NotAfter, now) <= days {
+			soon = append(soon, g)
+		}
+	}
+	sort.Slice(soon, func(i, j int) bool {
+		if !soon[i].report.NotAfter.Equal(soon[j].report.NotAfter) {
+			return soon[i].report.NotAfter.Before(soon[j].report.NotAfter)
+		}
+		return soon[i].report.Subject < soon[j].report.Subject
+	})
+	var b strings.Builder
+	if len(soon) == 0 {
+		fmt.Fprintf(&b, "no certificate expires within %dd\n", days)
+	} else {
+		fmt.Fprintf(&b, "expiring within %dd:\n", days)
+		for _, g := range soon {
+			when := fmt.Sprintf("%dd", daysLeft(g.report.NotAfter, now))
+			if d := daysLeft(g.report.NotAfter, now); d < 0 {
+				when = fmt.Sprintf("EXPIRED %dd ago", -d)
+			}
+			sort.Strings(g.hosts)
+			fmt.Fprintf(&b, "  %-28s %-10s %s  %s — %d host(s): %s\n", g.report.Subject, when,
+				g.report.NotAfter.Format("2006-01-02"), g.report.Issuer, len(g.hosts), strings.Join(g.hosts, ", "))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		b.WriteString("problems:\n")
+		b.WriteString(strings.Join(problems, "\n"))
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d hosts, %d distinct certificates, %d expiring within %dd\n", len(results), distinct, len(soon), days)
+	return b.String(), len(soon), len(problems)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testChain issues a CA and a leaf for names, valid until notAfter.
+func testChain(t *testing.T, names []string, notAfter time.Time) ([]*x509.Certificate, *x509.CertPool) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "R11", Organization: []string{"Let's Encrypt"}},
+		NotBefore:             time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(leafDER)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return []*x509.Certificate{leaf, ca}, roots
+}
+
+func TestInspectCert(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	chain, roots := testChain(t, []string{"*.viktorbarzin.me", "viktorbarzin.me"}, now.Add(43*24*time.Hour))
+
+	r := inspectCert(chain, "tripit.viktorbarzin.me", now, roots)
+	if r.ChainError != "" || !r.SANMatch {
+		t.Fatalf("valid wildcard judged bad: %+v", r)
+	}
+	if r.Issuer != "R11 (Let's Encrypt)" || r.Subject != "*.viktorbarzin.me" {
+		t.Errorf("issuer/subject = %q / %q", r.Issuer, r.Subject)
+	}
+	if got := fmtCert(r, now); got != "chain ok, SAN ok, issuer R11 (Let's Encrypt), expires 2026-12-01 (43d)" {
+		t.Errorf("fmtCert = %q", got)
+	}
+
+	// wrong zone: chain fine, names wrong
+	if r := inspectCert(chain, "ha.viktorbarzin.lan", now, roots); r.SANMatch || r.ChainError != "" {
+		t.Errorf("SAN mismatch not isolated: %+v", r)
+	}
+	// unknown root (system pool does not know the test CA)
+	if r := inspectCert(chain, "tripit.viktorbarzin.me", now, x509.NewCertPool()); r.ChainError == "" {
+		t.Error("chain against an empty root pool verified")
+	}
+	// expired
+	late := now.Add(50 * 24 * time.Hour)
+	r = inspectCert(chain, "tripit.viktorbarzin.me", late, roots)
+	if r.ChainError == "" {
+		t.Error("expired chain verified")
+	}
+	if got := fmtCert(r, late); !strings.Contains(got, "EXPIRED 2026-12-01 (7d ago)") {
+		t.Errorf("expired fmtCert = %q", got)
+	}
+}
+
+func TestDaysLeft(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		after time.Duration
+		want  int
+	}{
+		{36 * time.Hour, 1}, {23 * time.Hour, 0}, {-time.Hour, -1}, {-49 * time.Hour, -3},
+	} {
+		if got := daysLeft(now.Add(c.after), now); got != c.want {
+			t.Errorf("daysLeft(+%s) = %d, want %d", c.after, got, c.want)
+		}
+	}
+}
+
+func TestFormatCertSweep(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	wild := &certReport{Subject: "*.viktorbarzin.me", Issuer: "R11 (Let's Encrypt)", SANMatch: true,
+		NotAfter: now.Add(6 * 24 * time.Hour), Fingerprint: "aa"}
+	fresh := &certReport{Subject: "ha.viktorbarzin.lan", Issuer: "homelab CA", SANMatch: true,
+		NotAfter: now.Add(80 * 24 * time.Hour), Fingerprint: "bb", ChainError: "x509: certificate signed by unknown authority"}
+	results := []hostCert{
+		{Host: "tripit.viktorbarzin.me", Cert: wild},
+		{Host: "immich.viktorbarzin.me", Cert: wild},
+		{Host: "ha.viktorbarzin.lan", Cert: fresh},
+		{Host: "gone.viktorbarzin.me", Err: "connection refused"},
+	}
+	out, expiring, broken := formatCertSweep(results, 30, now)
+	if expiring != 1 {
+		t.Errorf("expiring = %d, want 1 (the wildcard, once)", expiring)
+	}
+	if broken != 2 {
+		t.Errorf("broken = %d, want 2 (bad chain, failed handshake)", broken)
+	}
+	for _, want := range []string{
+		"2 host(s): immich.viktorbarzin.me, tripit.viktorbarzin.me",
+		"ha.viktorbarzin.lan — chain invalid",
+		"gone.viktorbarzin.me — handshake failed: connection refused",
+		"4 hosts, 2 distinct certificates, 1 expiring within 30d",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("sweep missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(strings.SplitN(out, "problems:", 2)[0], "ha.viktorbarzin.lan") {
+		t.Errorf("80-day cert listed as expiring:\n%s", out)
+	}
+	if out, n, _ := formatCertSweep(results[2:3], 30, now); n != 0 || !strings.HasPrefix(out, "no certificate expires within 30d") {
+		t.Errorf("quiet sweep = %d, %q", n, out)
+	}
+	if _, _, broken := formatCertSweep(results[:2], 30, now); broken != 0 {
+		t.Errorf("healthy hosts counted as broken: %d", broken)
+	}
+}
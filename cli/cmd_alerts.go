@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+func alertsCommands() []Command {
+	return []Command{
+		{Path: []string{"alerts", "silence"}, Tier: TierWrite,
+			Summary: `silence alerts in Alertmanager: alerts silence <matcher>... --for 2h --comment "why" [--claim <kind>:<name>]`, Run: alertsSilence},
+		{Path: []string{"alerts", "silences"}, Tier: TierRead,
+			Summary: "list active/pending Alertmanager silences (--all adds expired): alerts silences [--all] [--json]", Run: alertsSilences},
+		{Path: []string{"alerts", "unsilence"}, Tier: TierWrite,
+			Summary: "expire a silence (and release its presence claim): alerts unsilence <id|prefix>", Run: alertsUnsilence},
+		{Path: []string{"alerts", "inhibited"}, Tier: TierRead,
+			Summary: "alerts an inhibition rule is currently suppressing, and by what: alerts inhibited [--json]", Run: alertsInhibited},
+	}
+}
+
+// amProxyPath reaches the in-cluster Alertmanager through the API server's
+// service proxy. The alertmanager.viktorbarzin.me ingress sits behind
+// Authentik forward-auth (every API call would 302 to a login page), while
+// the service proxy rides the caller's kubeconfig — the same credentials the
+// k8s verbs use.
+const amProxyPath = "/api/v1/namespaces/monitoring/services/prometheus-alertmanager:9093/proxy"
+
+// alertsDefaultFor is the silence length when --for is not given; long enough
+// for a maintenance window, short enough that a forgotten one lapses the same
+// day.
+const alertsDefaultFor = 2 * time.Hour
+
+// amRequest calls the Alertmanager v2 API via `kubectl get|create|delete --raw`.
+func amRequest(method, path string, body []byte) ([]byte, error) {
+	verb := map[string]string{"GET": "get", "POST": "create", "DELETE": "delete"}[method]
+	args := []string{verb, "--raw", amProxyPath + path}
+	if body != nil {
+		args = append(args, "-f", "-")
+	}
+	cmd := exec.Command("kubectl", args...)
+	if body != nil {
+		cmd.Stdin = bytes.NewReader(body)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("alertmanager %s %s: %v: %s", method, path, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func amSilences() ([]amSilence, error) {
+	body, err := amRequest("GET", "/api/v2/silences", nil)
+	if err != nil {
+		return nil, err
+	}
+	var ss []amSilence
+	return ss, decodeAM(body, &ss)
+}
+
+// alertsSilence creates a silence tagged with the caller as createdBy. With
+// --claim it first takes the presence claim (so others see the maintenance on
+// the board) and records the label in the comment, which is how unsilence
+// knows to release it; if the silence cannot be created, the claim is
+// released again.
+func alertsSilence(args []string) error {
+	const usage = `usage: homelab alerts silence <matcher>... --for 2h --comment "why" [--claim <kind>:<name>]`
+	valueFlags := map[string]bool{"--for": true, "--comment": true, "--claim": true}
+	matchers, err := parseMatchers(positionalsSkipping(args, valueFlags))
+	if err != nil {
+		return fmt.Errorf("%v\n%s", err, usage)
+	}
+	comment := strings.TrimSpace(flagValue(args, "--comment"))
+	if comment == "" {
+		return fmt.Errorf("--comment is required: say what the maintenance is, for whoever sees the silence\n%s", usage)
+	}
+	dur := alertsDefaultFor
+	if v := flagValue(args, "--for"); v != "" {
+		if dur, err = parsePromDuration(v); err != nil {
+			return fmt.Errorf("--for: %w", err)
+		}
+	}
+	claim := flagValue(args, "--claim")
+	if claim != "" {
+		if err := validateLabel(claim); err != nil {
+			return err
+		}
+		if err := presenceClaim(claim, "alerts silenced "+humanDuration(dur)+": "+comment); err != nil {
+			return fmt.Errorf("presence claim failed (run `vault login -method=oidc`?): %w", err)
+		}
+	}
+	now := time.Now().UTC()
+	s := amSilence{
+		Matchers:  matchers,
+		StartsAt:  now,
+		EndsAt:    now.Add(dur),
+		CreatedBy: currentUser(),
+		Comment:   silenceComment(comment, claim),
+	}
+	reqBody, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	body, err := amRequest("POST", "/api/v2/silences", reqBody)
+	if err != nil {
+		if claim != "" {
+			_ = presenceRelease(claim)
+		}
+		return err
+	}
+	var created struct {
+		SilenceID string `json:"silenceID"`
+	}
+	if err := decodeAM(body, &created); err != nil {
+		return err
+	}
+	fmt.Printf("silenced %s for %s (until %s) — id %s\n", joinMatchers(matchers), humanDuration(dur),
+		s.EndsAt.Local().Format("15:04"), created.SilenceID)
+	if claim != "" {
+		fmt.Printf("claimed %s; `homelab alerts unsilence %.8s` releases both\n", claim, created.SilenceID)
+	}
+	return nil
+}
+
+func alertsSilences(args []string) error {
+	ss, err := amSilences()
+	if err != nil {
+		return err
+	}
+	if !containsArg(args, "--all") {
+		var live []amSilence
+		for _, s := range ss {
+			if s.state() != "expired" {
+				live = append(live, s)
+			}
+		}
+		ss = live
+	}
+	if containsArg(args, "--json") {
+		b, err := json.MarshalIndent(ss, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Print(formatSilences(ss, time.Now()))
+	return nil
+}
+
+// alertsUnsilence expires a silence by ID or unique prefix, and releases the
+// presence claim its comment was tagged with.
+func alertsUnsilence(args []string) error {
+	id, _ := firstPositional(args)
+	if id == "" {
+		return fmt.Errorf("usage: homelab alerts unsilence <id|prefix>")
+	}
+	ss, err := amSilences()
+	if err != nil {
+		return err
+	}
+	s, err := resolveSilenceID(id, ss)
+	if err != nil {
+		return err
+	}
+	if _, err := amRequest("DELETE", "/api/v2/silence/"+s.ID, nil); err != nil {
+		return err
+	}
+	fmt.Printf("expired silence %s %s\n", s.ID, joinMatchers(s.Matchers))
+	if claim := silenceClaim(s.Comment); claim != "" {
+		if err := presenceRelease(claim); err != nil {
+			fmt.Fprintf(os.Stderr, "homelab: silence expired, but releasing %s failed: %v (run `homelab release %s`)\n", claim, err, claim)
+			return nil
+		}
+		fmt.Printf("released %s\n", claim)
+	}
+	return nil
+}
+
+func alertsInhibited(args []string) error {
+	body, err := amRequest("GET", "/api/v2/alerts", nil)
+	if err != nil {
+		return err
+	}
+	var alerts []amAlert
+	if err := decodeAM(body, &alerts); err != nil {
+		return err
+	}
+	if containsArg(args, "--json") {
+		var inhibited []amAlert
+		for _, a := range alerts {
+			if len(a.Status.InhibitedBy) > 0 {
+				inhibited = append(inhibited, a)
+			}
+		}
+		b, err := json.MarshalIndent(inhibited, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Print(formatInhibited(alerts))
+	return nil
+}
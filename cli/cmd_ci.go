@@ -1,21 +1,50 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 func ciCommands() []Command {
 	return []Command{
 		{Path: []string{"ci", "status"}, Tier: TierRead,
-			Summary: "pipeline status for HEAD/a commit: ci status [commit]", Run: ciStatus},
+			Summary: "pipeline status for HEAD/a commit: ci status [commit] [--repo owner/name]", Run: ciStatus},
 		{Path: []string{"ci", "watch"}, Tier: TierRead,
-			Summary: "poll the pipeline for HEAD (or a commit) to terminal; non-zero on failure", Run: ciWatch},
+			Summary: "poll the pipeline for HEAD (or a commit) to terminal; non-zero on failure: ci watch [commit] [--repo owner/name]", Run: ciWatch},
+		{Path: []string{"ci", "dashboard"}, Tier: TierRead,
+			Summary: "latest pipeline of every repo the token can see, failures first: ci dashboard [--json]", Run: ciDashboard},
+		{Path: []string{"ci", "logs"}, Tier: TierRead,
+			Summary: "step log for a pipeline (default: the failed step): ci logs <pipeline#> [step] [--tail N]", Run: ciLogs},
+		{Path: []string{"ci", "restart"}, Tier: TierWrite,
+			Summary: "re-run a whole pipeline: ci restart <pipeline#> [--mark-failed] [--var K=V ...]", Run: ciRestart},
+		{Path: []string{"ci", "cancel"}, Tier: TierWrite,
+			Summary: "cancel a pending/running pipeline: ci cancel <pipeline#>", Run: ciCancel},
+		{Path: []string{"ci", "trigger"}, Tier: TierWrite,
+			Summary: "start a manual pipeline or run a cron now: ci trigger [--branch B] [--var K=V ...] | ci trigger --cron NAME", Run: ciTrigger},
 	}
 }
 
+// restartWorkflowsVar is set on a `ci restart --mark-failed` run to the
+// comma-separated names of the workflows that failed. Woodpecker has no
+// per-workflow restart, so the whole pipeline re-runs; a workflow can opt into
+// skipping itself with
+//
+//	when:
+//	  evaluate: 'HOMELAB_RESTART_WORKFLOWS == "" || "<name>" in split(HOMELAB_RESTART_WORKFLOWS, ",")'
+//
+// Workflows without that clause simply run again.
+const restartWorkflowsVar = "HOMELAB_RESTART_WORKFLOWS"
+
+// ciFailureTail is how many log lines `ci watch` prints from the failed step.
+const ciFailureTail = 40
+
 func short(s string) string {
 	if len(s) > 8 {
 		return s[:8]
@@ -37,12 +66,12 @@ func currentHEAD() string {
 }
 
 func ciStatus(args []string) error {
-	commit, _ := firstPositional(args)
+	commit := firstOf(ciPositionals(args))
 	c, err := newWPClient()
 	if err != nil {
 		return err
 	}
-	id, err := c.repoID()
+	id, err := c.repoID(flagValue(args, "--repo"))
 	if err != nil {
 		return err
 	}
@@ -55,7 +84,10 @@ func ciStatus(args []string) error {
 }
 
 func ciWatch(args []string) error {
-	commit, _ := firstPositional(args)
+	commit := firstOf(ciPositionals(args))
+	if commit == "" && flagValue(args, "--repo") != "" {
+		return fmt.Errorf("ci watch --repo needs an explicit commit (HEAD belongs to the cwd repo)")
+	}
 	if commit == "" {
 		commit = currentHEAD()
 	}
@@ -66,7 +98,7 @@ func ciWatch(args []string) error {
 	if err != nil {
 		return err
 	}
-	id, err := c.repoID()
+	id, err := c.repoID(flagValue(args, "--repo"))
 	if err != nil {
 		return err
 	}
@@ -88,7 +120,11 @@ func ciWatch(args []string) error {
 			if isTerminalStatus(p.Status) {
 				fmt.Printf("#%d %s %s\n", p.Number, p.Status, short(commit))
 				if isFailureStatus(p.Status) {
-					return fmt.Errorf("pipeline #%d %s (woodpecker repo, see UI/DB for the failing step)", p.Number, p.Status)
+					if s, ok := reportFailedStep(c, id, p.Number, ciFailureTail); ok {
+						return fmt.Errorf("pipeline #%d %s: step %q exited %d (full log: %s)",
+							p.Number, p.Status, s.Name, s.ExitCode, ciLogsHint(p.Number, s.Name, flagValue(args, "--repo")))
+					}
+					return fmt.Errorf("pipeline #%d %s (no failed step found — see the UI)", p.Number, p.Status)
 				}
 				return nil
 			}
@@ -97,3 +133,410 @@ func ciWatch(args []string) error {
 	}
 	return fmt.Errorf("timed out after %s waiting for CI on %s", timeout, short(commit))
 }
+
+// ciLogsHint is the `ci logs` command for a step, carrying --repo when the
+// pipeline is not the cwd repo's.
+func ciLogsHint(number int, step, repo string) string {
+	hint := fmt.Sprintf("homelab ci logs %d %s", number, step)
+	if repo != "" {
+		hint += " --repo " + repo
+	}
+	return hint
+}
+
+// reportFailedStep prints the first failed step's exit code and last n log
+// lines to stderr. Best-effort: the detail/log endpoints are the flakiest part
+// of the Woodpecker API, so a fetch error just reports ok=false and the caller
+// falls back to the bare status.
+func reportFailedStep(c *wpClient, repoID, number, n int) (wpStep, bool) {
+	d, err := c.pipelineDetail(repoID, number)
+	if err != nil {
+		return wpStep{}, false
+	}
+	failed := failedSteps(d.Workflows)
+	if len(failed) == 0 {
+		return wpStep{}, false
+	}
+	s := failed[0]
+	fmt.Fprintf(os.Stderr, "homelab: step %q (#%d) %s, exit code %d\n", s.Name, s.PID, s.State, s.ExitCode)
+	if s.Error != "" {
+		fmt.Fprintf(os.Stderr, "homelab: error: %s\n", s.Error)
+	}
+	lines, err := c.stepLogs(repoID, number, s.ID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "homelab: could not fetch the step log: %v\n", err)
+		return s, true
+	}
+	fmt.Fprintf(os.Stderr, "--- last %d lines of %s ---\n", len(tailLines(lines, n)), s.Name)
+	for _, l := range tailLines(lines, n) {
+		fmt.Fprintln(os.Stderr, l)
+	}
+	return s, true
+}
+
+// ciLogs prints one step's log. With no step given it picks the first failed
+// step, and when nothing failed it lists the steps to choose from.
+func ciLogs(args []string) error {
+	tail := 0
+	if v := flagValue(args, "--tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("bad --tail %q: %w", v, err)
+		}
+		tail = n
+	}
+	number, err := ciPipelineNumber(args, "homelab ci logs <pipeline#> [step] [--tail N]", "--tail")
+	if err != nil {
+		return err
+	}
+	pos := ciPositionals(args, "--tail")
+	c, err := newWPClient()
+	if err != nil {
+		return err
+	}
+	id, err := c.repoID(flagValue(args, "--repo"))
+	if err != nil {
+		return err
+	}
+	d, err := c.pipelineDetail(id, number)
+	if err != nil {
+		return err
+	}
+	var step wpStep
+	if len(pos) > 1 {
+		s, ok := findStep(d.Workflows, pos[1])
+		if !ok {
+			return fmt.Errorf("no step %q in pipeline #%d", pos[1], number)
+		}
+		step = s
+	} else if failed := failedSteps(d.Workflows); len(failed) > 0 {
+		step = failed[0]
+	} else {
+		fmt.Printf("#%d %s — no failed step; pick one:\n", d.Number, d.Status)
+		for _, wf := range d.Workflows {
+			for _, s := range wf.Steps {
+				fmt.Printf("  %3d  %-10s %s/%s\n", s.PID, s.State, wf.Name, s.Name)
+			}
+		}
+		return nil
+	}
+	lines, err := c.stepLogs(id, number, step.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "homelab: #%d step %q %s, exit code %d\n", number, step.Name, step.State, step.ExitCode)
+	for _, l := range tailLines(lines, tail) {
+		fmt.Println(l)
+	}
+	return nil
+}
+
+// positionalsSkipping returns the bare args, skipping the values of the given
+// value-flags (so `--tail 50 12 build` yields [12 build], not [50 12 build]).
+func positionalsSkipping(args []string, valueFlags map[string]bool) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if valueFlags[a] {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(a, "-") {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// parseVars turns repeated `--var K=V` values into a variable map.
+func parseVars(kvs []string) (map[string]string, error) {
+	if len(kvs) == 0 {
+		return nil, nil
+	}
+	vars := map[string]string{}
+	for _, kv := range kvs {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("--var wants KEY=VALUE, got %q", kv)
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// failedWorkflowNames lists the workflows of a pipeline that ended in failure.
+func failedWorkflowNames(wfs []wpWorkflow) []string {
+	var out []string
+	for _, wf := range wfs {
+		if isFailureStatus(wf.State) {
+			out = append(out, wf.Name)
+		}
+	}
+	return out
+}
+
+// ciPositionals returns the bare args of a ci verb, skipping the values of the
+// shared --repo flag and of the verb's own value flags.
+func ciPositionals(args []string, valueFlags ...string) []string {
+	skip := map[string]bool{"--repo": true}
+	for _, f := range valueFlags {
+		skip[f] = true
+	}
+	return positionalsSkipping(args, skip)
+}
+
+func firstOf(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	return ss[0]
+}
+
+// ciPipelineNumber parses the leading `<pipeline#>` positional (a `#` prefix is
+// tolerated, as the UI and `ci status` print it that way).
+func ciPipelineNumber(args []string, usage string, valueFlags ...string) (int, error) {
+	pos := ciPositionals(args, valueFlags...)
+	if len(pos) == 0 {
+		return 0, fmt.Errorf("usage: %s", usage)
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(pos[0], "#"))
+	if err != nil {
+		return 0, fmt.Errorf("pipeline number must be an integer, got %q", pos[0])
+	}
+	return n, nil
+}
+
+func ciRestart(args []string) error {
+	number, err := ciPipelineNumber(args, "homelab ci restart <pipeline#> [--mark-failed] [--var K=V ...]", "--var")
+	if err != nil {
+		return err
+	}
+	vars, err := parseVars(flagValues(args, "--var"))
+	if err != nil {
+		return err
+	}
+	c, err := newWPClient()
+	if err != nil {
+		return err
+	}
+	id, err := c.repoID(flagValue(args, "--repo"))
+	if err != nil {
+		return err
+	}
+	params := url.Values{}
+	for k, v := range vars {
+		params.Set(k, v)
+	}
+	if containsArg(args, "--mark-failed") {
+		d, err := c.pipelineDetail(id, number)
+		if err != nil {
+			return err
+		}
+		failed := failedWorkflowNames(d.Workflows)
+		if len(failed) == 0 {
+			return fmt.Errorf("pipeline #%d (%s) has no failed workflows to restart", number, d.Status)
+		}
+		params.Set(restartWorkflowsVar, strings.Join(failed, ","))
+		fmt.Fprintf(os.Stderr, "homelab: re-running ALL workflows of #%d (Woodpecker has no per-workflow restart); %s=%s\n"+
+			"  only workflows with a matching `when: evaluate` clause skip themselves\n", number, restartWorkflowsVar, strings.Join(failed, ","))
+	}
+	p, err := c.restartPipeline(id, number, params)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("#%d %s (restart of #%d) %s\n", p.Number, p.Status, number, short(p.Commit))
+	return nil
+}
+
+func ciCancel(args []string) error {
+	number, err := ciPipelineNumber(args, "homelab ci cancel <pipeline#>")
+	if err != nil {
+		return err
+	}
+	c, err := newWPClient()
+	if err != nil {
+		return err
+	}
+	id, err := c.repoID(flagValue(args, "--repo"))
+	if err != nil {
+		return err
+	}
+	if err := c.cancelPipeline(id, number); err != nil {
+		return err
+	}
+	fmt.Printf("#%d cancelled\n", number)
+	return nil
+}
+
+func ciTrigger(args []string) error {
+	vars, err := parseVars(flagValues(args, "--var"))
+	if err != nil {
+		return err
+	}
+	c, err := newWPClient()
+	if err != nil {
+		return err
+	}
+	repo, err := c.lookupRepo(flagValue(args, "--repo"))
+	if err != nil {
+		return err
+	}
+	id := repo.ID
+	var p wpPipeline
+	if cron := flagValue(args, "--cron"); cron != "" {
+		if vars != nil {
+			return fmt.Errorf("--var cannot be combined with --cron (a cron run uses its own configured variables)")
+		}
+		p, err = c.runCron(id, cron)
+	} else {
+		branch := flagValue(args, "--branch")
+		if branch == "" {
+			branch = repo.DefaultBranch
+		}
+		if branch == "" {
+			return fmt.Errorf("woodpecker reports no default branch for this repo; pass --branch")
+		}
+		p, err = c.triggerManual(id, branch, vars)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("#%d %s event=%s %s\n", p.Number, p.Status, p.Event, short(p.Commit))
+	return nil
+}
+
+// dashboardRow is one repo's latest pipeline for `ci dashboard`. Pipeline is
+// nil when the repo has never run one; Err is set when the fetch failed.
+type dashboardRow struct {
+	Repo     string      `json:"repo"`
+	Pipeline *wpPipeline `json:"pipeline,omitempty"`
+	Err      string      `json:"error,omitempty"`
+}
+
+// status is the row's effective state ("none" for never-built, "unknown" when
+// the fetch failed).
+func (r dashboardRow) status() string {
+	switch {
+	case r.Err != "":
+		return "unknown"
+	case r.Pipeline == nil:
+		return "none"
+	}
+	return r.Pipeline.Status
+}
+
+// dashboardRank orders rows so red repos come first, then in-flight ones, then
+// the ones we couldn't read, then green/never-built.
+func dashboardRank(status string) int {
+	switch {
+	case isFailureStatus(status):
+		return 0
+	case !isTerminalStatus(status) && status != "none" && status != "unknown":
+		return 1
+	case status == "unknown":
+		return 2
+	}
+	return 3
+}
+
+func sortDashboard(rows []dashboardRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		ri, rj := dashboardRank(rows[i].status()), dashboardRank(rows[j].status())
+		if ri != rj {
+			return ri < rj
+		}
+		return strings.ToLower(rows[i].Repo) < strings.ToLower(rows[j].Repo)
+	})
+}
+
+// ciDashboardWorkers bounds concurrent per-repo fetches, so a sweep over every
+// repo doesn't pile load onto an API that already flakes under it.
+const ciDashboardWorkers = 6
+
+func ciDashboard(args []string) error {
+	c, err := newWPClient()
+	if err != nil {
+		return err
+	}
+	repos, err := c.userRepos()
+	if err != nil {
+		return err
+	}
+	rows := make([]dashboardRow, len(repos))
+	sem := make(chan struct{}, ciDashboardWorkers)
+	var wg sync.WaitGroup
+	for i, r := range repos {
+		wg.Add(1)
+		go func(i int, r wpRepo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			row := dashboardRow{Repo: r.FullName}
+			ps, err := c.recentPipelines(r.ID, 1)
+			if err != nil {
+				row.Err = err.Error()
+			} else if len(ps) > 0 {
+				row.Pipeline = &ps[0]
+			}
+			rows[i] = row
+		}(i, r)
+	}
+	wg.Wait()
+	sortDashboard(rows)
+	if containsArg(args, "--json") {
+		b, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Print(formatDashboard(rows, time.Now()))
+	return nil
+}
+
+// formatDashboard renders one aligned line per repo.
+func formatDashboard(rows []dashboardRow, now time.Time) string {
+	width := 0
+	for _, r := range rows {
+		if len(r.Repo) > width {
+			width = len(r.Repo)
+		}
+	}
+	var b strings.Builder
+	red := 0
+	for _, r := range rows {
+		st := r.status()
+		if isFailureStatus(st) {
+			red++
+		}
+		line := fmt.Sprintf("%-8s %-*s", st, width, r.Repo)
+		switch {
+		case r.Err != "":
+			line += "  " + r.Err
+		case r.Pipeline != nil:
+			p := r.Pipeline
+			line += fmt.Sprintf("  #%-5d %-8s %-12s %s", p.Number, short(p.Commit), p.Event, ageSince(p.Created, now))
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	fmt.Fprintf(&b, "%d repos, %d failing\n", len(rows), red)
+	return b.String()
+}
+
+// ageSince renders a unix timestamp as a coarse "3h ago".
+func ageSince(unix int64, now time.Time) string {
+	if unix == 0 {
+		return ""
+	}
+	d := now.Sub(time.Unix(unix, 0))
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net"
+	"os"
+	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -38,11 +43,21 @@ const (
 func crowdsecCommands() []Command {
 	return []Command{
 		{Path: []string{"crowdsec", "ban"}, Tier: TierWrite,
-			Summary: "ban an IP/CIDR with a bounded expiry: crowdsec ban <ip|cidr> --reason \"why\" [--duration 24h, max 168h]", Run: crowdsecBan},
+			Summary: "ban an IP/CIDR (or every line of --from-file) with a bounded expiry: crowdsec ban <ip|cidr>|--from-file F --reason \"why\" [--duration 24h, max 168h]", Run: crowdsecBan},
+		{Path: []string{"crowdsec", "allowlist", "add"}, Tier: TierWrite,
+			Summary: "allowlist an IP/CIDR so it can never be banned: crowdsec allowlist add <ip|cidr>... --reason \"why\"", Run: crowdsecAllowlistAdd},
+		{Path: []string{"crowdsec", "allowlist", "rm"}, Tier: TierWrite,
+			Summary: "remove IPs/CIDRs from the allowlist: crowdsec allowlist rm <ip|cidr>...", Run: crowdsecAllowlistRm},
+		{Path: []string{"crowdsec", "allowlist", "list"}, Tier: TierRead,
+			Summary: "list the allowlist: crowdsec allowlist list [--json]", Run: crowdsecAllowlistList},
+		{Path: []string{"crowdsec", "history"}, Tier: TierRead,
+			Summary: "past alerts and decisions for an address: crowdsec history <ip|cidr> [--json]", Run: crowdsecHistory},
+		{Path: []string{"crowdsec", "explain"}, Tier: TierRead,
+			Summary: "why an IP is banned, and what it did through Traefik: crowdsec explain <ip> [--since 24h] [--top 10] [--json]", Run: crowdsecExplain},
 		{Path: []string{"crowdsec", "unban"}, Tier: TierWrite,
 			Summary: "remove every decision for an IP/CIDR: crowdsec unban <ip|cidr>", Run: crowdsecUnban},
 		{Path: []string{"crowdsec", "decisions"}, Tier: TierRead,
-			Summary: "list local CrowdSec decisions (add --all to include the community blocklist)", Run: crowdsecDecisions},
+			Summary: "list CrowdSec decisions in force: crowdsec decisions [--all] [--origin O] [--scenario S] [--scope ip|range] [--ip X] [--json]", Run: crowdsecDecisions},
 		{Path: []string{"crowdsec"}, Tier: TierRead,
 			Summary: "CrowdSec decisions with a bounded manual-ban lifetime (run `homelab crowdsec` for help)",
 			Run:     func([]string) error { fmt.Print(crowdsecHelp()); return nil }},
@@ -55,14 +70,35 @@ func crowdsecHelp() string {
   homelab crowdsec ban <ip|cidr> --reason "why" [--duration 24h]
         Ban with an expiry. Default 24h, hard cap 168h (7d). A reason is
         required — it is the only record of why the block exists.
+  homelab crowdsec ban --from-file ips.txt --reason "why" [--duration 24h]
+        Bulk ban, one IP/CIDR per line (# comments). Every line is validated
+        before anything is banned; already-banned and allowlisted addresses
+        are skipped, so re-running the same file is safe.
   homelab crowdsec unban <ip|cidr>    delete every decision for the address
-  homelab crowdsec decisions [--all]  list decisions (--all includes CAPI)
+  homelab crowdsec decisions [--all] [--origin O] [--scenario S] [--scope S] [--ip X] [--json]
+        Decisions in force (--all includes CAPI), filtered.
+  homelab crowdsec history <ip|cidr>  past alerts and decisions for the address
+  homelab crowdsec explain <ip> [--since 24h]
+        The alerts behind the active decision, plus the address's Traefik
+        access lines by host, status, path and user agent — check for a
+        false positive before unbanning.
+  homelab crowdsec allowlist add <ip|cidr>... --reason "why"
+  homelab crowdsec allowlist rm <ip|cidr>...
+  homelab crowdsec allowlist list [--json]
+        The ` + "`" + crowdsecAllowlist + "`" + ` centralized allowlist: LAPI refuses
+        decisions on anything in it — the guard against banning our own
+        egress IP again.
 
 Bans are enforced in two places: the node firewall bouncer (all traffic) and
 the Cloudflare edge IP list for proxied hosts. The edge list is slow to
 correct — Cloudflare rate-limits Lists writes hard — so prefer a short expiry
 over trusting that you can undo a long one quickly.
 
+With CROWDSEC_MACHINE_ID and CROWDSEC_MACHINE_PASSWORD set (a machine from
+` + "`cscli machines add homelab-cli --auto`" + `), the verbs call the LAPI API
+directly — via CROWDSEC_LAPI_URL, or a port-forward to the LAPI Service —
+instead of exec'ing cscli in the LAPI pod. Allowlist changes always use cscli.
+
 Blocks meant to be permanent belong in the reviewable external blocklist
 import (stacks/crowdsec), not in a manual decision.
 `
@@ -118,6 +154,14 @@ func parseBanDuration(s string) (time.Duration, error) {
 // validateBanRequest checks the ban target is a literal IP or CIDR and that a
 // reason was given.
 func validateBanRequest(target, reason string) error {
+	if err := validateBanTarget(target); err != nil {
+		return err
+	}
+	return validateBanReason(reason)
+}
+
+// validateBanTarget checks target is a literal IP or CIDR.
+func validateBanTarget(target string) error {
 	target = strings.TrimSpace(target)
 	if target == "" {
 		return fmt.Errorf("an IP or CIDR to ban is required")
@@ -129,39 +173,54 @@ func validateBanRequest(target, reason string) error {
 	} else if net.ParseIP(target) == nil {
 		return fmt.Errorf("%q is not a valid IP address (hostnames are not bannable — resolve it first, and check the address is not our own egress)", target)
 	}
+	return nil
+}
+
+// validateBanReason rejects an empty --reason.
+func validateBanReason(reason string) error {
 	if strings.TrimSpace(reason) == "" {
 		return fmt.Errorf("--reason is required: a ban with no stated reason cannot be reviewed or safely undone later")
 	}
 	return nil
 }
 
-// parseCrowdsecBanArgs pulls the target, --reason and --duration out of argv.
-func parseCrowdsecBanArgs(args []string) (target, reason, duration string, err error) {
+// parseCrowdsecBanArgs pulls the target (or --from-file), --reason and
+// --duration out of argv.
+func parseCrowdsecBanArgs(args []string) (target, fromFile, reason, duration string, err error) {
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
+		case "--from-file", "-f":
+			if i+1 >= len(args) {
+				return "", "", "", "", fmt.Errorf("--from-file needs a value")
+			}
+			fromFile = args[i+1]
+			i++
 		case "--reason", "-r":
 			if i+1 >= len(args) {
-				return "", "", "", fmt.Errorf("--reason needs a value")
+				return "", "", "", "", fmt.Errorf("--reason needs a value")
 			}
 			reason = args[i+1]
 			i++
 		case "--duration", "-d":
 			if i+1 >= len(args) {
-				return "", "", "", fmt.Errorf("--duration needs a value")
+				return "", "", "", "", fmt.Errorf("--duration needs a value")
 			}
 			duration = args[i+1]
 			i++
 		default:
 			if strings.HasPrefix(args[i], "-") {
-				return "", "", "", fmt.Errorf("unknown flag %q", args[i])
+				return "", "", "", "", fmt.Errorf("unknown flag %q", args[i])
 			}
 			if target != "" {
-				return "", "", "", fmt.Errorf("expected one target, got %q and %q", target, args[i])
+				return "", "", "", "", fmt.Errorf("expected one target, got %q and %q", target, args[i])
 			}
 			target = args[i]
 		}
 	}
-	return target, reason, duration, nil
+	if target != "" && fromFile != "" {
+		return "", "", "", "", fmt.Errorf("give one target or --from-file, not both")
+	}
+	return target, fromFile, reason, duration, nil
 }
 
 // --- runners ---------------------------------------------------------------
@@ -180,11 +239,59 @@ func crowdsecLapiPod() (string, error) {
 	return "", fmt.Errorf("no running CrowdSec LAPI pod in namespace %s", crowdsecNamespace)
 }
 
+// cscliCapture runs cscli on the LAPI pod and returns its stdout, with
+// cscli's stderr in the error.
+func cscliCapture(pod string, args ...string) ([]byte, error) {
+	cmd := exec.Command("kubectl", kubectlBase(crowdsecNamespace, append([]string{"exec", pod, "--", "cscli"}, args...)...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cscli %s: %v: %s", strings.Join(args[:min(2, len(args))], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// cscliTargetFlag picks cscli's flag for a target: a CIDR is a range.
+func cscliTargetFlag(target string) string {
+	if strings.Contains(target, "/") {
+		return "--range"
+	}
+	return "--ip"
+}
+
+// cscliCombined is cscliCapture for the commands that report on stderr
+// (cscli logs "N decision(s) deleted" rather than printing it).
+func cscliCombined(pod string, args ...string) ([]byte, error) {
+	out, err := exec.Command("kubectl", kubectlBase(crowdsecNamespace, append([]string{"exec", pod, "--", "cscli"}, args...)...)...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("cscli %s: %v: %s", strings.Join(args[:min(2, len(args))], " "), err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// csAllowlistItems reads the crowdsecAllowlist entries; exists is false when
+// the list has not been created yet.
+func csAllowlistItems(pod string) (items []csAllowlistItem, exists bool, err error) {
+	out, err := cscliCapture(pod, "allowlists", "inspect", crowdsecAllowlist, "-o", "json")
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	items, err = parseCSAllowlist(out)
+	return items, true, err
+}
+
 func crowdsecBan(args []string) error {
-	target, reason, durationArg, err := parseCrowdsecBanArgs(args)
+	target, fromFile, reason, durationArg, err := parseCrowdsecBanArgs(args)
 	if err != nil {
 		return err
 	}
+	if fromFile != "" {
+		return crowdsecBanFile(fromFile, reason, durationArg)
+	}
 	if err := validateBanRequest(target, reason); err != nil {
 		return err
 	}
@@ -192,48 +299,421 @@ func crowdsecBan(args []string) error {
 	if err != nil {
 		return err
 	}
-	pod, err := crowdsecLapiPod()
+	cs, err := newCSBackend()
 	if err != nil {
 		return err
 	}
-	if err := kubectlStream(crowdsecNamespace, "exec", pod, "--",
-		"cscli", "decisions", "add", "--ip", target, "--duration", humanDuration(d), "--reason", reason); err != nil {
-		return fmt.Errorf("cscli decisions add failed: %w", err)
+	defer cs.close()
+	if items, _, err := cs.allowlist(); err != nil {
+		fmt.Fprintf(os.Stderr, "homelab crowdsec: warning: could not read the allowlist (%v); LAPI still enforces it\n", err)
+	} else if it, ok := allowlistCovering(items, target); ok {
+		return fmt.Errorf("%s is covered by allowlist entry %s (%s); `homelab crowdsec allowlist rm %s` first if the ban is really intended",
+			target, it.Value, it.Description, it.Value)
+	}
+	if err := cs.addBan(target, d, reason); err != nil {
+		return fmt.Errorf("ban failed: %w", err)
 	}
 	fmt.Printf("banned %s for %s — expires on its own; `homelab crowdsec unban %s` to lift it sooner\n", target, humanDuration(d), target)
 	fmt.Println("note: proxied hosts are enforced via the Cloudflare edge list, which can lag by hours (Cloudflare rate-limits Lists writes)")
 	return nil
 }
 
+// crowdsecBanFile bans every address in a file with the same reason and
+// duration. It is idempotent: addresses that already have a ban, or that the
+// allowlist covers, are skipped and reported as such.
+func crowdsecBanFile(path, reason, durationArg string) error {
+	if err := validateBanReason(reason); err != nil {
+		return err
+	}
+	d, err := parseBanDuration(durationArg)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	targets, err := parseBanFile(string(content), reason)
+	if err != nil {
+		return err
+	}
+	cs, err := newCSBackend()
+	if err != nil {
+		return err
+	}
+	defer cs.close()
+	existing, err := cs.alerts(csFilter{Active: true})
+	if err != nil {
+		return err
+	}
+	banned := activeBans(existing)
+	allow, _, err := cs.allowlist()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "homelab crowdsec: warning: could not read the allowlist (%v); LAPI still enforces it\n", err)
+	}
+	var results []bulkResult
+	failed := 0
+	for _, t := range targets {
+		r := bulkResult{Target: t}
+		if it, ok := allowlistCovering(allow, t); ok {
+			r.Result = "skipped: allowlisted (" + it.Value + ")"
+		} else if banned[t] {
+			r.Result = "already banned"
+		} else if err := cs.addBan(t, d, reason); err != nil {
+			r.Result, r.Failed = "failed: "+err.Error(), true
+			failed++
+		} else {
+			r.Result = "banned " + humanDuration(d)
+		}
+		results = append(results, r)
+	}
+	fmt.Print(formatBulkSummary(results))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d bans failed; re-run the same file to retry them (the rest are skipped)", failed, len(targets))
+	}
+	fmt.Println("note: proxied hosts are enforced via the Cloudflare edge list, which can lag by hours (Cloudflare rate-limits Lists writes)")
+	return nil
+}
+
 func crowdsecUnban(args []string) error {
 	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
 		return fmt.Errorf("usage: homelab crowdsec unban <ip|cidr>")
 	}
 	target := strings.TrimSpace(args[0])
-	if err := validateBanRequest(target, "unban needs no reason"); err != nil {
+	if err := validateBanTarget(target); err != nil {
 		return err
 	}
-	pod, err := crowdsecLapiPod()
+	cs, err := newCSBackend()
 	if err != nil {
 		return err
 	}
-	if err := kubectlStream(crowdsecNamespace, "exec", pod, "--",
-		"cscli", "decisions", "delete", "--ip", target); err != nil {
-		return fmt.Errorf("cscli decisions delete failed: %w", err)
+	defer cs.close()
+	n, err := cs.deleteDecisions(target)
+	if err != nil {
+		return fmt.Errorf("unban failed: %w", err)
+	}
+	if n >= 0 {
+		fmt.Printf("removed %d decision(s) for %s\n", n, target)
+	} else {
+		fmt.Printf("removed local decisions for %s\n", target)
 	}
-	fmt.Printf("removed local decisions for %s\n", target)
 	fmt.Println("note: the Cloudflare edge list is reconciled by the crowdsec-cf-sync CronJob and may lag; check `homelab crowdsec decisions` plus the list itself if a proxied host still blocks")
 	return nil
 }
 
+// crowdsecDecisions lists the decisions in force, filtered by origin,
+// scenario and scope on either backend.
 func crowdsecDecisions(args []string) error {
+	f := csFilter{
+		Active:      true,
+		IncludeCAPI: containsArg(args, "--all"),
+		Origin:      flagValue(args, "--origin"),
+		Scenario:    flagValue(args, "--scenario"),
+		Scope:       flagValue(args, "--scope"),
+	}
+	if t := flagValue(args, "--ip"); t != "" {
+		if toNet(t) == nil {
+			return fmt.Errorf("--ip %q is not a valid IP address or CIDR", t)
+		}
+		f.Target = t
+	}
+	cs, err := newCSBackend()
+	if err != nil {
+		return err
+	}
+	defer cs.close()
+	alerts, err := cs.alerts(f)
+	if err != nil {
+		return err
+	}
+	rows := decisionRows(alerts)
+	if containsArg(args, "--json") {
+		if rows == nil {
+			rows = []csDecisionRow{}
+		}
+		b, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Print(formatDecisions(rows))
+	return nil
+}
+
+// allowlistTargets validates the positional IPs/CIDRs of an allowlist verb.
+func allowlistTargets(args []string, valueFlags map[string]bool) ([]string, error) {
+	targets := positionalsSkipping(args, valueFlags)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("at least one IP or CIDR is required")
+	}
+	for _, t := range targets {
+		if toNet(t) == nil {
+			return nil, fmt.Errorf("%q is not a valid IP address or CIDR", t)
+		}
+	}
+	return targets, nil
+}
+
+func crowdsecAllowlistAdd(args []string) error {
+	targets, err := allowlistTargets(args, map[string]bool{"--reason": true, "-r": true})
+	if err != nil {
+		return fmt.Errorf("%v\nusage: homelab crowdsec allowlist add <ip|cidr>... --reason \"why\"", err)
+	}
+	reason := strings.TrimSpace(flagValue(args, "--reason"))
+	if reason == "" {
+		reason = strings.TrimSpace(flagValue(args, "-r"))
+	}
+	if reason == "" {
+		return fmt.Errorf("--reason is required: say whose address it is, so the entry can be reviewed later")
+	}
+	pod, err := crowdsecLapiPod()
+	if err != nil {
+		return err
+	}
+	items, exists, err := csAllowlistItems(pod)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := cscliCapture(pod, "allowlists", "create", crowdsecAllowlist, "-d", "operational allowlist managed by `homelab crowdsec allowlist`"); err != nil {
+			return err
+		}
+	}
+	present := map[string]bool{}
+	for _, it := range items {
+		present[it.Value] = true
+	}
+	existing, err := cscliBackend{pod}.alerts(csFilter{Active: true})
+	if err != nil {
+		return err
+	}
+	banned := activeBans(existing)
+	var results []bulkResult
+	failed := 0
+	for _, t := range targets {
+		r := bulkResult{Target: t}
+		switch {
+		case present[t]:
+			r.Result = "already allowlisted"
+		default:
+			if _, err := cscliCapture(pod, "allowlists", "add", crowdsecAllowlist, t, "-d", reason); err != nil {
+				r.Result, r.Failed = "failed: "+err.Error(), true
+				failed++
+			} else {
+				r.Result = "allowlisted"
+			}
+		}
+		if banned[t] && !r.Failed {
+			r.Result += " (an active ban remains: homelab crowdsec unban " + t + ")"
+		}
+		results = append(results, r)
+	}
+	fmt.Print(formatBulkSummary(results))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d allowlist additions failed", failed, len(targets))
+	}
+	return nil
+}
+
+func crowdsecAllowlistRm(args []string) error {
+	targets, err := allowlistTargets(args, nil)
+	if err != nil {
+		return fmt.Errorf("%v\nusage: homelab crowdsec allowlist rm <ip|cidr>...", err)
+	}
 	pod, err := crowdsecLapiPod()
 	if err != nil {
 		return err
 	}
-	cmd := []string{"exec", pod, "--", "cscli", "decisions", "list"}
-	if containsArg(args, "--all") {
-		cmd = append(cmd, "-a")
+	items, _, err := csAllowlistItems(pod)
+	if err != nil {
+		return err
+	}
+	present := map[string]bool{}
+	for _, it := range items {
+		present[it.Value] = true
+	}
+	var results []bulkResult
+	failed := 0
+	for _, t := range targets {
+		r := bulkResult{Target: t}
+		if !present[t] {
+			r.Result = "not on the allowlist"
+		} else if _, err := cscliCapture(pod, "allowlists", "remove", crowdsecAllowlist, t); err != nil {
+			r.Result, r.Failed = "failed: "+err.Error(), true
+			failed++
+		} else {
+			r.Result = "removed"
+		}
+		results = append(results, r)
+	}
+	fmt.Print(formatBulkSummary(results))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d removals failed", failed, len(targets))
+	}
+	return nil
+}
+
+func crowdsecAllowlistList(args []string) error {
+	cs, err := newCSBackend()
+	if err != nil {
+		return err
+	}
+	defer cs.close()
+	items, _, err := cs.allowlist()
+	if err != nil {
+		return err
+	}
+	if containsArg(args, "--json") {
+		if items == nil {
+			items = []csAllowlistItem{}
+		}
+		b, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Print(formatAllowlist(items))
+	return nil
+}
+
+// csAddressAlerts is every alert CrowdSec kept for target, plus the IDs of
+// the decisions still in force for it. The active lookup includes CAPI, so an
+// address blocked only by the community blocklist is not reported as free; its
+// alert is added to the list for the caller to show.
+func csAddressAlerts(cs csBackend, target string) ([]csAlert, map[int64]bool, error) {
+	alerts, err := cs.alerts(csFilter{Target: target})
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := cs.alerts(csFilter{Target: target, Active: true, IncludeCAPI: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := map[int64]bool{}
+	for _, a := range alerts {
+		seen[a.ID] = true
+	}
+	active := map[int64]bool{}
+	for _, a := range current {
+		for _, d := range a.Decisions {
+			active[d.ID] = true
+		}
+		if !seen[a.ID] {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts, active, nil
+}
+
+// crowdsecHistory lists every alert CrowdSec kept for an address — scenario
+// triggers and manual bans alike — with the decisions each produced, marking
+// the ones still in force.
+func crowdsecHistory(args []string) error {
+	target, _ := firstPositional(args)
+	if target == "" || toNet(target) == nil {
+		return fmt.Errorf("usage: homelab crowdsec history <ip|cidr> [--json]")
+	}
+	cs, err := newCSBackend()
+	if err != nil {
+		return err
+	}
+	defer cs.close()
+	alerts, active, err := csAddressAlerts(cs, target)
+	if err != nil {
+		return err
 	}
-	return kubectlStream(crowdsecNamespace, cmd...)
+	if containsArg(args, "--json") {
+		if alerts == nil {
+			alerts = []csAlert{}
+		}
+		b, err := json.MarshalIndent(alerts, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Print(formatHistory(target, alerts, active))
+	return nil
+}
+
+// explainMaxLines bounds the Loki read of `crowdsec explain`: a scanner can
+// send far more lines than a summary needs.
+const explainMaxLines = 20000
+
+// crowdsecExplain shows why an address was blocked — the alerts and scenarios
+// behind its decisions — next to what it actually did through Traefik, so a
+// false positive can be judged before `unban`.
+func crowdsecExplain(args []string) error {
+	pos := positionalsSkipping(args, map[string]bool{"--since": true, "--top": true})
+	if len(pos) != 1 || net.ParseIP(pos[0]) == nil {
+		return fmt.Errorf("usage: homelab crowdsec explain <ip> [--since 24h] [--top 10] [--json]")
+	}
+	ip := pos[0]
+	since := flagValue(args, "--since")
+	if since == "" {
+		since = "24h"
+	}
+	dur, err := parsePromDuration(since)
+	if err != nil {
+		return fmt.Errorf("--since: %w", err)
+	}
+	top := 10
+	if v := flagValue(args, "--top"); v != "" {
+		if top, err = strconv.Atoi(v); err != nil || top <= 0 {
+			return fmt.Errorf("bad --top %q: want a positive number", v)
+		}
+	}
+	cs, err := newCSBackend()
+	if err != nil {
+		return err
+	}
+	defer cs.close()
+	alerts, active, err := csAddressAlerts(cs, ip)
+	if err != nil {
+		return err
+	}
+	// The access lines are the evidence, not the verdict: if Loki is down the
+	// CrowdSec half is still worth printing.
+	end := time.Now()
+	entries, complete, _, lokiErr := lokiWindow(accessLogQuery(ip), end.Add(-dur), end, logsAllPage, explainMaxLines)
+	summary := summariseAccess(entryLines(entries))
+	if containsArg(args, "--json") {
+		if alerts == nil {
+			alerts = []csAlert{}
+		}
+		ids := []int64{}
+		for id := range active {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		out := map[string]interface{}{"ip": ip, "alerts": alerts, "active_decision_ids": ids, "window": since}
+		if lokiErr != nil {
+			out["access_error"] = lokiErr.Error()
+		} else {
+			out["access"] = summary
+			out["access_complete"] = complete
+		}
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Print(formatExplain(ip, alerts, active))
+	fmt.Printf("\nTraefik access from %s, last %s:\n", ip, since)
+	if lokiErr != nil {
+		fmt.Printf("  (Loki query failed: %v)\n", lokiErr)
+		return nil
+	}
+	fmt.Print(formatAccessSummary(summary, top))
+	if !complete {
+		fmt.Printf("(newest %d lines only; narrow --since for the full picture)\n", explainMaxLines)
+	}
+	return nil
 }
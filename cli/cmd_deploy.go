@@ -10,42 +10,185 @@ import (
 func deployCommands() []Command {
 	return []Command{
 		{Path: []string{"deploy", "wait"}, Tier: TierRead,
-			Summary: "wait for <ns>/<deploy> to roll out the current (or --sha) image: deploy wait <ns>/<deploy> [--sha SHA]", Run: deployWait},
+			Summary: "wait for workloads to roll out the current (or --sha) image: deploy wait <ns>/<deploy>|<ns>/<sts|ds>/<name>|<ns> -l SEL [--sha SHA] [--timeout 10m]", Run: deployWait},
 	}
 }
 
+// deployWaitDefaultTimeout bounds the whole wait (image match + rollout) when
+// --timeout is not given.
+const deployWaitDefaultTimeout = 10 * time.Minute
+
+const deployWaitUsage = "usage: homelab deploy wait <ns>/<deploy> | <ns>/<sts|ds|deploy>/<name> | <ns> -l <selector> [--sha SHA] [--timeout 10m]"
+
+// deployWaitMaxReported caps how many stuck pods get their events printed, so a
+// wide DaemonSet failure stays readable.
+const deployWaitMaxReported = 5
+
 // deployWait closes the "did the NEW code land" gap: rollout status alone returns
-// success on the OLD ReplicaSet, so we first wait for the deployment image to
-// reference the expected sha, THEN block on rollout status.
+// success on the OLD ReplicaSet, so we first wait for every targeted workload's
+// images to reference the expected sha, THEN block on rollout status — all
+// within one --timeout budget. On failure it reports the pods still on the old
+// revision, with their events.
 func deployWait(args []string) error {
-	target, _ := firstPositional(args)
-	if target == "" || !strings.Contains(target, "/") {
-		return fmt.Errorf("usage: homelab deploy wait <ns>/<deploy> [--sha SHA] [--timeout 10m]")
+	selector := flagValue(args, "-l")
+	if selector == "" {
+		selector = flagValue(args, "--selector")
+	}
+	pos := positionalsSkipping(args, map[string]bool{"--sha": true, "--timeout": true, "-l": true, "--selector": true})
+	if len(pos) == 0 {
+		return fmt.Errorf(deployWaitUsage)
+	}
+	t, err := parseDeployTarget(pos[0], selector)
+	if err != nil {
+		return fmt.Errorf("%v\n%s", err, deployWaitUsage)
 	}
-	parts := strings.SplitN(target, "/", 2)
-	ns, deploy := parts[0], parts[1]
+	timeout, err := durationFlag(args, "--timeout", deployWaitDefaultTimeout)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
 
 	sha := flagValue(args, "--sha")
 	if sha == "" {
 		sha = short(currentHEAD())
 	}
-	deadline := time.Now().Add(10 * time.Minute)
 
+	workloads, err := fetchWorkloads(t)
+	if err != nil {
+		return err
+	}
 	if sha != "" {
-		fmt.Fprintf(os.Stderr, "homelab: waiting for %s/%s image to match %s...\n", ns, deploy, sha)
-		matched := false
-		for time.Now().Before(deadline) {
-			img, _ := kubectlCapture(ns, "get", "deploy", deploy, "-o", "jsonpath={.spec.template.spec.containers[*].image}")
-			if strings.Contains(img, sha) {
-				matched = true
-				break
+		if workloads, err = waitForImages(t, sha, deadline, timeout); err != nil {
+			return err
+		}
+	}
+	for _, w := range workloads {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			reportStuckPods(w)
+			return fmt.Errorf("timed out after %s before %s finished rolling out", timeout, w.ref())
+		}
+		fmt.Fprintf(os.Stderr, "homelab: rollout status %s...\n", w.ref())
+		if err := kubectlStream(w.Metadata.Namespace, "rollout", "status", w.ref().objectRef(),
+			fmt.Sprintf("--timeout=%ds", int(remaining.Seconds())+1)); err != nil {
+			reportStuckPods(w)
+			return fmt.Errorf("%s did not finish rolling out: %w", w.ref(), err)
+		}
+	}
+	return nil
+}
+
+// fetchWorkloads reads the target's workload object(s) from the cluster.
+func fetchWorkloads(t deployTarget) ([]workloadObject, error) {
+	var out string
+	var err error
+	if t.selector != "" {
+		out, err = kubectlCapture(t.ns, "get", "deployment,statefulset,daemonset", "-l", t.selector, "-o", "json")
+	} else {
+		out, err = kubectlCapture(t.ns, "get", t.one.kind, t.one.name, "-o", "json")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read workloads in %s: %w", t.ns, err)
+	}
+	ws, err := parseWorkloads(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(ws) == 0 {
+		return nil, fmt.Errorf("no deployment/statefulset/daemonset in %s matches %q", t.ns, t.selector)
+	}
+	return ws, nil
+}
+
+// waitForImages polls until every workload's images carry sha (see
+// imagesMatchSHA), returning the final objects.
+func waitForImages(t deployTarget, sha string, deadline time.Time, timeout time.Duration) ([]workloadObject, error) {
+	matched := map[string]bool{}
+	announced := false
+	var last []workloadObject
+	var pending []string
+	for {
+		if ws, err := fetchWorkloads(t); err == nil {
+			last, pending = ws, nil
+			for _, w := range ws {
+				name := w.ref().String()
+				ok, stale := imagesMatchSHA(w.Spec.Template.Spec.images(), sha)
+				switch {
+				case ok:
+					if !matched[name] {
+						fmt.Fprintf(os.Stderr, "homelab: %s image matches %s\n", name, sha)
+						matched[name] = true
+					}
+				case len(stale) > 0:
+					pending = append(pending, fmt.Sprintf("%s (still on %s)", name, strings.Join(stale, ", ")))
+				default:
+					pending = append(pending, name)
+				}
+			}
+			if len(pending) == 0 {
+				return ws, nil
+			}
+			if !announced {
+				fmt.Fprintf(os.Stderr, "homelab: waiting for %d workload(s) in %s to reference %s...\n", len(pending), t.ns, sha)
+				announced = true
 			}
-			time.Sleep(10 * time.Second)
 		}
-		if !matched {
-			return fmt.Errorf("timed out: %s/%s image never matched %q", ns, deploy, sha)
+		if time.Now().Add(10 * time.Second).After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Second)
+	}
+	for _, w := range last {
+		if ok, _ := imagesMatchSHA(w.Spec.Template.Spec.images(), sha); !ok {
+			reportStuckPods(w)
+		}
+	}
+	return nil, fmt.Errorf("timed out after %s: image never matched %q for %s", timeout, sha, strings.Join(pending, "; "))
+}
+
+// reportStuckPods prints, to stderr, the workload's pods that are not on its
+// current revision (or not Ready), each with its recent events. Best-effort:
+// it is diagnostics on an already-failing path, so lookup errors are skipped.
+func reportStuckPods(w workloadObject) {
+	ns := w.Metadata.Namespace
+	sel := selectorString(w.Spec.Selector.MatchLabels)
+	if sel == "" {
+		return
+	}
+	podsJSON, err := kubectlCapture(ns, "get", "pods", "-l", sel, "-o", "json")
+	if err != nil {
+		return
+	}
+	pods, err := parsePods(podsJSON)
+	if err != nil {
+		return
+	}
+	current := ""
+	if strings.ToLower(w.Kind) == "deployment" {
+		if rsJSON, err := kubectlCapture(ns, "get", "rs", "-l", sel, "-o", "json"); err == nil {
+			current, _ = newestReplicaSet(rsJSON)
+		}
+	}
+	stuck := findStuckPods(w, pods, current)
+	if len(stuck) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n=== %s: %d pod(s) not on the new revision ===\n", w.ref(), len(stuck))
+	for i, p := range stuck {
+		fmt.Fprintf(os.Stderr, "  %s — %s\n", p.name, p.reason)
+		if i >= deployWaitMaxReported {
+			continue
+		}
+		ev, err := kubectlCapture(ns, "get", "events", "--field-selector", "involvedObject.name="+p.name,
+			"--sort-by=.lastTimestamp", "-o", "custom-columns=AGE:.lastTimestamp,TYPE:.type,REASON:.reason,MESSAGE:.message", "--no-headers")
+		if err != nil || ev == "" {
+			continue
 		}
+		for _, line := range tailLines(strings.Split(ev, "\n"), 8) {
+			fmt.Fprintf(os.Stderr, "      %s\n", line)
+		}
+	}
+	if len(stuck) > deployWaitMaxReported {
+		fmt.Fprintf(os.Stderr, "  (events shown for the first %d only)\n", deployWaitMaxReported)
 	}
-	fmt.Fprintf(os.Stderr, "homelab: rollout status %s/%s...\n", ns, deploy)
-	return kubectlStream(ns, "rollout", "status", "deploy/"+deploy, "--timeout=180s")
 }
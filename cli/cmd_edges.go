@@ -1,12 +1,33 @@
 package main
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
 
 func edgesCommands() []Command {
 	return []Command{
 		{Path: []string{"edges"}, Tier: TierRead,
 			Summary: "who-talks-to-whom trail: edges [--ns|--src|--dst|--peers-of N] [--new-since 24h] [--denied] [--json] [--limit N]",
 			Run:     edgesRun},
+		{Path: []string{"edges", "baseline", "save"}, Tier: TierWrite,
+			Summary: "write the approved edge set to the checked-in baseline: edges baseline save [--window 7d] [--file F]",
+			Run:     edgesBaselineSave},
+		{Path: []string{"edges", "diff"}, Tier: TierRead,
+			Summary: "new peers / newly denied / vanished edges vs the baseline; non-zero on new egress: edges diff [--ns N] [--window 7d] [--file F] [--json]",
+			Run:     edgesDiffRun},
+		{Path: []string{"edges", "graph"}, Tier: TierRead,
+			Summary: "namespace dependency graph (denied edges dashed red, sized by flow_count): edges graph [--ns N [--depth 2]] [--window 7d] --format dot|mermaid|svg",
+			Run:     edgesGraph},
+		{Path: []string{"edges", "suggest-policy"}, Tier: TierRead,
+			Summary: "draft a NetworkPolicy allowing exactly the observed peers: edges suggest-policy <ns> [--min-flows 10] [--new-since 30d] [--format yaml|tf]",
+			Run:     edgesSuggestPolicy},
 	}
 }
 
@@ -27,11 +48,9 @@ func edgesRun(args []string) error {
 	if err != nil {
 		return err
 	}
-	// pg-cluster-rw is a Service (not exec-able); resolve the primary POD.
-	pod, err := kubectlCapture("dbaas", "get", "pod", "-l", "cnpg.io/instanceRole=primary",
-		"-o", "jsonpath={.items[0].metadata.name}")
-	if err != nil || pod == "" {
-		return fmt.Errorf("could not resolve CNPG primary pod in dbaas: %v", err)
+	pod, err := edgesPrimaryPod()
+	if err != nil {
+		return err
 	}
 	exec := []string{"exec", pod, "-c", "postgres", "--", "psql", "-U", "postgres", "-d", "goldmane_edges"}
 	if o.asJSON {
@@ -42,6 +61,243 @@ func edgesRun(args []string) error {
 	return kubectlStream("dbaas", exec...)
 }
 
+// edgesPrimaryPod resolves the CNPG primary POD — pg-cluster-rw is a Service
+// (not exec-able).
+func edgesPrimaryPod() (string, error) {
+	pod, err := kubectlCapture("dbaas", "get", "pod", "-l", "cnpg.io/instanceRole=primary",
+		"-o", "jsonpath={.items[0].metadata.name}")
+	if err != nil || pod == "" {
+		return "", fmt.Errorf("could not resolve CNPG primary pod in dbaas: %v", err)
+	}
+	return pod, nil
+}
+
+// edgesPSQL runs sql against goldmane_edges and returns the raw tuple output.
+func edgesPSQL(sql string) (string, error) {
+	pod, err := edgesPrimaryPod()
+	if err != nil {
+		return "", err
+	}
+	out, err := kubectlCapture("dbaas", "exec", pod, "-c", "postgres", "--",
+		"psql", "-U", "postgres", "-d", "goldmane_edges", "-tAc", sql)
+	if err != nil {
+		return "", fmt.Errorf("goldmane_edges query failed: %v", err)
+	}
+	return out, nil
+}
+
+// edgesBaselinePath is --file, or edgesBaselineFile under the infra checkout.
+func edgesBaselinePath(args []string) (string, error) {
+	if f := flagValue(args, "--file"); f != "" {
+		return f, nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	root, err := findInfraRoot(cwd)
+	if err != nil {
+		return "", fmt.Errorf("%w (or pass --file)", err)
+	}
+	return filepath.Join(root, edgesBaselineFile), nil
+}
+
+// currentEdgeSet reads the distinct edges seen within --window.
+func currentEdgeSet(args []string) ([]baselineEdge, string, error) {
+	window := flagValue(args, "--window")
+	if window == "" {
+		window = edgesDefaultWindow
+	}
+	sql, err := edgeSetQuery(window)
+	if err != nil {
+		return nil, "", err
+	}
+	out, err := edgesPSQL(sql)
+	if err != nil {
+		return nil, "", err
+	}
+	edges, err := parseEdgeSet(out)
+	return edges, window, err
+}
+
+// edgesBaselineSave records the current edge set as approved. Commit the file
+// alongside the NetworkPolicy change it reflects.
+func edgesBaselineSave(args []string) error {
+	path, err := edgesBaselinePath(args)
+	if err != nil {
+		return err
+	}
+	edges, window, err := currentEdgeSet(args)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(edgesBaseline{SavedAt: time.Now().UTC().Truncate(time.Second), Window: window, Edges: edges}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("saved %d edges (seen within %s) to %s — commit it with the policy change\n", len(edges), window, path)
+	return nil
+}
+
+// edgesDiffRun compares the current edge set to the baseline. New allowed
+// edges are unexpected egress and fail the command, so CI can gate on it;
+// newly denied and vanished edges are reported but do not fail.
+func edgesDiffRun(args []string) error {
+	ns := flagValue(args, "--ns")
+	if ns != "" {
+		if err := validateNS(ns); err != nil {
+			return err
+		}
+	}
+	path, err := edgesBaselinePath(args)
+	if err != nil {
+		return err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("no baseline at %s (run `homelab edges baseline save`): %w", path, err)
+	}
+	var base edgesBaseline
+	if err := json.Unmarshal(raw, &base); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	cur, _, err := currentEdgeSet(args)
+	if err != nil {
+		return err
+	}
+	d := diffEdges(base.Edges, cur, ns)
+	if containsArg(args, "--json") {
+		b, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		fmt.Print(formatEdgesDiff(d))
+	}
+	if n := len(d.NewPeers); n > 0 {
+		return fmt.Errorf("%d unexpected new egress edge(s) since the baseline of %s; if intended, run `homelab edges baseline save` and commit it",
+			n, base.SavedAt.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// edgesGraph renders the edge table as a directed namespace graph. svg goes
+// through Graphviz (`dot` must be installed); dot and mermaid are text, the
+// latter embeddable in a markdown doc for `pages publish`.
+func edgesGraph(args []string) error {
+	format := flagValue(args, "--format")
+	if format == "" {
+		format = "dot"
+	}
+	if format != "dot" && format != "mermaid" && format != "svg" {
+		return fmt.Errorf("--format must be dot, mermaid or svg, not %q", format)
+	}
+	ns := flagValue(args, "--ns")
+	if ns != "" {
+		if err := validateNS(ns); err != nil {
+			return err
+		}
+	}
+	depth := 2
+	if v := flagValue(args, "--depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("bad --depth %q: want a positive number of hops", v)
+		}
+		depth = n
+	}
+	dotBin := ""
+	if format == "svg" {
+		p, err := exec.LookPath("dot")
+		if err != nil {
+			return fmt.Errorf("--format svg needs Graphviz `dot` on PATH; use --format dot and render it elsewhere")
+		}
+		dotBin = p
+	}
+	sql, err := edgeGraphQuery(flagValue(args, "--window"))
+	if err != nil {
+		return err
+	}
+	out, err := edgesPSQL(sql)
+	if err != nil {
+		return err
+	}
+	edges, err := parseGraphEdges(out)
+	if err != nil {
+		return err
+	}
+	if ns != "" {
+		edges = neighbourhood(edges, ns, depth)
+		if len(edges) == 0 {
+			return fmt.Errorf("no edges touch %s", ns)
+		}
+	}
+	switch format {
+	case "mermaid":
+		fmt.Print(renderMermaid(edges, ns))
+	case "dot":
+		fmt.Print(renderDOT(edges, ns))
+	case "svg":
+		cmd := exec.Command(dotBin, "-Tsvg")
+		cmd.Stdin = bytes.NewReader([]byte(renderDOT(edges, ns)))
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		return cmd.Run()
+	}
+	return nil
+}
+
+// edgesSuggestPolicy prints a NetworkPolicy draft for a namespace from its
+// observed allowed edges. It only prints; review and apply it through the
+// namespace's stack.
+func edgesSuggestPolicy(args []string) error {
+	const usage = "usage: homelab edges suggest-policy <ns> [--min-flows 10] [--new-since 30d] [--format yaml|tf]"
+	pos := positionalsSkipping(args, map[string]bool{"--min-flows": true, "--new-since": true, "--format": true})
+	if len(pos) != 1 {
+		return fmt.Errorf("%s", usage)
+	}
+	ns := pos[0]
+	format := flagValue(args, "--format")
+	if format == "" {
+		format = "yaml"
+	}
+	if format != "yaml" && format != "tf" {
+		return fmt.Errorf("--format must be yaml or tf, not %q", format)
+	}
+	minFlows := policyDefaultMinFlows
+	if v := flagValue(args, "--min-flows"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("bad --min-flows %q: want a flow count", v)
+		}
+		minFlows = n
+	}
+	sql, err := policyQuery(ns, flagValue(args, "--new-since"))
+	if err != nil {
+		return err
+	}
+	out, err := edgesPSQL(sql)
+	if err != nil {
+		return err
+	}
+	d, err := buildPolicyDraft(ns, out)
+	if err != nil {
+		return err
+	}
+	if len(d.Ingress)+len(d.Egress) == 0 {
+		return fmt.Errorf("no allowed edges touch %s in the trail; nothing to base a policy on", ns)
+	}
+	if format == "tf" {
+		fmt.Print(renderPolicyTF(d, minFlows))
+	} else {
+		fmt.Print(renderPolicyYAML(d, minFlows))
+	}
+	return nil
+}
+
 func edgesUsage() string {
 	return `homelab edges — query the who-talks-to-whom trail (goldmane_edges, ADR-0014)
 
@@ -64,6 +320,23 @@ Examples:
   homelab edges --new-since 24h            # edges first seen in the last day
   homelab edges --denied --json            # blocked flows, machine-readable
 
+Baseline (the approved edge set, checked in at stacks/goldmane-edge-aggregator/edges-baseline.json):
+  homelab edges baseline save [--window 7d] [--file F]   record edges seen within the window
+  homelab edges diff [--ns N] [--window 7d] [--file F] [--json]
+                    new peers, newly denied and vanished edges per namespace;
+                    exits non-zero on new (allowed) egress, for CI
+
+Graph:
+  homelab edges graph [--ns N [--depth 2]] [--window 7d] --format dot|mermaid|svg
+                    directed namespace graph; denied edges dashed red, nodes and
+                    edges sized by flow_count (svg needs Graphviz dot)
+
+Policy draft:
+  homelab edges suggest-policy NS [--min-flows 10] [--new-since 30d] [--format yaml|tf]
+                    NetworkPolicy (YAML, or Terraform for the stack) allowing
+                    exactly NS's observed peers; peers under --min-flows or not
+                    seen since --new-since are commented out for review
+
 Read-only SELECT against CNPG DB goldmane_edges via the dbaas primary pod.
 `
 }
@@ -1,17 +1,31 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 func netCommands() []Command {
 	return []Command{
 		{Path: []string{"net", "check"}, Tier: TierRead,
-			Summary: "reachability of <host>[/path]: external (public DNS→CF) vs internal (Traefik LB)", Run: netCheck},
+			Summary: "reachability + TLS cert of <host>[/path]: external (public DNS→CF) vs internal (Traefik LB)", Run: netCheck},
+		{Path: []string{"net", "watch"}, Tier: TierRead,
+			Summary: "probe <host>[/path] repeatedly on both legs: rolling p50/p95, status counts, flaps; non-zero above --max-error-rate: net watch <host> [--interval 5s] [--for 10m|--count N]", Run: netWatch},
+		{Path: []string{"net", "certs"}, Tier: TierRead,
+			Summary: "sweep every services-catalog host's cert, list those expiring within N days: net certs [--days 30] [--json]", Run: netCerts},
 		{Path: []string{"dns", "lookup"}, Tier: TierRead,
 			Summary: "resolve <name> via Technitium (10.0.20.201) and public (1.1.1.1), diffed", Run: dnsLookup},
+		{Path: []string{"dns", "audit"}, Tier: TierRead,
+			Summary: "resolve names (default: every services-catalog host) on public, Technitium, pfSense and OpenWRT resolvers; flag mismatches/missing/split-horizon surprises: dns audit [name...] [--json]", Run: dnsAudit},
 	}
 }
 
@@ -38,38 +52,356 @@ func netCheck(args []string) error {
 	fmt.Printf("%s\n", u)
 
 	// external leg: resolve via public DNS, dial the public IP (tests the real CF path)
-	pubOut, _ := dig(hostOnly(host), "1.1.1.1", "")
-	if pubIP := firstLine(pubOut); pubIP != "" {
+	if pubIP := firstIPv4(hostOnly(host), "1.1.1.1"); pubIP != "" {
 		c, d, e := probeURL(clientDialingIP(pubIP, 10*time.Second), u)
 		fmt.Printf("  external (public %-15s) %s\n", pubIP, fmtProbe(c, d, e))
+		fmt.Printf("    cert: %s\n", certLeg(pubIP, hostOnly(host)))
 	} else {
 		fmt.Println("  external (public)            no public A record")
 	}
 	// internal leg: dial the Traefik LB directly
 	c, d, e := probeURL(clientDialingIP(internalLBIP, 10*time.Second), u)
 	fmt.Printf("  internal (LB %-15s)     %s\n", internalLBIP, fmtProbe(c, d, e))
+	fmt.Printf("    cert: %s\n", certLeg(internalLBIP, hostOnly(host)))
+	return nil
+}
+
+const (
+	netWatchDefaultInterval  = 5 * time.Second
+	netWatchDefaultErrorRate = 0.05
+	// netWatchWindow is how many recent answered probes the p50/p95 cover, so
+	// the percentiles follow an incident instead of averaging it away.
+	netWatchWindow = 60
+)
+
+// netWatch is `net check` on a loop, for watching an incident or a rollout:
+// every --interval both legs are probed and one line per leg printed; on
+// Ctrl-C, --for or --count it prints a summary and fails when either leg's
+// error rate exceeds --max-error-rate — so it can gate a script.
+func netWatch(args []string) error {
+	const usage = "usage: homelab net watch <host>[/path] [--interval 5s] [--for 10m | --count N] [--max-error-rate 5%]"
+	pos := positionalsSkipping(args, map[string]bool{"--interval": true, "--for": true, "--count": true, "--max-error-rate": true})
+	if len(pos) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+	host, path := pos[0], "/"
+	if i := strings.Index(host, "/"); i >= 0 {
+		host, path = host[:i], host[i:]
+	} else if len(pos) > 1 {
+		path = "/" + strings.TrimPrefix(pos[1], "/")
+	}
+	interval, err := durationFlag(args, "--interval", netWatchDefaultInterval)
+	if err != nil {
+		return err
+	}
+	forDur, err := durationFlag(args, "--for", 0)
+	if err != nil {
+		return err
+	}
+	count := 0
+	if v := flagValue(args, "--count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil || count <= 0 {
+			return fmt.Errorf("bad --count %q: want a positive number of rounds", v)
+		}
+	}
+	maxRate := netWatchDefaultErrorRate
+	if v := flagValue(args, "--max-error-rate"); v != "" {
+		if maxRate, err = parsePercent(v); err != nil {
+			return err
+		}
+	}
+
+	u := "https://" + host + path
+	type leg struct {
+		name  string
+		c     *http.Client
+		stats *legStats
+	}
+	var legs []leg
+	// resolve the public IP once: a watch is about the path, not DNS churn
+	if pubIP := firstIPv4(host, "1.1.1.1"); pubIP != "" {
+		legs = append(legs, leg{"external", clientDialingIP(pubIP, 10*time.Second), newLegStats(netWatchWindow)})
+	} else {
+		fmt.Println("external: no public A record — watching the internal leg only")
+	}
+	legs = append(legs, leg{"internal", clientDialingIP(internalLBIP, 10*time.Second), newLegStats(netWatchWindow)})
+	fmt.Printf("watching %s every %s (Ctrl-C to stop)\n", u, interval)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	var deadline <-chan time.Time
+	if forDur > 0 {
+		deadline = time.After(forDur)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+loop:
+	for round := 1; ; round++ {
+		for _, l := range legs {
+			code, d, err := probeURL(l.c, u)
+			l.stats.add(code, d, err)
+			fmt.Printf("%s %-8s %-22s | %s\n", time.Now().Format("15:04:05"), l.name, fmtProbe(code, d, err), l.stats.summary())
+		}
+		if count > 0 && round >= count {
+			break
+		}
+		select {
+		case <-sig:
+			break loop
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+		}
+	}
+
+	fmt.Println("summary:")
+	var failing []string
+	for _, l := range legs {
+		fmt.Printf("  %-8s %s\n", l.name, l.stats.summary())
+		if l.stats.errorRate() > maxRate {
+			failing = append(failing, fmt.Sprintf("%s %.1f%%", l.name, 100*l.stats.errorRate()))
+		}
+	}
+	if len(failing) > 0 {
+		return fmt.Errorf("error rate above %.1f%%: %s", 100*maxRate, strings.Join(failing, ", "))
+	}
+	return nil
+}
+
+// catalogHosts is every distinct host in the services catalog, sorted — the
+// default target set for the fleet-wide sweeps.
+func catalogHosts() ([]string, error) {
+	svcs, err := ingressServices()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var hosts []string
+	for _, s := range svcs {
+		if !seen[s.Host] {
+			seen[s.Host] = true
+			hosts = append(hosts, s.Host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+// certLeg fetches and judges the cert ip serves for host, as one line.
+func certLeg(ip, host string) string {
+	chain, err := fetchCertChain(ip, host, 8*time.Second)
+	if err != nil {
+		return "ERR " + err.Error()
+	}
+	return fmtCert(inspectCert(chain, host, time.Now(), nil), time.Now())
+}
+
+// netCertsDefaultDays is the `net certs` window: comfortably longer than
+// cert-manager's renew-before, so a renewal that silently stopped shows up
+// while there is still time to fix it.
+const netCertsDefaultDays = 30
+
+// netCertsWorkers bounds the concurrent handshakes against the LB.
+const netCertsWorkers = 8
+
+// netCerts checks the cert every catalog host serves through the internal LB
+// (every ingress routes through it, and cert-manager's certs live there) and
+// exits non-zero when any expires within --days or any host has a bad chain,
+// a SAN mismatch or a failed handshake.
+func netCerts(args []string) error {
+	days := netCertsDefaultDays
+	if v := flagValue(args, "--days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("bad --days %q: want a whole number of days", v)
+		}
+		days = n
+	}
+	hosts, err := catalogHosts()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	results := make([]hostCert, len(hosts))
+	sem := make(chan struct{}, netCertsWorkers)
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			hc := hostCert{Host: h}
+			if chain, err := fetchCertChain(internalLBIP, h, 8*time.Second); err != nil {
+				hc.Err = err.Error()
+			} else {
+				r := inspectCert(chain, h, now, nil)
+				hc.Cert = &r
+			}
+			results[i] = hc
+		}(i, h)
+	}
+	wg.Wait()
+
+	report, expiring, broken := formatCertSweep(results, days, now)
+	if containsArg(args, "--json") {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		fmt.Print(report)
+	}
+	var bad []string
+	if expiring > 0 {
+		bad = append(bad, fmt.Sprintf("%d certificate(s) expire within %dd", expiring, days))
+	}
+	if broken > 0 {
+		bad = append(bad, fmt.Sprintf("%d host(s) with a chain, SAN or handshake problem", broken))
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("%s", strings.Join(bad, "; "))
+	}
 	return nil
 }
 
 func dnsLookup(args []string) error {
-	name, rest := firstPositional(args)
-	if name == "" {
-		return fmt.Errorf("usage: homelab dns lookup <name> [A|AAAA|TXT|MX|PTR]")
-	}
-	rr := ""
-	if len(rest) > 0 {
-		rr = rest[0]
-	}
-	tech, _ := dig(name, "10.0.20.201", rr)
-	pub, _ := dig(name, "1.1.1.1", rr)
-	fmt.Printf("technitium (10.0.20.201): %s\n", oneLineList(tech))
-	fmt.Printf("public     (1.1.1.1)    : %s\n", oneLineList(pub))
-	if strings.TrimSpace(tech) != strings.TrimSpace(pub) {
+	const usage = "usage: homelab dns lookup <name|ip> [A|AAAA|TXT|MX|PTR|CNAME|SRV|NS] [--server IP[:port]] [--json]"
+	pos := positionalsSkipping(args, map[string]bool{"--server": true})
+	if len(pos) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+	name, rr := pos[0], ""
+	if len(pos) > 1 {
+		rr = pos[1]
+	}
+	if _, ok := dnsTypes[strings.ToUpper(rr)]; rr != "" && !ok {
+		return fmt.Errorf("unsupported record type %q\n%s", rr, usage)
+	}
+	servers := []struct{ label, addr string }{{"technitium", "10.0.20.201"}, {"public", "1.1.1.1"}}
+	if s := flagValue(args, "--server"); s != "" {
+		servers = []struct{ label, addr string }{{"server", s}}
+	}
+	var resps []dnsResponse
+	var lines []string
+	for _, s := range servers {
+		r, err := dnsQuery(name, s.addr, rr)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%-10s (%s): ERR %v", s.label, s.addr, err))
+			resps = append(resps, dnsResponse{Server: s.addr, Name: name, RCode: "ERROR", Error: err.Error()})
+			continue
+		}
+		resps = append(resps, r)
+		lines = append(lines, fmt.Sprintf("%-10s (%s): %s", s.label, s.addr, fmtDNSAnswer(r)))
+	}
+	if containsArg(args, "--json") {
+		b, err := json.MarshalIndent(resps, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+	if len(resps) == 2 && !sameAnswers(resps[0], resps[1]) {
 		fmt.Println("⚠ mismatch — split-horizon (expected for internal-only apps) or a propagation gap")
 	}
 	return nil
 }
 
+// fmtDNSAnswer is one resolver's answer on a line: the values, or the rcode
+// when there are none, plus the lowest TTL and any AA/AD flags.
+func fmtDNSAnswer(r dnsResponse) string {
+	if len(r.Answers) == 0 {
+		if r.RCode == "NOERROR" {
+			return "(none)"
+		}
+		return "(none, " + r.RCode + ")"
+	}
+	ttl := r.Answers[0].TTL
+	for _, a := range r.Answers {
+		if a.TTL < ttl {
+			ttl = a.TTL
+		}
+	}
+	out := fmt.Sprintf("%s  ttl=%ds", strings.Join(r.values(), ", "), ttl)
+	if r.Authoritative {
+		out += " aa"
+	}
+	if r.AuthenticData {
+		out += " ad"
+	}
+	return out
+}
+
+// dnsAuditWorkers bounds how many names are audited at once (each asks every
+// resolver in turn).
+const dnsAuditWorkers = 8
+
+// dnsAudit resolves each name on every auditResolvers vantage point and flags
+// the answers no split-horizon design intends (see auditName). With no names
+// it sweeps the services catalog: since the wildcard consolidation (ADR-0021)
+// a missing or stale record no longer fails loudly, it just routes somewhere
+// else. Exits non-zero when anything is flagged.
+func dnsAudit(args []string) error {
+	names := positionalsSkipping(args, nil)
+	if len(names) == 0 {
+		hosts, err := catalogHosts()
+		if err != nil {
+			return fmt.Errorf("%w (or name hosts explicitly: homelab dns audit <name>...)", err)
+		}
+		names = hosts
+	}
+	audits := make([]nameAudit, len(names))
+	sem := make(chan struct{}, dnsAuditWorkers)
+	var wg sync.WaitGroup
+	for i, n := range names {
+		wg.Add(1)
+		go func(i int, n string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			var answers []resolverAnswer
+			for _, r := range auditResolvers {
+				ans := resolverAnswer{Resolver: r}
+				if resp, err := dnsQuery(n, r.Addr, "A"); err != nil {
+					ans.Err = err.Error()
+				} else {
+					ans.IPs = answerIPs(resp)
+				}
+				answers = append(answers, ans)
+			}
+			audits[i] = auditName(n, answers)
+		}(i, n)
+	}
+	wg.Wait()
+
+	flagged := 0
+	for _, a := range audits {
+		if len(a.Findings) > 0 {
+			flagged++
+		}
+	}
+	if containsArg(args, "--json") {
+		b, err := json.MarshalIndent(audits, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		fmt.Print(formatDNSAudit(audits))
+	}
+	if flagged > 0 {
+		return fmt.Errorf("%d of %d names flagged", flagged, len(audits))
+	}
+	return nil
+}
+
 func hostOnly(h string) string { // strip any path accidentally included
 	return strings.SplitN(h, "/", 2)[0]
 }
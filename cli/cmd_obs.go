@@ -1,14 +1,20 @@
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	"golang.org/x/net/websocket"
 )
 
 const (
@@ -19,11 +25,13 @@ const (
 func obsCommands() []Command {
 	return []Command{
 		{Path: []string{"metrics", "query"}, Tier: TierRead,
-			Summary: `Prometheus instant query: metrics query "<promql>" [--json]`, Run: metricsQuery},
+			Summary: `Prometheus query — instant, or --range 6h [--step 1m] as sparklines + min/max/last (--csv to export): metrics query "<promql>" [--range D] [--step D] [--csv|--json]`, Run: metricsQuery},
 		{Path: []string{"metrics", "alerts"}, Tier: TierRead,
 			Summary: "list currently firing Prometheus alerts", Run: metricsAlerts},
 		{Path: []string{"logs", "query"}, Tier: TierRead,
-			Summary: `Loki query (last --since, default 1h): logs query "<logql>" [--since 1h] [--limit N] [--json]`, Run: logsQuery},
+			Summary: `Loki query (last --since, default 1h; --all pages past --limit; --patterns groups lines into templates): logs query "<logql>" [--since 1h] [--limit N] [--all [--max-lines N] | --patterns [--compare 24h]] [--json]`, Run: logsQuery},
+		{Path: []string{"logs", "tail"}, Tier: TierRead,
+			Summary: `follow a LogQL stream live, reconnecting on drops: logs tail "<logql>" [--since 10m] [--limit N] [--grep RE]`, Run: logsTail},
 	}
 }
 
@@ -58,9 +66,12 @@ func labelStr(m map[string]string) string {
 }
 
 func metricsQuery(args []string) error {
-	q := queryArg(args, nil)
+	q := queryArg(args, map[string]bool{"--range": true, "--step": true})
 	if q == "" {
-		return fmt.Errorf(`usage: homelab metrics query "<promql>" [--json]`)
+		return fmt.Errorf(`usage: homelab metrics query "<promql>" [--range 6h [--step 1m]] [--csv|--json]`)
+	}
+	if flagValue(args, "--range") != "" {
+		return metricsRange(q, args)
 	}
 	v := url.Values{}
 	v.Set("query", q)
@@ -98,6 +109,51 @@ func metricsQuery(args []string) error {
 	return nil
 }
 
+// metricsRange runs q over the last --range at --step via query_range and
+// prints a sparkline with min/max/last per series — a trend at a glance
+// without Grafana, and a compact numeric summary instead of raw JSON. --csv
+// exports every sample; --json passes the response through.
+func metricsRange(q string, args []string) error {
+	rng, err := parsePromDuration(flagValue(args, "--range"))
+	if err != nil {
+		return fmt.Errorf("--range: %w", err)
+	}
+	step := defaultStep(rng)
+	if s := flagValue(args, "--step"); s != "" {
+		if step, err = parsePromDuration(s); err != nil {
+			return fmt.Errorf("--step: %w", err)
+		}
+	}
+	if n := int(rng / step); n > promMaxPoints {
+		return fmt.Errorf("--range %s at --step %s is %d points per series; Prometheus allows %d — raise --step", rng, step, n, promMaxPoints)
+	}
+	end := time.Now()
+	start := end.Add(-rng)
+	v := url.Values{}
+	v.Set("query", q)
+	v.Set("start", strconv.FormatInt(start.Unix(), 10))
+	v.Set("end", strconv.FormatInt(end.Unix(), 10))
+	v.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+	body, err := lbGetBody(promHost, "/api/v1/query_range", v)
+	if err != nil {
+		return err
+	}
+	if containsArg(args, "--json") {
+		fmt.Println(string(body))
+		return nil
+	}
+	series, err := parseMatrix(body)
+	if err != nil {
+		return err
+	}
+	if containsArg(args, "--csv") {
+		return writeRangeCSV(os.Stdout, series)
+	}
+	fmt.Printf("%s → %s, step %s\n", start.Format("01-02 15:04"), end.Format("01-02 15:04"), step)
+	fmt.Print(formatRange(series, start, end, sparkWidth))
+	return nil
+}
+
 func metricsAlerts(args []string) error {
 	// prometheus-query is a query-only frontend (no /api/v1/alerts); the firing
 	// set is exposed as the synthetic ALERTS series, queryable the normal way.
@@ -157,16 +213,22 @@ func truncationNote(limit, n int, minNs, maxNs int64, since time.Duration) strin
 	covered := time.Duration(maxNs-minNs) * time.Nanosecond
 	return fmt.Sprintf(
 		"note: --limit %d was reached, so these lines cover only %s of the %s requested "+
-			"(oldest %s). Older lines were NOT returned. Raise --limit, narrow the stream "+
+			"(oldest %s). Older lines were NOT returned. Use --all to page through the window, raise --limit, narrow the stream "+
 			"selector, or add line filters (!= \"/health\") to reach further back.",
 		limit, covered.Round(time.Second), since,
 		time.Unix(0, minNs).Format("15:04:05"))
 }
 
 func logsQuery(args []string) error {
-	q := queryArg(args, map[string]bool{"--since": true, "--limit": true})
+	q := queryArg(args, map[string]bool{"--since": true, "--limit": true, "--max-lines": true, "--compare": true, "--top": true})
 	if q == "" {
-		return fmt.Errorf(`usage: homelab logs query "<logql>" [--since 1h] [--limit N] [--json]`)
+		return fmt.Errorf(`usage: homelab logs query "<logql>" [--since 1h] [--limit N] [--all [--max-lines N] | --patterns [--compare 24h] [--top N]] [--json]`)
+	}
+	if containsArg(args, "--patterns") {
+		return logsPatterns(q, args)
+	}
+	if containsArg(args, "--all") {
+		return logsQueryAll(q, args)
 	}
 	since := flagValue(args, "--since")
 	if since == "" {
@@ -236,3 +298,313 @@ func logsQuery(args []string) error {
 	}
 	return nil
 }
+
+// lokiTailDial opens the /loki/api/v1/tail websocket through the internal LB,
+// keeping lokiHost as SNI and Host the way clientDialingIP does for HTTP.
+// TCP keepalives are what notice a silently dropped connection: a quiet stream
+// sends nothing, so a read error is the only signal to reconnect on.
+func lokiTailDial(q string, start time.Time, limit int) (*websocket.Conn, error) {
+	v := url.Values{}
+	v.Set("query", q)
+	v.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	v.Set("limit", strconv.Itoa(limit))
+	cfg, err := websocket.NewConfig("wss://"+lokiHost+"/loki/api/v1/tail?"+v.Encode(), "https://"+lokiHost)
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: 8 * time.Second, KeepAlive: 15 * time.Second}
+	conn, err := tls.DialWithDialer(d, "tcp", internalLBIP+":443", &tls.Config{ServerName: lokiHost, InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	ws, err := websocket.NewClient(cfg, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("tail handshake: %w", err)
+	}
+	return ws, nil
+}
+
+// logsTail follows a LogQL query over Loki's tail websocket. --since backfills
+// that much history first (capped by --limit, as Loki caps it); on a drop it
+// reconnects with backoff, resuming from the last printed line so nothing is
+// repeated. --grep filters lines locally, after LogQL — handy for a regex the
+// stream selector cannot express cheaply. Stream tags are coloured on a
+// terminal (unless NO_COLOR is set).
+func logsTail(args []string) error {
+	const usage = `usage: homelab logs tail "<logql>" [--since 10m] [--limit N] [--grep RE]`
+	q := queryArg(args, map[string]bool{"--since": true, "--limit": true, "--grep": true})
+	if q == "" {
+		return fmt.Errorf("%s", usage)
+	}
+	start := time.Now()
+	if v := flagValue(args, "--since"); v != "" {
+		d, err := parsePromDuration(v)
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		start = start.Add(-d)
+	}
+	limit := 100
+	if v := flagValue(args, "--limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("bad --limit %q: want a positive number of backfill lines", v)
+		}
+		limit = n
+	}
+	grep, err := compileGrep(flagValue(args, "--grep"))
+	if err != nil {
+		return err
+	}
+	colour := stdoutIsTTY() && os.Getenv("NO_COLOR") == ""
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	cur := &tailCursor{}
+	var wait time.Duration
+	for attempt := 0; ; attempt++ {
+		ws, err := lokiTailDial(q, cur.resumeFrom(start), limit)
+		if err != nil {
+			if attempt == 0 {
+				return err // a bad query or unreachable Loki: say so, don't spin
+			}
+			wait = nextBackoff(wait)
+			fmt.Fprintf(os.Stderr, "homelab: logs tail: %v — reconnecting in %s\n", err, wait)
+			select {
+			case <-sig:
+				return nil
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if attempt > 0 {
+			fmt.Fprintln(os.Stderr, "homelab: logs tail: reconnected")
+		}
+		wait = 0
+
+		frames := make(chan []byte)
+		errc := make(chan error, 1)
+		done := make(chan struct{})
+		go func() {
+			for {
+				var msg []byte
+				if err := websocket.Message.Receive(ws, &msg); err != nil {
+					errc <- err
+					return
+				}
+				select {
+				case frames <- msg:
+				case <-done:
+					return
+				}
+			}
+		}()
+	read:
+		for {
+			select {
+			case <-sig:
+				close(done)
+				ws.Close()
+				return nil
+			case err := <-errc:
+				close(done)
+				ws.Close()
+				fmt.Fprintf(os.Stderr, "homelab: logs tail: connection dropped: %v\n", err)
+				break read
+			case msg := <-frames:
+				entries, dropped, err := parseTailFrame(msg)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "homelab: logs tail: %v\n", err)
+					continue
+				}
+				if dropped > 0 {
+					fmt.Fprintf(os.Stderr, "homelab: logs tail: Loki dropped %d line(s) — the stream outpaced the tail; narrow the query\n", dropped)
+				}
+				for _, e := range entries {
+					if !cur.admit(e) || (grep != nil && !grep.MatchString(e.Line)) {
+						continue
+					}
+					fmt.Println(formatTailLine(e, colour))
+				}
+			}
+		}
+	}
+}
+
+const (
+	// logsAllPage is the page size for `logs query --all` when --limit is not
+	// given: big pages mean few round trips, well under Loki's 5000 cap.
+	logsAllPage = 1000
+	// logsDefaultMaxLines bounds --all so a broad selector over a long window
+	// cannot pull the whole stream into the terminal.
+	logsDefaultMaxLines = 20000
+)
+
+// logsWindowFlags reads the flags of the whole-window modes (--all,
+// --patterns): --since, --limit as the page size, and --max-lines.
+func logsWindowFlags(args []string) (dur time.Duration, page, maxLines int, err error) {
+	since := flagValue(args, "--since")
+	if since == "" {
+		since = "1h"
+	}
+	if dur, err = parsePromDuration(since); err != nil {
+		return 0, 0, 0, fmt.Errorf("--since: %w", err)
+	}
+	page = logsAllPage
+	if v := flagValue(args, "--limit"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page <= 0 {
+			return 0, 0, 0, fmt.Errorf("bad --limit %q: want a positive page size", v)
+		}
+	}
+	maxLines = logsDefaultMaxLines
+	if v := flagValue(args, "--max-lines"); v != "" {
+		if maxLines, err = strconv.Atoi(v); err != nil || maxLines <= 0 {
+			return 0, 0, 0, fmt.Errorf("bad --max-lines %q: want a positive number", v)
+		}
+	}
+	return dur, page, maxLines, nil
+}
+
+// lokiWindow collects every line of q in [start, end) via pageBackwards,
+// returning how many query_range pages it took.
+func lokiWindow(q string, start, end time.Time, page, maxLines int) ([]logEntry, bool, int, error) {
+	pages := 0
+	fetch := func(endNs int64) ([]logEntry, error) {
+		pages++
+		v := url.Values{}
+		v.Set("query", q)
+		v.Set("limit", strconv.Itoa(page))
+		v.Set("direction", "backward")
+		v.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+		v.Set("end", strconv.FormatInt(endNs, 10))
+		body, err := lbGetBody(lokiHost, "/loki/api/v1/query_range", v)
+		if err != nil {
+			return nil, err
+		}
+		return parseStreams(body)
+	}
+	entries, complete, err := pageBackwards(fetch, start.UnixNano(), end.UnixNano(), page, maxLines)
+	return entries, complete, pages, err
+}
+
+// logsQueryAll is `logs query --all`: it pages backwards through the window
+// (see pageBackwards) and prints every line in time order, instead of the
+// newest --limit lines a single query returns.
+func logsQueryAll(q string, args []string) error {
+	dur, page, maxLines, err := logsWindowFlags(args)
+	if err != nil {
+		return err
+	}
+	end := time.Now()
+	entries, complete, pages, err := lokiWindow(q, end.Add(-dur), end, page, maxLines)
+	if err != nil {
+		return err
+	}
+	if containsArg(args, "--json") {
+		if entries == nil {
+			entries = []logEntry{}
+		}
+		b, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		for _, e := range entries {
+			fmt.Println(e.Line)
+		}
+		if len(entries) == 0 {
+			fmt.Println("(no log lines)")
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d lines in %d page(s)\n", len(entries), pages)
+	if note := windowNote(entries, complete, page, maxLines, dur); note != "" {
+		fmt.Fprintln(os.Stderr, note)
+	}
+	return nil
+}
+
+// windowNote explains an incomplete lokiWindow result; "" when complete.
+func windowNote(entries []logEntry, complete bool, page, maxLines int, dur time.Duration) string {
+	switch {
+	case complete || len(entries) == 0:
+		return ""
+	case len(entries) < maxLines:
+		return fmt.Sprintf("note: more than --limit %d lines share one timestamp, so some of them were skipped; raise --limit to page through them.", page)
+	}
+	return fmt.Sprintf("note: stopped at --max-lines %d; these lines reach back only to %s of the %s requested. "+
+		"Raise --max-lines or narrow the query to cover the rest.",
+		maxLines, time.Unix(0, entries[0].TS).Format("15:04:05"), dur)
+}
+
+// logsPatternsTop is how many templates --patterns prints by default; the
+// long tail is mostly one-offs, and the footer still counts it.
+const logsPatternsTop = 30
+
+// logsPatterns is `logs query --patterns`: the whole window (as --all) grouped
+// into templates by logTemplate, most frequent first. --compare D also reads
+// the D before the window and marks templates it never produced as NEW —
+// the one new error hiding among thousands of familiar lines.
+func logsPatterns(q string, args []string) error {
+	dur, page, maxLines, err := logsWindowFlags(args)
+	if err != nil {
+		return err
+	}
+	top := logsPatternsTop
+	if v := flagValue(args, "--top"); v != "" {
+		if top, err = strconv.Atoi(v); err != nil || top <= 0 {
+			return fmt.Errorf("bad --top %q: want a positive number", v)
+		}
+	}
+	var compare time.Duration
+	if v := flagValue(args, "--compare"); v != "" {
+		if compare, err = parsePromDuration(v); err != nil {
+			return fmt.Errorf("--compare: %w", err)
+		}
+	}
+	end := time.Now()
+	start := end.Add(-dur)
+	entries, complete, _, err := lokiWindow(q, start, end, page, maxLines)
+	if err != nil {
+		return err
+	}
+	if note := windowNote(entries, complete, page, maxLines, dur); note != "" {
+		fmt.Fprintln(os.Stderr, note)
+	}
+	var baseline map[string]bool
+	if compare > 0 {
+		prev, prevComplete, _, err := lokiWindow(q, start.Add(-compare), start, page, maxLines)
+		if err != nil {
+			return fmt.Errorf("comparison window: %w", err)
+		}
+		if !prevComplete {
+			fmt.Fprintf(os.Stderr, "note: the comparison window hit --max-lines %d, so its older part was not read; a template marked NEW may have occurred there\n", maxLines)
+		}
+		baseline = templateSet(entryLines(prev))
+	}
+	patterns := clusterLines(entryLines(entries), baseline)
+	if containsArg(args, "--json") {
+		if patterns == nil {
+			patterns = []logPattern{}
+		}
+		b, err := json.MarshalIndent(patterns, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Print(formatPatterns(patterns, len(entries), top, 160, baseline != nil))
+	return nil
+}
+
+func entryLines(entries []logEntry) []string {
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.Line
+	}
+	return lines
+}
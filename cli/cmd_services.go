@@ -15,12 +15,17 @@ func servicesCommands() []Command {
 // servicesList prints the routing table and the live service inventory. The
 // inventory is read from ingress annotations at call time rather than a stored
 // list, so it cannot drift from what is actually deployed.
-func servicesList(args []string) error {
+// ingressServices is the catalog as the cluster's Ingresses describe it.
+func ingressServices() ([]service, error) {
 	out, err := exec.Command("kubectl", "get", "ingress", "-A", "-o", "json").Output()
 	if err != nil {
-		return fmt.Errorf("cannot list ingresses (need cluster read access): %w", err)
+		return nil, fmt.Errorf("cannot list ingresses (need cluster read access): %w", err)
 	}
-	svcs, err := parseServices(string(out))
+	return parseServices(string(out))
+}
+
+func servicesList(args []string) error {
+	svcs, err := ingressServices()
 	if err != nil {
 		return err
 	}
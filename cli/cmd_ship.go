@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func shipCommands() []Command {
+	return []Command{
+		{Path: []string{"ship"}, Tier: TierWrite,
+			Summary: "land → CI → rollout → smoke-probe, stopping at the first failure: ship [--deploy <ns>/<name> ...] [--url <host/path> ...]", Run: shipRun},
+	}
+}
+
+const (
+	shipDefaultRolloutTimeout = 10 * time.Minute
+	shipDefaultSmokeTimeout   = 2 * time.Minute
+	// smokeFirstBackoff/smokeMaxBackoff shape the retry schedule: a freshly
+	// rolled pod often needs a few seconds before Traefik routes to it, so the
+	// first retries come quickly, then settle at one every 30s.
+	smokeFirstBackoff = 2 * time.Second
+	smokeMaxBackoff   = 30 * time.Second
+)
+
+// shipStage is one step of the pipeline and how it went.
+type shipStage struct {
+	name   string
+	state  string // "" (not run), "ok", "failed", "skipped"
+	detail string
+}
+
+// formatShipReport renders the stage table printed whether ship succeeds or
+// stops, so the reader sees at a glance how far the change got.
+func formatShipReport(stages []shipStage) string {
+	var b strings.Builder
+	b.WriteString("ship:\n")
+	for _, s := range stages {
+		mark, detail := "–", s.detail
+		switch s.state {
+		case "ok":
+			mark = "✓"
+		case "failed":
+			mark = "✗"
+		case "skipped":
+			mark = "·"
+		default:
+			detail = "not run"
+		}
+		fmt.Fprintf(&b, "  %s %-8s %s\n", mark, s.name, detail)
+	}
+	return b.String()
+}
+
+// nextBackoff doubles d up to smokeMaxBackoff.
+func nextBackoff(d time.Duration) time.Duration {
+	if d <= 0 {
+		return smokeFirstBackoff
+	}
+	if d*2 > smokeMaxBackoff {
+		return smokeMaxBackoff
+	}
+	return d * 2
+}
+
+// smokeURL turns a `--url` value (host, host/path, or a full URL) into a URL.
+func smokeURL(target string) string {
+	if strings.Contains(target, "://") {
+		return target
+	}
+	return "https://" + target
+}
+
+// smokeProbe GETs target through the internal LB — and through the public
+// path too, when the host has a public A record — retrying with backoff until
+// every leg answers healthily or budget runs out.
+func smokeProbe(target string, budget time.Duration) (string, error) {
+	u := smokeURL(target)
+	host := hostOnly(strings.SplitN(u, "://", 2)[1])
+	legs := map[string]*http.Client{"internal": clientDialingIP(internalLBIP, 10*time.Second)}
+	if pub := firstIPv4(host, "1.1.1.1"); pub != "" {
+		legs["external"] = clientDialingIP(pub, 10*time.Second)
+	}
+	deadline := time.Now().Add(budget)
+	var wait time.Duration
+	for attempt := 1; ; attempt++ {
+		var bad []string
+		var good []string
+		for _, leg := range []string{"internal", "external"} {
+			c, ok := legs[leg]
+			if !ok {
+				continue
+			}
+			code, d, err := probeURL(c, u)
+			if err == nil && probeHealthy(code) {
+				good = append(good, fmt.Sprintf("%s %s", leg, fmtProbe(code, d, nil)))
+			} else {
+				bad = append(bad, fmt.Sprintf("%s %s", leg, fmtProbe(code, d, err)))
+			}
+		}
+		if len(bad) == 0 {
+			return fmt.Sprintf("%s: %s (attempt %d)", u, strings.Join(good, ", "), attempt), nil
+		}
+		wait = nextBackoff(wait)
+		if time.Now().Add(wait).After(deadline) {
+			return "", fmt.Errorf("%s still failing after %d attempts over %s: %s", u, attempt, budget, strings.Join(bad, ", "))
+		}
+		fmt.Fprintf(os.Stderr, "homelab: smoke %s: %s — retrying in %s\n", u, strings.Join(bad, ", "), wait)
+		time.Sleep(wait)
+	}
+}
+
+// shipRun chains what used to be four manual steps for a change: `work land`
+// (merge master, verify, push), `ci watch` on the landed commit, `deploy wait`
+// for each --deploy target at that sha, then a smoke probe of each --url. It
+// stops at the first failing stage and always prints the stage report.
+func shipRun(args []string) error {
+	deploys := flagValues(args, "--deploy")
+	urls := flagValues(args, "--url")
+	rolloutTimeout, err := durationFlag(args, "--timeout", shipDefaultRolloutTimeout)
+	if err != nil {
+		return err
+	}
+	smokeTimeout, err := durationFlag(args, "--smoke-timeout", shipDefaultSmokeTimeout)
+	if err != nil {
+		return err
+	}
+	stages := []shipStage{{name: "land"}, {name: "ci"}, {name: "rollout"}, {name: "smoke"}}
+	stop := func(i int, err error) error {
+		stages[i].state, stages[i].detail = "failed", err.Error()
+		fmt.Print(formatShipReport(stages))
+		return fmt.Errorf("ship stopped at %s: %w", stages[i].name, err)
+	}
+
+	// land — only the flags `work land` understands are forwarded.
+	var landArgs []string
+	if v := flagValue(args, "--verify-cmd"); v != "" {
+		landArgs = append(landArgs, "--verify-cmd", v)
+	}
+	if containsArg(args, "--no-verify") {
+		landArgs = append(landArgs, "--no-verify")
+	}
+	r, err := landBranch(landArgs)
+	if err != nil {
+		return stop(0, err)
+	}
+	if r.viaPR {
+		return stop(0, fmt.Errorf("direct push to master was refused; pushed branch %s for a PR instead — nothing to deploy yet", r.branch))
+	}
+	stages[0].state, stages[0].detail = "ok", "landed "+short(r.sha)
+
+	// ci
+	fmt.Fprintln(os.Stderr, "homelab: ship: watching CI for the landed commit...")
+	if err := ciWatch([]string{r.sha}); err != nil {
+		return stop(1, err)
+	}
+	stages[1].state, stages[1].detail = "ok", "pipeline green for "+short(r.sha)
+
+	// rollout
+	if len(deploys) == 0 {
+		stages[2].state, stages[2].detail = "skipped", "no --deploy given"
+	} else {
+		for _, d := range deploys {
+			fmt.Fprintf(os.Stderr, "homelab: ship: waiting for %s...\n", d)
+			if err := deployWait([]string{d, "--sha", short(r.sha), "--timeout", rolloutTimeout.String()}); err != nil {
+				return stop(2, fmt.Errorf("%s: %w", d, err))
+			}
+		}
+		stages[2].state, stages[2].detail = "ok", strings.Join(deploys, ", ")+" on "+short(r.sha)
+	}
+
+	// smoke
+	if len(urls) == 0 {
+		stages[3].state, stages[3].detail = "skipped", "no --url given"
+	} else {
+		var results []string
+		for _, u := range urls {
+			res, err := smokeProbe(u, smokeTimeout)
+			if err != nil {
+				return stop(3, err)
+			}
+			results = append(results, res)
+		}
+		stages[3].state, stages[3].detail = "ok", strings.Join(results, "; ")
+	}
+	fmt.Print(formatShipReport(stages))
+	return nil
+}
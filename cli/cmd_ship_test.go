@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextBackoff(t *testing.T) {
+	var got []time.Duration
+	var d time.Duration
+	for i := 0; i < 7; i++ {
+		d = nextBackoff(d)
+		got = append(got, d)
+	}
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("backoff sequence = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSmokeURL(t *testing.T) {
+	cases := map[string]string{
+		"tripit.viktorbarzin.me":          "https://tripit.viktorbarzin.me",
+		"tripit.viktorbarzin.me/healthz":  "https://tripit.viktorbarzin.me/healthz",
+		"http://ha.viktorbarzin.lan/api/": "http://ha.viktorbarzin.lan/api/",
+	}
+	for in, want := range cases {
+		if got := smokeURL(in); got != want {
+			t.Errorf("smokeURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatShipReport(t *testing.T) {
+	out := formatShipReport([]shipStage{
+		{name: "land", state: "ok", detail: "landed abc1234"},
+		{name: "ci", state: "ok", detail: "pipeline green for abc1234"},
+		{name: "rollout", state: "failed", detail: "tripit/tripit: timed out"},
+		{name: "smoke"},
+	})
+	for _, want := range []string{
+		"✓ land     landed abc1234",
+		"✗ rollout  tripit/tripit: timed out",
+		"– smoke    not run",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+	if out := formatShipReport([]shipStage{{name: "smoke", state: "skipped", detail: "no --url given"}}); !strings.Contains(out, "· smoke    no --url given") {
+		t.Errorf("skipped stage not rendered:\n%s", out)
+	}
+}
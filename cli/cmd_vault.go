@@ -32,6 +32,12 @@ func vaultCommands() []Command {
 			Summary: "[vaultwarden] search your item names: vault search <query>", Run: vaultSearch},
 		{Path: []string{"vault", "code"}, Tier: TierRead,
 			Summary: "[vaultwarden] current TOTP code for an item: vault code <name>", Run: vaultCode},
+		{Path: []string{"vault", "add"}, Tier: TierWrite,
+			Summary: "[vaultwarden] store a new login: vault add <name> [--username U] [--uri URL] [--generate N] (password via stdin unless generated)", Run: vaultAdd},
+		{Path: []string{"vault", "edit"}, Tier: TierWrite,
+			Summary: "[vaultwarden] change one field of a login: vault edit <name> --field K [--generate N] (value via stdin)", Run: vaultEdit},
+		{Path: []string{"vault", "exec"}, Tier: TierWrite,
+			Summary: "run a command with secrets as env vars: vault exec --env NAME=kv:<path>#<field> --env NAME=vw:<item>[#<field>] -- <cmd>", Run: vaultExec},
 		{Path: []string{"vault", "lock"}, Tier: TierWrite,
 			Summary: "[vaultwarden] lock/log out the local bw session", Run: vaultLock},
 		{Path: []string{"vault"}, Tier: TierRead,
@@ -61,12 +67,30 @@ func vaultHelp() string {
   homelab vault get <name> --all  all fields (incl. custom) as JSON; piped only.
                                   TOTP shown as presence flag — use 'vault code' for a code.
   homelab vault code <name>       current TOTP code
+  homelab vault add <name> [--username U] [--uri URL] [--generate N]
+                                  store a new login; password via stdin / no-echo prompt,
+                                  or --generate N → stored, then to the clipboard
+  homelab vault edit <name> --field K [--generate N]
+                                  set password|username|uri|notes|totp or a custom field;
+                                  value via stdin / no-echo prompt (or generated)
   homelab vault lock              lock / log out the local bw session
 
 ── HashiCorp Vault / OpenBao  (infra secrets; uses your own OIDC vault token) ──
   homelab vault kv get <path> [--field K]   read an infra KV secret
   homelab vault kv list <path>              list sub-paths
+  homelab vault kv tree <path>              every sub-path, recursively
+  homelab vault kv find <pattern>           paths / key names matching (never values)
+  homelab vault kv diff <pathA> <pathB>     which keys differ (hash compare, no values)
+  homelab vault kv history <path>           versions and the keys each changed
+  homelab vault kv rollback <path> --to N   restore version N (shows the change, asks first)
   homelab vault kv put <path> <key>         write one key (value via stdin)
+  homelab vault rotate <path> <key>         new value → sync → restart consumers → health
+                                            (--dry-run lists the consumers only)
+
+── Both ──
+  homelab vault exec --env NAME=kv:<path>#<field> --env NAME=vw:<item>[#<field>] -- <cmd> [args]
+                                  run <cmd> with those secrets as environment variables
+                                  (never argv/history); vw field defaults to password
 
 Vaultwarden creds live only in your own Vault path; the admin never sees them.
 Security model: docs/runbooks/homelab-vault-onboarding.md
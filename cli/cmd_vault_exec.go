@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// `vault exec` runs a tool with secrets from either store as environment
+// variables, so they never pass through argv, shell history or a file:
+//
+//	homelab vault exec --env DB_PASSWORD=kv:secret/app#db_password \
+//	                   --env GH_TOKEN=vw:github#password -- ./deploy.sh
+//
+// kv: refs are read with the caller's own Vault token (as `vault kv get`),
+// vw: refs through the user's Vaultwarden session (as `vault get`). The CLI
+// then execve(2)s the command in its own place: the resolved values exist in
+// this process only until the image is replaced, so no copy outlives it, and
+// the child's environment is the caller's — not the one the CLI built for
+// itself (no injected scoped VAULT_TOKEN).
+
+// execRef is one parsed `--env NAME=store:ref#field`.
+type execRef struct {
+	Name  string // environment variable
+	Store string // "kv" or "vw"
+	Path  string // KV path or Vaultwarden item name
+	Field string
+}
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseExecRef parses NAME=kv:secret/x#field or NAME=vw:item[#field]; a vw
+// field defaults to password. The TOTP seed is not offered: `vault code` is
+// the one audited TOTP path.
+func parseExecRef(spec string) (execRef, error) {
+	eq := strings.IndexByte(spec, '=')
+	if eq < 0 {
+		return execRef{}, fmt.Errorf("--env %q: want NAME=kv:<path>#<field> or NAME=vw:<item>[#<field>]", spec)
+	}
+	r := execRef{Name: spec[:eq]}
+	if !envNameRe.MatchString(r.Name) {
+		return execRef{}, fmt.Errorf("--env %q: %q is not a valid environment variable name", spec, r.Name)
+	}
+	ref := spec[eq+1:]
+	switch {
+	case strings.HasPrefix(ref, "kv:"):
+		r.Store, ref = "kv", strings.TrimPrefix(ref, "kv:")
+	case strings.HasPrefix(ref, "vw:"):
+		r.Store, ref = "vw", strings.TrimPrefix(ref, "vw:")
+	default:
+		return execRef{}, fmt.Errorf("--env %s: the value must start with kv: (infra KV) or vw: (Vaultwarden)", r.Name)
+	}
+	// The field follows the LAST '#', so an item name may itself contain one.
+	if i := strings.LastIndexByte(ref, '#'); i >= 0 {
+		r.Path, r.Field = ref[:i], ref[i+1:]
+	} else {
+		r.Path = ref
+	}
+	if r.Path == "" {
+		return execRef{}, fmt.Errorf("--env %s: empty %s reference", r.Name, r.Store)
+	}
+	switch r.Store {
+	case "kv":
+		if r.Field == "" {
+			return execRef{}, fmt.Errorf("--env %s: kv refs need a field: kv:%s#<field>", r.Name, r.Path)
+		}
+	case "vw":
+		if r.Field == "" {
+			r.Field = "password"
+		}
+		if r.Field == "totp" {
+			return execRef{}, fmt.Errorf("--env %s: TOTP is not injectable; use `homelab vault code`", r.Name)
+		}
+		if !validGetFields[r.Field] {
+			return execRef{}, fmt.Errorf("--env %s: invalid vw field %q (want password|username|uri|notes; an item name containing '#' needs an explicit #password)", r.Name, r.Field)
+		}
+	}
+	return r, nil
+}
+
+// parseExecArgs splits `--env … -- cmd args` into refs and the command.
+func parseExecArgs(args []string) ([]execRef, []string, error) {
+	usage := fmt.Errorf("usage: homelab vault exec --env NAME=kv:<path>#<field> --env NAME=vw:<item>[#<field>] … -- <cmd> [args]")
+	sep := -1
+	for i, a := range args {
+		if a == "--" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || sep == len(args)-1 {
+		return nil, nil, usage
+	}
+	specs := flagValues(args[:sep], "--env")
+	if len(specs) == 0 {
+		return nil, nil, usage
+	}
+	seen := map[string]bool{}
+	var refs []execRef
+	for _, s := range specs {
+		r, err := parseExecRef(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		if seen[r.Name] {
+			return nil, nil, fmt.Errorf("--env %s given twice", r.Name)
+		}
+		seen[r.Name] = true
+		refs = append(refs, r)
+	}
+	return refs, args[sep+1:], nil
+}
+
+// resolveExecRefs reads every ref into NAME=value. kv refs go first, while
+// the process still carries only the caller's token; vw refs share one
+// Vaultwarden session, opened by vwSession only if any are present. Each
+// resolution is op-logged by store, never by path or item.
+func resolveExecRefs(run cmdRunner, refs []execRef, vwSession func() (session, error)) ([]string, error) {
+	vals := make([]string, len(refs))
+	for i, r := range refs {
+		if r.Store != "kv" {
+			continue
+		}
+		v, err := kvGetField(run, r.Path, r.Field)
+		if err != nil {
+			return nil, fmt.Errorf("--env %s: %w", r.Name, err)
+		}
+		vals[i] = v
+		logExecResolve("exec-kv", r)
+	}
+	var s *session
+	for i, r := range refs {
+		if r.Store != "vw" {
+			continue
+		}
+		if s == nil {
+			opened, err := vwSession()
+			if err != nil {
+				return nil, err
+			}
+			s = &opened
+		}
+		v, err := bwGet(run, s.env, r.Field, r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("--env %s: %w", r.Name, err)
+		}
+		vals[i] = v
+		logExecResolve("exec-vw", r)
+	}
+	env := make([]string, len(refs))
+	for i, r := range refs {
+		env[i] = r.Name + "=" + vals[i]
+	}
+	return env, nil
+}
+
+// logExecResolve is a seam so tests don't write to syslog.
+var logExecResolve = func(verb string, r execRef) {
+	writeOpLog(opRecord{User: vaultCurrentUser(), Verb: verb, PID: os.Getpid(), PPID: os.Getppid(), ParentComm: parentComm(os.Getppid()), ItemName: r.Path})
+}
+
+// mergeEnv returns base with every NAME in extra replaced by extra's value.
+func mergeEnv(base, extra []string) []string {
+	drop := map[string]bool{}
+	for _, kv := range extra {
+		drop[kv[:strings.IndexByte(kv, '=')]] = true
+	}
+	out := make([]string, 0, len(base)+len(extra))
+	for _, kv := range base {
+		name := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			name = kv[:i]
+		}
+		if !drop[name] {
+			out = append(out, kv)
+		}
+	}
+	return append(out, extra...)
+}
+
+func vaultExec(args []string) error {
+	// The child gets the caller's environment, captured before ensureVault*
+	// adjust this process's own.
+	baseEnv := os.Environ()
+	hardenProcess()
+	refs, argv, err := parseExecArgs(args)
+	if err != nil {
+		return err
+	}
+	bin, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+	ensureVaultAddr()
+	var unlock func()
+	defer func() {
+		if unlock != nil {
+			unlock()
+		}
+	}()
+	vwSession := func() (session, error) {
+		ensureVaultToken()
+		uid := vaultCurrentUID()
+		u, err := withUserLock(uid)
+		if err != nil {
+			return session{}, err
+		}
+		unlock = u
+		return openSession(realRunner, vaultCurrentUser(), uid)
+	}
+	env, err := resolveExecRefs(realRunner, refs, vwSession)
+	if err != nil {
+		return err
+	}
+	if unlock != nil {
+		unlock()
+		unlock = nil
+	}
+	// A successful execve never returns, so dispatch records only the runs
+	// where it failed — with the real error, never an advance exit=0.
+	return fmt.Errorf("exec %s: %w", argv[0], syscall.Exec(bin, argv, mergeEnv(baseEnv, env)))
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // The `vault kv` verbs talk to HashiCorp Vault / OpenBao — the homelab INFRA
@@ -27,8 +32,20 @@ func vaultKVCommands() []Command {
 			Summary: "[hashicorp-vault] read an infra KV secret: vault kv get <path> [--field K]", Run: vaultKVGet},
 		{Path: []string{"vault", "kv", "list"}, Tier: TierRead,
 			Summary: "[hashicorp-vault] list infra KV sub-paths: vault kv list <path>", Run: vaultKVList},
+		{Path: []string{"vault", "kv", "tree"}, Tier: TierRead,
+			Summary: "[hashicorp-vault] every path under a prefix, recursively: vault kv tree <path> [--depth N]", Run: vaultKVTree},
+		{Path: []string{"vault", "kv", "find"}, Tier: TierRead,
+			Summary: "[hashicorp-vault] find paths/keys by NAME (never values): vault kv find <pattern> [--under secret/]", Run: vaultKVFind},
+		{Path: []string{"vault", "kv", "diff"}, Tier: TierRead,
+			Summary: "[hashicorp-vault] which keys differ between two paths (hash compare, no values): vault kv diff <pathA> <pathB>", Run: vaultKVDiff},
+		{Path: []string{"vault", "kv", "history"}, Tier: TierRead,
+			Summary: "[hashicorp-vault] KV v2 versions with the keys each changed: vault kv history <path> [--field K --version N]", Run: vaultKVHistory},
+		{Path: []string{"vault", "kv", "rollback"}, Tier: TierWrite,
+			Summary: "[hashicorp-vault] restore an earlier version (confirms first): vault kv rollback <path> --to N [--yes]", Run: vaultKVRollback},
 		{Path: []string{"vault", "kv", "put"}, Tier: TierWrite,
 			Summary: "[hashicorp-vault] write one KV key (value via stdin): vault kv put <path> <key>", Run: vaultKVPut},
+		{Path: []string{"vault", "rotate"}, Tier: TierWrite,
+			Summary: "[hashicorp-vault] rotate a KV key and roll its consumers: vault rotate <path> <key> [--generate N | --stdin] [--dry-run] [--yes]", Run: vaultRotate},
 		{Path: []string{"vault", "kv"}, Tier: TierRead,
 			Summary: "[hashicorp-vault] infra secrets (run `homelab vault kv` for help)",
 			Run:     func([]string) error { fmt.Print(vaultKVHelp()); return nil }},
@@ -42,6 +59,21 @@ func vaultKVHelp() string {
                                   --field K  → one value (TTY → clipboard; piped → stdout)
                                   no --field → all fields as JSON (piped only)
   homelab vault kv list <path>    list sub-paths under <path> (no values)
+  homelab vault kv tree <path> [--depth N]
+                                  every sub-path under <path>, recursively (no values)
+  homelab vault kv find <pattern> [--under secret/]
+                                  paths and key NAMES matching <pattern> (substring,
+                                  or a glob with * ? [..]); values are never matched
+  homelab vault kv diff <pathA> <pathB>
+                                  keys only in A / only in B / differing, by hash —
+                                  no values printed; exits non-zero if they differ
+  homelab vault kv history <path>  versions, newest first, with when and which keys
+                                  each added (+), changed (~) or removed (-); no values
+                                  --field K --version N → that key's value at version N
+                                  (TTY → clipboard; piped → stdout)
+  homelab vault kv rollback <path> --to N [--yes]
+                                  make version N current again (as a new version);
+                                  shows the keys that change and asks first
   homelab vault kv put <path> <key>   write one key; value read from stdin
                                   (piped, or no-echo prompt); merges — never clobbers siblings
 
@@ -58,6 +90,15 @@ func vaultKVGetFieldArgs(path, field string) []string {
 }
 func vaultKVGetJSONArgs(path string) []string { return []string{"kv", "get", "-format=json", path} }
 func vaultKVListArgs(path string) []string    { return []string{"kv", "list", "-format=json", path} }
+func vaultKVMetadataArgs(path string) []string {
+	return []string{"kv", "metadata", "get", "-format=json", path}
+}
+func vaultKVGetVersionArgs(path string, version int) []string {
+	return []string{"kv", "get", "-format=json", "-version=" + strconv.Itoa(version), path}
+}
+func vaultKVRollbackArgs(path string, version int) []string {
+	return []string{"kv", "rollback", "-version=" + strconv.Itoa(version), path}
+}
 
 // vaultKVPutArgs builds the write argv. merge=true → `kv patch -method=rw`
 // (read-modify-write: merges, needs only read+update — not the `patch` capability
@@ -135,6 +176,427 @@ func kvPut(run cmdRunner, runStdin cmdRunnerStdin, path, key, value string) erro
 	return err
 }
 
+// --- recursive walk (tree / find) ----------------------------------------
+
+// kvWalkParallel bounds the concurrent `vault kv list` calls of a walk: enough
+// to make a few hundred paths quick, few enough not to look like a scan to
+// Vault's rate limiting.
+const kvWalkParallel = 8
+
+// kvWalk lists every secret path under root (a "dir/" prefix), descending at
+// most maxDepth levels (0 = unlimited) with at most parallel lists in flight.
+// A sub-path that cannot be listed (403 under a partially granted prefix) is
+// reported in denied rather than failing the walk; only a failure at root is
+// an error.
+func kvWalk(run cmdRunner, root string, maxDepth, parallel int) (leaves, denied []string, err error) {
+	root = strings.TrimRight(root, "/") + "/"
+	top, err := kvList(run, root)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, parallel)
+	)
+	var visit func(dir string, keys []string, depth int)
+	visit = func(dir string, keys []string, depth int) {
+		for _, k := range keys {
+			p := dir + k
+			if !strings.HasSuffix(k, "/") {
+				mu.Lock()
+				leaves = append(leaves, p)
+				mu.Unlock()
+				continue
+			}
+			if maxDepth > 0 && depth >= maxDepth {
+				mu.Lock()
+				leaves = append(leaves, p) // shown as an unexpanded folder
+				mu.Unlock()
+				continue
+			}
+			wg.Add(1)
+			go func(p string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				sub, err := kvList(run, p)
+				<-sem
+				if err != nil {
+					mu.Lock()
+					denied = append(denied, p)
+					mu.Unlock()
+					return
+				}
+				visit(p, sub, depth+1)
+			}(p)
+		}
+	}
+	visit(root, top, 1)
+	wg.Wait()
+	sort.Strings(leaves)
+	sort.Strings(denied)
+	return leaves, denied, nil
+}
+
+// renderKVTree indents paths (relative to root) by depth, printing each
+// folder once before its contents.
+func renderKVTree(root string, paths []string) string {
+	root = strings.TrimRight(root, "/") + "/"
+	var b strings.Builder
+	b.WriteString(root + "\n")
+	printed := map[string]bool{}
+	for _, p := range paths {
+		rel := strings.TrimPrefix(p, root)
+		parts := strings.SplitAfter(rel, "/")
+		if parts[len(parts)-1] == "" {
+			parts = parts[:len(parts)-1]
+		}
+		prefix := ""
+		for i, part := range parts {
+			prefix += part
+			if i < len(parts)-1 && printed[prefix] {
+				continue
+			}
+			printed[prefix] = true
+			b.WriteString(strings.Repeat("  ", i+1) + part + "\n")
+		}
+	}
+	return b.String()
+}
+
+// kvKeyNames returns the key names of a `vault kv get -format=json` envelope,
+// sorted. The values are parsed only as opaque JSON and dropped.
+func kvKeyNames(jsonOut string) ([]string, error) {
+	data, err := extractKVData(jsonOut)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, fmt.Errorf("parse vault kv data: %w", err)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// kvNameMatcher matches a name case-insensitively: as a glob when the
+// pattern has glob characters, else as a substring.
+func kvNameMatcher(pattern string) (func(string) bool, error) {
+	p := strings.ToLower(pattern)
+	if !strings.ContainsAny(p, "*?[") {
+		return func(s string) bool { return strings.Contains(strings.ToLower(s), p) }, nil
+	}
+	if _, err := path.Match(p, ""); err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+	}
+	return func(s string) bool { ok, _ := path.Match(p, strings.ToLower(s)); return ok }, nil
+}
+
+// kvFind walks under root and returns every path whose last segment matches,
+// plus every "path#key" whose key name matches. Key names are read with
+// bounded parallelism like the walk; values are discarded unread. A leaf that
+// cannot be read or parsed is reported in denied, never silently dropped.
+func kvFind(run cmdRunner, root, pattern string, parallel int) (hits, denied []string, err error) {
+	match, err := kvNameMatcher(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	leaves, denied, err := kvWalk(run, root, 0, parallel)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, parallel)
+	)
+	for _, p := range leaves {
+		if match(path.Base(p)) {
+			hits = append(hits, p)
+		}
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			out, err := run("vault", vaultKVGetJSONArgs(p), nil)
+			<-sem
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				denied = append(denied, p)
+				return
+			}
+			keys, err := kvKeyNames(out)
+			if err != nil {
+				denied = append(denied, p)
+				return
+			}
+			for _, k := range keys {
+				if match(k) {
+					hits = append(hits, p+"#"+k)
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	sort.Strings(hits)
+	sort.Strings(denied)
+	return hits, denied, nil
+}
+
+// kvHashes maps each key of a secret to the SHA-256 of its JSON value, so
+// two secrets can be compared without holding or showing the values.
+func kvHashes(run cmdRunner, p string) (map[string][32]byte, error) {
+	data, err := kvGetJSON(run, p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return hashKVData(data)
+}
+
+// kvVersionHashes is kvHashes for one KV v2 version of p.
+func kvVersionHashes(run cmdRunner, p string, version int) (map[string][32]byte, error) {
+	out, err := run("vault", vaultKVGetVersionArgs(p, version), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s version %d: %w", p, version, err)
+	}
+	data, err := extractKVData(out)
+	if err != nil {
+		return nil, fmt.Errorf("%s version %d: %w", p, version, err)
+	}
+	return hashKVData(data)
+}
+
+func hashKVData(data string) (map[string][32]byte, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, fmt.Errorf("parse vault kv data: %w", err)
+	}
+	h := make(map[string][32]byte, len(m))
+	for k, v := range m {
+		h[k] = sha256.Sum256(v)
+	}
+	return h, nil
+}
+
+// kvKeyDiff is the key-level comparison of two secrets.
+type kvKeyDiff struct {
+	OnlyA, OnlyB, Differ, Same []string
+}
+
+func diffKVHashes(a, b map[string][32]byte) kvKeyDiff {
+	var d kvKeyDiff
+	for k, ha := range a {
+		hb, ok := b[k]
+		switch {
+		case !ok:
+			d.OnlyA = append(d.OnlyA, k)
+		case ha != hb:
+			d.Differ = append(d.Differ, k)
+		default:
+			d.Same = append(d.Same, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			d.OnlyB = append(d.OnlyB, k)
+		}
+	}
+	for _, l := range [][]string{d.OnlyA, d.OnlyB, d.Differ, d.Same} {
+		sort.Strings(l)
+	}
+	return d
+}
+
+func formatKVKeyDiff(pa, pb string, d kvKeyDiff) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", pa, pb)
+	for _, k := range d.OnlyA {
+		fmt.Fprintf(&b, "- %s  (only in %s)\n", k, pa)
+	}
+	for _, k := range d.OnlyB {
+		fmt.Fprintf(&b, "+ %s  (only in %s)\n", k, pb)
+	}
+	for _, k := range d.Differ {
+		fmt.Fprintf(&b, "~ %s  (value differs)\n", k)
+	}
+	fmt.Fprintf(&b, "%d same, %d differ, %d only in A, %d only in B\n", len(d.Same), len(d.Differ), len(d.OnlyA), len(d.OnlyB))
+	return b.String()
+}
+
+// kvWalkFlags reads --parallel (default kvWalkParallel).
+func kvWalkFlags(args []string) (int, error) {
+	parallel := kvWalkParallel
+	if v := flagValue(args, "--parallel"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 32 {
+			return 0, fmt.Errorf("bad --parallel %q: want 1..32", v)
+		}
+		parallel = n
+	}
+	return parallel, nil
+}
+
+// printDenied notes the sub-paths the walk could not read, on stderr.
+func printDenied(denied []string) {
+	if len(denied) > 0 {
+		fmt.Fprintf(os.Stderr, "%d path(s) could not be read (no access with your token, or an unparseable response; skipped): %s\n", len(denied), strings.Join(denied, ", "))
+	}
+}
+
+// --- version history / rollback -------------------------------------------
+
+// kvMetadata is the part of `vault kv metadata get -format=json` history
+// needs.
+type kvMetadata struct {
+	Current  int
+	Versions map[int]kvVersionMeta
+}
+
+type kvVersionMeta struct {
+	Created   string `json:"created_time"`
+	Deleted   string `json:"deletion_time"`
+	Destroyed bool   `json:"destroyed"`
+}
+
+func parseKVMetadata(jsonOut string) (kvMetadata, error) {
+	var env struct {
+		Data struct {
+			CurrentVersion int                      `json:"current_version"`
+			Versions       map[string]kvVersionMeta `json:"versions"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(jsonOut), &env); err != nil {
+		return kvMetadata{}, fmt.Errorf("parse vault kv metadata json: %w", err)
+	}
+	m := kvMetadata{Current: env.Data.CurrentVersion, Versions: map[int]kvVersionMeta{}}
+	for k, v := range env.Data.Versions {
+		n, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		m.Versions[n] = v
+	}
+	if len(m.Versions) == 0 {
+		return kvMetadata{}, fmt.Errorf("no versions (not a KV v2 secret?)")
+	}
+	return m, nil
+}
+
+func kvGetMetadata(run cmdRunner, p string) (kvMetadata, error) {
+	out, err := run("vault", vaultKVMetadataArgs(p), nil)
+	if err != nil {
+		return kvMetadata{}, err
+	}
+	return parseKVMetadata(out)
+}
+
+// kvVersion is one version of a secret and the keys it changed relative to
+// the previous readable version.
+type kvVersion struct {
+	N       int
+	Created string
+	State   string // "", "deleted", "destroyed" or "unreadable"
+	Current bool
+	kvKeyDiff
+}
+
+// kvHistory reads every retained version of p, oldest first. A deleted or
+// destroyed version has no data; the next readable one is compared with the
+// last readable one before it.
+func kvHistory(run cmdRunner, p string) ([]kvVersion, error) {
+	meta, err := kvGetMetadata(run, p)
+	if err != nil {
+		return nil, err
+	}
+	ns := make([]int, 0, len(meta.Versions))
+	for n := range meta.Versions {
+		ns = append(ns, n)
+	}
+	sort.Ints(ns)
+	var out []kvVersion
+	var prev map[string][32]byte
+	for _, n := range ns {
+		vm := meta.Versions[n]
+		v := kvVersion{N: n, Created: vm.Created, Current: n == meta.Current}
+		switch {
+		case vm.Destroyed:
+			v.State = "destroyed"
+		case vm.Deleted != "":
+			v.State = "deleted"
+		}
+		if v.State == "" {
+			h, err := kvVersionHashes(run, p, n)
+			if err != nil {
+				v.State = "unreadable"
+			} else {
+				if prev == nil {
+					prev = map[string][32]byte{}
+				}
+				v.kvKeyDiff = diffKVHashes(prev, h)
+				prev = h
+			}
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// formatKVHistory renders versions newest first: +added ~changed -removed.
+func formatKVHistory(p string, versions []kvVersion) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", p)
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		label := fmt.Sprintf("v%d", v.N)
+		if v.Current {
+			label += "*"
+		}
+		var changes []string
+		for _, k := range v.OnlyB {
+			changes = append(changes, "+"+k)
+		}
+		for _, k := range v.Differ {
+			changes = append(changes, "~"+k)
+		}
+		for _, k := range v.OnlyA {
+			changes = append(changes, "-"+k)
+		}
+		desc := strings.Join(changes, " ")
+		switch {
+		case v.State != "":
+			desc = "(" + v.State + ")"
+		case desc == "":
+			desc = "(no key changes)"
+		}
+		fmt.Fprintf(&b, "  %-5s %s  %s\n", label, shortTime(v.Created), desc)
+	}
+	b.WriteString("* current. Values are never shown; use --field K --version N for one.\n")
+	return b.String()
+}
+
+// formatRollbackPlan lists the keys a rollback from the current version to
+// target changes, from the current version's point of view.
+func formatRollbackPlan(p string, current, target int, d kvKeyDiff) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rollback %s: v%d (current) → contents of v%d\n", p, current, target)
+	for _, k := range d.OnlyB {
+		fmt.Fprintf(&b, "  + %s  (restored)\n", k)
+	}
+	for _, k := range d.Differ {
+		fmt.Fprintf(&b, "  ~ %s  (value changes)\n", k)
+	}
+	for _, k := range d.OnlyA {
+		fmt.Fprintf(&b, "  - %s  (removed)\n", k)
+	}
+	fmt.Fprintf(&b, "  %d key(s) unchanged\n", len(d.Same))
+	return b.String()
+}
+
 // --- handlers --------------------------------------------------------------
 
 func vaultKVGet(args []string) error {
@@ -246,3 +708,185 @@ func readSecretValue(prompt string) (string, error) {
 	}
 	return promptNoEcho(prompt)
 }
+
+func vaultKVTree(args []string) error {
+	ensureVaultAddr()
+	pos := positionalsSkipping(args, map[string]bool{"--depth": true, "--parallel": true})
+	if len(pos) != 1 {
+		return fmt.Errorf("usage: homelab vault kv tree <path> [--depth N] [--parallel 8]")
+	}
+	depth := 0
+	if v := flagValue(args, "--depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("bad --depth %q: want a positive number", v)
+		}
+		depth = n
+	}
+	parallel, err := kvWalkFlags(args)
+	if err != nil {
+		return err
+	}
+	leaves, denied, err := kvWalk(realRunner, pos[0], depth, parallel)
+	if err != nil {
+		return err
+	}
+	fmt.Print(renderKVTree(pos[0], leaves))
+	fmt.Fprintf(os.Stderr, "%d path(s)\n", len(leaves))
+	printDenied(denied)
+	return nil
+}
+
+func vaultKVFind(args []string) error {
+	hardenProcess()
+	ensureVaultAddr()
+	pos := positionalsSkipping(args, map[string]bool{"--under": true, "--parallel": true})
+	if len(pos) != 1 {
+		return fmt.Errorf("usage: homelab vault kv find <pattern> [--under secret/] [--parallel 8]")
+	}
+	root := flagValue(args, "--under")
+	if root == "" {
+		root = "secret/"
+	}
+	parallel, err := kvWalkFlags(args)
+	if err != nil {
+		return err
+	}
+	hits, denied, err := kvFind(realRunner, root, pos[0], parallel)
+	if err != nil {
+		return err
+	}
+	for _, h := range hits {
+		fmt.Println(h)
+	}
+	if len(hits) == 0 {
+		fmt.Fprintf(os.Stderr, "no path or key name under %s matches %q\n", root, pos[0])
+	}
+	printDenied(denied)
+	return nil
+}
+
+func vaultKVDiff(args []string) error {
+	hardenProcess()
+	ensureVaultAddr()
+	pos := positionalsSkipping(args, nil)
+	if len(pos) != 2 {
+		return fmt.Errorf("usage: homelab vault kv diff <pathA> <pathB>")
+	}
+	a, err := kvHashes(realRunner, pos[0])
+	if err != nil {
+		return err
+	}
+	b, err := kvHashes(realRunner, pos[1])
+	if err != nil {
+		return err
+	}
+	d := diffKVHashes(a, b)
+	fmt.Print(formatKVKeyDiff(pos[0], pos[1], d))
+	if n := len(d.OnlyA) + len(d.OnlyB) + len(d.Differ); n > 0 {
+		return fmt.Errorf("%d key(s) differ", n)
+	}
+	return nil
+}
+
+func vaultKVHistory(args []string) error {
+	hardenProcess()
+	ensureVaultAddr()
+	pos := positionalsSkipping(args, map[string]bool{"--field": true, "--version": true})
+	if len(pos) != 1 {
+		return fmt.Errorf("usage: homelab vault kv history <path> [--field K --version N]")
+	}
+	p := pos[0]
+	if field := flagValue(args, "--field"); field != "" {
+		n, err := strconv.Atoi(flagValue(args, "--version"))
+		if err != nil || n < 1 {
+			return fmt.Errorf("--field needs --version N (see `homelab vault kv history %s` for the versions)", p)
+		}
+		out, err := realRunner("vault", vaultKVGetVersionArgs(p, n), nil)
+		if err != nil {
+			return err
+		}
+		data, err := extractKVData(out)
+		if err != nil {
+			return fmt.Errorf("version %d: %w (deleted or destroyed?)", n, err)
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			return fmt.Errorf("parse vault kv data: %w", err)
+		}
+		v, ok := m[field]
+		if !ok {
+			return fmt.Errorf("no key %q in version %d", field, n)
+		}
+		s, isStr := v.(string)
+		if !isStr {
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		emitSecret(s)
+		return nil
+	}
+	versions, err := kvHistory(realRunner, p)
+	if err != nil {
+		return err
+	}
+	fmt.Print(formatKVHistory(p, versions))
+	return nil
+}
+
+func vaultKVRollback(args []string) error {
+	hardenProcess()
+	ensureVaultAddr()
+	pos := positionalsSkipping(args, map[string]bool{"--to": true})
+	to, err := strconv.Atoi(flagValue(args, "--to"))
+	if len(pos) != 1 || err != nil || to < 1 {
+		return fmt.Errorf("usage: homelab vault kv rollback <path> --to N [--yes]")
+	}
+	p := pos[0]
+	meta, err := kvGetMetadata(realRunner, p)
+	if err != nil {
+		return err
+	}
+	if _, ok := meta.Versions[to]; !ok {
+		return fmt.Errorf("%s has no version %d (retained: see `homelab vault kv history %s`)", p, to, p)
+	}
+	if to == meta.Current {
+		return fmt.Errorf("version %d is already current; nothing to roll back", to)
+	}
+	cur, err := kvVersionHashes(realRunner, p, meta.Current)
+	if err != nil {
+		return err
+	}
+	target, err := kvVersionHashes(realRunner, p, to)
+	if err != nil {
+		return fmt.Errorf("%w (a deleted or destroyed version cannot be restored)", err)
+	}
+	d := diffKVHashes(cur, target)
+	fmt.Print(formatRollbackPlan(p, meta.Current, to, d))
+	if len(d.OnlyA)+len(d.OnlyB)+len(d.Differ) == 0 {
+		fmt.Println("nothing changes; not rolling back")
+		return nil
+	}
+	if !containsArg(args, "--yes") && !containsArg(args, "-y") {
+		fi, _ := os.Stdin.Stat()
+		if fi == nil || fi.Mode()&os.ModeCharDevice == 0 {
+			return fmt.Errorf("refusing to roll back: stdin is not an interactive terminal; re-run with --yes once the change above is approved")
+		}
+		ok, err := confirm(fmt.Sprintf("Write the contents of v%d as a new version of %s?", to, p))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborted; nothing written")
+		}
+	}
+	if _, err := realRunner("vault", vaultKVRollbackArgs(p, to), nil); err != nil {
+		return fmt.Errorf("rollback failed (does your token have write access?): %w", err)
+	}
+	if after, err := kvGetMetadata(realRunner, p); err == nil {
+		fmt.Fprintf(os.Stderr, "rolled %s back to v%d's contents as v%d; v%d is still in the history\n", p, to, after.Current, meta.Current)
+	} else {
+		fmt.Fprintf(os.Stderr, "rolled %s back to v%d's contents\n", p, to)
+	}
+	return nil
+}
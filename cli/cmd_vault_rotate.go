@@ -0,0 +1,604 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// `vault rotate` replaces the rotation runbook: write the new value, find who
+// consumes it, push it through External Secrets, roll the consumers and check
+// they came back. Consumers are found three ways:
+//
+//   - ExternalSecrets on the vault-kv ClusterSecretStore that reference the
+//     path (data[].remoteRef with the key as property, or dataFrom extract);
+//   - Deployments that mount or env-reference the Secrets those sync into, or
+//     name them in a `secret.reloader.stakater.com/reload` annotation;
+//   - stacks that read the path at plan time (`data "vault_kv_secret_v2"`) —
+//     these only pick the value up on the next apply, so they are listed, not
+//     touched.
+//
+// Reloader may also roll some of the same Deployments when the Secret changes;
+// a second restart is harmless and keeps this command independent of it.
+// StatefulSets and DaemonSets using the Secrets are found the same way but
+// only listed as not restarted: rolling them is left to the operator.
+
+// esoKVStore is the ClusterSecretStore over the secret/ KV v2 mount
+// (stacks/external-secrets). Its remoteRef keys are relative to the mount.
+const (
+	esoKVStore      = "vault-kv"
+	rotateGenLength = 32
+	rotateAlphabet  = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+func vaultRotateHelp() string {
+	return `homelab vault rotate <path> <key> [--generate N | --stdin] [--dry-run] [--timeout 3m] [--yes]
+
+  1. finds the consumers: ExternalSecrets on the vault-kv store that sync <path>#<key>,
+     the Deployments, StatefulSets and DaemonSets using their Secrets, and stacks
+     reading <path> via Terraform
+  2. asks for confirmation (--yes skips it; with no terminal, --yes is required)
+     and writes the new value with 'vault kv put' semantics (siblings kept)
+       default / --generate N  → N random alphanumerics (default 32); never printed
+       --stdin                 → piped stdin, or a no-echo prompt
+  3. force-syncs each ExternalSecret and waits until its Secret holds the new value
+  4. restarts each Deployment (as 'homelab k8s restart') and checks it is fully ready;
+     StatefulSets and DaemonSets are listed as not restarted
+
+--dry-run stops after step 1. The old value stays in the KV history
+('homelab vault kv rollback <path> --to N' restores it).
+`
+}
+
+// generateSecret returns n characters drawn uniformly from rotateAlphabet.
+func generateSecret(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(rotateAlphabet)))
+	for i := range b {
+		r, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("cannot generate random material: %w", err)
+		}
+		b[i] = rotateAlphabet[r.Int64()]
+	}
+	return string(b), nil
+}
+
+// esoKVKey maps a CLI path (secret/foo/bar) to the key ESO uses on the
+// vault-kv store (foo/bar).
+func esoKVKey(p string) (string, error) {
+	rel := strings.TrimPrefix(strings.Trim(p, "/"), "secret/")
+	if rel == strings.Trim(p, "/") || rel == "" {
+		return "", fmt.Errorf("%s is not under secret/: only the secret/ KV mount is synced by External Secrets", p)
+	}
+	return rel, nil
+}
+
+// esConsumer is one ExternalSecret that syncs the rotated key into
+// Secret/SecretKey in Namespace.
+type esConsumer struct {
+	Namespace string
+	Name      string
+	Secret    string
+	SecretKey string
+}
+
+// findESConsumers picks, from `kubectl get externalsecrets -A -o json`, those
+// on the vault-kv store that reference rel#key.
+func findESConsumers(listJSON, rel, key string) ([]esConsumer, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				SecretStoreRef struct {
+					Name string `json:"name"`
+					Kind string `json:"kind"`
+				} `json:"secretStoreRef"`
+				Target struct {
+					Name string `json:"name"`
+				} `json:"target"`
+				Data []struct {
+					SecretKey string `json:"secretKey"`
+					RemoteRef struct {
+						Key      string `json:"key"`
+						Property string `json:"property"`
+					} `json:"remoteRef"`
+				} `json:"data"`
+				DataFrom []struct {
+					Extract *struct {
+						Key string `json:"key"`
+					} `json:"extract"`
+				} `json:"dataFrom"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(listJSON), &list); err != nil {
+		return nil, fmt.Errorf("parse externalsecrets json: %w", err)
+	}
+	sameKey := func(k string) bool { return strings.TrimPrefix(strings.Trim(k, "/"), "secret/") == rel }
+	var out []esConsumer
+	for _, es := range list.Items {
+		s := es.Spec
+		if s.SecretStoreRef.Name != esoKVStore || s.SecretStoreRef.Kind != "ClusterSecretStore" {
+			continue
+		}
+		c := esConsumer{Namespace: es.Metadata.Namespace, Name: es.Metadata.Name, Secret: s.Target.Name}
+		if c.Secret == "" {
+			c.Secret = es.Metadata.Name // ESO's default target name
+		}
+		for _, d := range s.Data {
+			if sameKey(d.RemoteRef.Key) && d.RemoteRef.Property == key {
+				c.SecretKey = d.SecretKey
+			}
+		}
+		for _, d := range s.DataFrom {
+			if c.SecretKey == "" && d.Extract != nil && sameKey(d.Extract.Key) {
+				c.SecretKey = key // extract copies every property under its own name
+			}
+		}
+		if c.SecretKey != "" {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// deployConsumer is a Deployment that uses one of the synced Secrets; Via
+// says how (env, envFrom, volume or reloader annotation).
+type deployConsumer struct {
+	Namespace string
+	Name      string
+	Via       string
+}
+
+// reloaderReloadAnnotation names Secrets whose change should roll the
+// Deployment — a reference even when the pod spec doesn't mention them.
+const reloaderReloadAnnotation = "secret.reloader.stakater.com/reload"
+
+type podSpecRefs struct {
+	Containers     []containerRefs `json:"containers"`
+	InitContainers []containerRefs `json:"initContainers"`
+	Volumes        []struct {
+		Secret *struct {
+			SecretName string `json:"secretName"`
+		} `json:"secret"`
+		Projected *struct {
+			Sources []struct {
+				Secret *struct {
+					Name string `json:"name"`
+				} `json:"secret"`
+			} `json:"sources"`
+		} `json:"projected"`
+	} `json:"volumes"`
+}
+
+type containerRefs struct {
+	Env []struct {
+		ValueFrom *struct {
+			SecretKeyRef *struct {
+				Name string `json:"name"`
+			} `json:"secretKeyRef"`
+		} `json:"valueFrom"`
+	} `json:"env"`
+	EnvFrom []struct {
+		SecretRef *struct {
+			Name string `json:"name"`
+		} `json:"secretRef"`
+	} `json:"envFrom"`
+}
+
+// secretRefs returns the Secret names a pod spec uses, each with how.
+func (p podSpecRefs) secretRefs() map[string]string {
+	refs := map[string]string{}
+	add := func(name, via string) {
+		if name != "" && refs[name] == "" {
+			refs[name] = via
+		}
+	}
+	for _, c := range append(append([]containerRefs{}, p.Containers...), p.InitContainers...) {
+		for _, e := range c.Env {
+			if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil {
+				add(e.ValueFrom.SecretKeyRef.Name, "env")
+			}
+		}
+		for _, e := range c.EnvFrom {
+			if e.SecretRef != nil {
+				add(e.SecretRef.Name, "envFrom")
+			}
+		}
+	}
+	for _, v := range p.Volumes {
+		if v.Secret != nil {
+			add(v.Secret.SecretName, "volume")
+		}
+		if v.Projected != nil {
+			for _, s := range v.Projected.Sources {
+				if s.Secret != nil {
+					add(s.Secret.Name, "volume")
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// findDeployConsumers picks, from `kubectl get deployments -A -o json`, the
+// Deployments using any of secrets (namespace → Secret names). StatefulSet
+// and DaemonSet lists share the shape it reads, so it serves those too.
+func findDeployConsumers(listJSON string, secrets map[string]map[string]bool) ([]deployConsumer, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name        string            `json:"name"`
+				Namespace   string            `json:"namespace"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+			Spec struct {
+				Template struct {
+					Spec podSpecRefs `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(listJSON), &list); err != nil {
+		return nil, fmt.Errorf("parse deployments json: %w", err)
+	}
+	var out []deployConsumer
+	for _, d := range list.Items {
+		want := secrets[d.Metadata.Namespace]
+		if len(want) == 0 {
+			continue
+		}
+		refs := d.Spec.Template.Spec.secretRefs()
+		for _, name := range strings.Split(d.Metadata.Annotations[reloaderReloadAnnotation], ",") {
+			if name = strings.TrimSpace(name); name != "" && refs[name] == "" {
+				refs[name] = "reloader annotation"
+			}
+		}
+		var via []string
+		for name, how := range refs {
+			if want[name] {
+				via = append(via, how+" "+name)
+			}
+		}
+		if len(via) > 0 {
+			sort.Strings(via)
+			out = append(out, deployConsumer{Namespace: d.Metadata.Namespace, Name: d.Metadata.Name, Via: strings.Join(via, ", ")})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// tfVaultKVDataRe matches a `data "vault_kv_secret_v2"` block's body; the
+// mount and name are then read from it (literal strings only).
+var (
+	tfVaultKVDataRe = regexp.MustCompile(`data\s+"vault_kv_secret_v2"\s+"[^"]+"\s*\{([^}]*)\}`)
+	tfMountRe       = regexp.MustCompile(`(?m)^\s*mount\s*=\s*"([^"]*)"`)
+	tfNameRe        = regexp.MustCompile(`(?m)^\s*name\s*=\s*"([^"]*)"`)
+)
+
+// tfReadsKVPath reports whether a .tf source reads secret/<rel> at plan time.
+func tfReadsKVPath(src, rel string) bool {
+	for _, m := range tfVaultKVDataRe.FindAllStringSubmatch(src, -1) {
+		mount, name := tfMountRe.FindStringSubmatch(m[1]), tfNameRe.FindStringSubmatch(m[1])
+		if mount != nil && name != nil && mount[1] == "secret" && name[1] == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// stacksReadingKVPath lists the stacks under infraRoot with a .tf file that
+// reads secret/<rel>.
+func stacksReadingKVPath(infraRoot, rel string) []string {
+	var out []string
+	for _, stack := range listStacks(infraRoot) {
+		dir := filepath.Join(infraRoot, "stacks", stack)
+		found := false
+		_ = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || found {
+				return nil
+			}
+			if fi.IsDir() && (fi.Name() == ".terraform" || fi.Name() == ".terragrunt-cache") {
+				return filepath.SkipDir
+			}
+			if strings.HasSuffix(p, ".tf") {
+				if b, err := os.ReadFile(p); err == nil && tfReadsKVPath(string(b), rel) {
+					found = true
+				}
+			}
+			return nil
+		})
+		if found {
+			out = append(out, stack)
+		}
+	}
+	return out
+}
+
+// secretKeyHash is the SHA-256 of one key of a `kubectl get secret -o json`,
+// so the sync can be checked against the new value without printing either.
+func secretKeyHash(secretJSON, key string) ([32]byte, bool) {
+	var s struct {
+		Data map[string]string `json:"data"`
+	}
+	if json.Unmarshal([]byte(secretJSON), &s) != nil {
+		return [32]byte{}, false
+	}
+	enc, ok := s.Data[key]
+	if !ok {
+		return [32]byte{}, false
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return [32]byte{}, false
+	}
+	return sha256.Sum256(raw), true
+}
+
+// deploymentReady reports whether a `kubectl get deploy -o json` has every
+// desired replica updated and ready, and the controller has seen the latest
+// spec.
+func deploymentReady(deployJSON string) (bool, string) {
+	var d struct {
+		Metadata struct {
+			Generation int64 `json:"generation"`
+		} `json:"metadata"`
+		Spec struct {
+			Replicas *int `json:"replicas"`
+		} `json:"spec"`
+		Status struct {
+			ObservedGeneration int64 `json:"observedGeneration"`
+			UpdatedReplicas    int   `json:"updatedReplicas"`
+			ReadyReplicas      int   `json:"readyReplicas"`
+			AvailableReplicas  int   `json:"availableReplicas"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(deployJSON), &d); err != nil {
+		return false, "unreadable status"
+	}
+	want := 1
+	if d.Spec.Replicas != nil {
+		want = *d.Spec.Replicas
+	}
+	s := d.Status
+	msg := fmt.Sprintf("%d/%d ready, %d updated", s.ReadyReplicas, want, s.UpdatedReplicas)
+	return s.ObservedGeneration >= d.Metadata.Generation && s.UpdatedReplicas == want && s.ReadyReplicas == want && s.AvailableReplicas == want, msg
+}
+
+// rotatePlan is what a rotation will touch. Unrestarted are the StatefulSets
+// and DaemonSets using the Secrets, keyed by kind.
+type rotatePlan struct {
+	ES          []esConsumer
+	Deploys     []deployConsumer
+	Unrestarted map[string][]deployConsumer
+	Stacks      []string
+}
+
+// rotateUnrestartedKinds are the workloads listed but not rolled.
+var rotateUnrestartedKinds = []string{"statefulset", "daemonset"}
+
+func findRotateConsumers(run cmdRunner, rel, key string) (rotatePlan, error) {
+	var plan rotatePlan
+	out, err := run("kubectl", []string{"get", "externalsecrets.external-secrets.io", "-A", "-o", "json"}, nil)
+	if err != nil {
+		return plan, fmt.Errorf("list ExternalSecrets: %w", err)
+	}
+	if plan.ES, err = findESConsumers(out, rel, key); err != nil {
+		return plan, err
+	}
+	secrets := map[string]map[string]bool{}
+	for _, c := range plan.ES {
+		if secrets[c.Namespace] == nil {
+			secrets[c.Namespace] = map[string]bool{}
+		}
+		secrets[c.Namespace][c.Secret] = true
+	}
+	if len(secrets) == 0 {
+		return plan, nil
+	}
+	out, err = run("kubectl", []string{"get", "deployments", "-A", "-o", "json"}, nil)
+	if err != nil {
+		return plan, fmt.Errorf("list Deployments: %w", err)
+	}
+	if plan.Deploys, err = findDeployConsumers(out, secrets); err != nil {
+		return plan, err
+	}
+	plan.Unrestarted = map[string][]deployConsumer{}
+	for _, kind := range rotateUnrestartedKinds {
+		out, err = run("kubectl", []string{"get", kind + "s", "-A", "-o", "json"}, nil)
+		if err != nil {
+			return plan, fmt.Errorf("list %ss: %w", kind, err)
+		}
+		if plan.Unrestarted[kind], err = findDeployConsumers(out, secrets); err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
+
+func formatRotatePlan(p, key string, plan rotatePlan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "consumers of %s#%s:\n", p, key)
+	if len(plan.ES) == 0 {
+		b.WriteString("  (no ExternalSecret on the vault-kv store syncs this key)\n")
+	}
+	for _, c := range plan.ES {
+		fmt.Fprintf(&b, "  externalsecret %s/%s → secret %s key %s\n", c.Namespace, c.Name, c.Secret, c.SecretKey)
+	}
+	for _, d := range plan.Deploys {
+		fmt.Fprintf(&b, "  deployment %s/%s  (%s)\n", d.Namespace, d.Name, d.Via)
+	}
+	for _, kind := range rotateUnrestartedKinds {
+		for _, d := range plan.Unrestarted[kind] {
+			fmt.Fprintf(&b, "  %s %s/%s  (%s) — not restarted: roll it yourself once the Secret is synced\n", kind, d.Namespace, d.Name, d.Via)
+		}
+	}
+	for _, s := range plan.Stacks {
+		fmt.Fprintf(&b, "  stack %s reads %s via Terraform — needs `homelab tf apply %s` to pick it up\n", s, p, s)
+	}
+	return b.String()
+}
+
+// waitSecretSynced force-syncs c and polls its Secret until key holds want.
+func waitSecretSynced(run cmdRunner, c esConsumer, want [32]byte, timeout time.Duration) error {
+	if _, err := run("kubectl", []string{"-n", c.Namespace, "annotate", "externalsecrets.external-secrets.io", c.Name,
+		"force-sync=" + strconv.FormatInt(time.Now().Unix(), 10), "--overwrite"}, nil); err != nil {
+		return fmt.Errorf("force-sync %s/%s: %w", c.Namespace, c.Name, err)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := run("kubectl", []string{"-n", c.Namespace, "get", "secret", c.Secret, "-o", "json"}, nil)
+		if err == nil {
+			if h, ok := secretKeyHash(out, c.SecretKey); ok && h == want {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("secret %s/%s key %s still not the new value after %s (check `homelab k8s get %s externalsecret %s`)",
+				c.Namespace, c.Secret, c.SecretKey, timeout, c.Namespace, c.Name)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func vaultRotate(args []string) error {
+	hardenProcess()
+	ensureVaultAddr()
+	pos := positionalsSkipping(args, map[string]bool{"--generate": true, "--timeout": true})
+	if len(pos) != 2 {
+		fmt.Print(vaultRotateHelp())
+		return fmt.Errorf("usage: homelab vault rotate <path> <key> [--generate N | --stdin] [--dry-run] [--yes]")
+	}
+	p, key := strings.Trim(pos[0], "/"), pos[1]
+	rel, err := esoKVKey(p)
+	if err != nil {
+		return err
+	}
+	length := rotateGenLength
+	if v := flagValue(args, "--generate"); v != "" {
+		if length, err = strconv.Atoi(v); err != nil || length < 16 || length > 256 {
+			return fmt.Errorf("bad --generate %q: want a length from 16 to 256", v)
+		}
+	}
+	if containsArg(args, "--stdin") && flagValue(args, "--generate") != "" {
+		return fmt.Errorf("--stdin and --generate are exclusive")
+	}
+	timeout, err := durationFlag(args, "--timeout", 3*time.Minute)
+	if err != nil {
+		return err
+	}
+
+	plan, err := findRotateConsumers(realRunner, rel, key)
+	if err != nil {
+		return err
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if root, err := findInfraRoot(wd); err == nil {
+			plan.Stacks = stacksReadingKVPath(root, rel)
+		}
+	}
+	fmt.Print(formatRotatePlan(p, key, plan))
+	if containsArg(args, "--dry-run") {
+		return nil
+	}
+	if !containsArg(args, "--yes") && !containsArg(args, "-y") {
+		fi, _ := os.Stdin.Stat()
+		if fi == nil || fi.Mode()&os.ModeCharDevice == 0 {
+			return fmt.Errorf("refusing to rotate: stdin is not an interactive terminal; re-run with --yes once the consumers above are approved")
+		}
+		ok, err := confirm(fmt.Sprintf("Write a new %s to %s and restart the deployments above?", key, p))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborted; nothing written")
+		}
+	}
+
+	var value string
+	if containsArg(args, "--stdin") {
+		if value, err = readSecretValue("New value for " + key + ": "); err != nil {
+			return err
+		}
+		if value == "" {
+			return fmt.Errorf("empty value; aborting (nothing written)")
+		}
+	} else if value, err = generateSecret(length); err != nil {
+		return err
+	}
+	want := sha256.Sum256([]byte(value))
+	if err := kvPut(realRunner, realRunnerStdin, p, key, value); err != nil {
+		return fmt.Errorf("writing %q to %s failed (does your token have write access?): %w", key, p, err)
+	}
+	writeOpLog(opRecord{User: vaultCurrentUser(), Verb: "kv-rotate", PID: os.Getpid(), PPID: os.Getppid(), ParentComm: parentComm(os.Getppid()), ItemName: p + "#" + key})
+	fmt.Fprintf(os.Stderr, "wrote new %s to %s (previous value kept in the KV history)\n", key, p)
+
+	var failed []string
+	for _, c := range plan.ES {
+		if err := waitSecretSynced(realRunner, c, want, timeout); err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "synced secret %s/%s\n", c.Namespace, c.Secret)
+	}
+	if len(failed) > 0 {
+		// Restarting now would roll pods onto the OLD value.
+		return fmt.Errorf("not restarting anything; sync incomplete:\n  %s", strings.Join(failed, "\n  "))
+	}
+	for _, d := range plan.Deploys {
+		fmt.Fprintf(os.Stderr, "restarting deployment %s/%s\n", d.Namespace, d.Name)
+		if err := k8sRestart([]string{d.Name, "-n", d.Namespace}); err != nil {
+			failed = append(failed, fmt.Sprintf("%s/%s: restart: %v", d.Namespace, d.Name, err))
+			continue
+		}
+		out, err := realRunner("kubectl", []string{"-n", d.Namespace, "get", "deployment", d.Name, "-o", "json"}, nil)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s/%s: status: %v", d.Namespace, d.Name, err))
+			continue
+		}
+		ok, msg := deploymentReady(out)
+		fmt.Printf("  %s/%s: %s\n", d.Namespace, d.Name, msg)
+		if !ok {
+			failed = append(failed, fmt.Sprintf("%s/%s: not healthy (%s)", d.Namespace, d.Name, msg))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("rotation written but %d consumer(s) need attention:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	for _, kind := range rotateUnrestartedKinds {
+		for _, d := range plan.Unrestarted[kind] {
+			fmt.Fprintf(os.Stderr, "not restarted: %s %s/%s — roll it to pick up the new value\n", kind, d.Namespace, d.Name)
+		}
+	}
+	if len(plan.Stacks) > 0 {
+		fmt.Fprintf(os.Stderr, "remember: apply %s for the Terraform readers\n", strings.Join(plan.Stacks, ", "))
+	}
+	fmt.Fprintln(os.Stderr, "rotation complete")
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -18,6 +19,9 @@ func TestVaultCommandsRegistered(t *testing.T) {
 		"vault list":   TierRead,
 		"vault get":    TierRead,
 		"vault search": TierRead,
+		"vault add":    TierWrite,
+		"vault edit":   TierWrite,
+		"vault exec":   TierWrite,
 		"vault code":   TierRead,
 		"vault lock":   TierWrite,
 	}
@@ -887,9 +891,15 @@ func TestReadSucceedsWhenSyncFails(t *testing.T) {
 
 func TestVaultKVCommandsRegistered(t *testing.T) {
 	want := map[string]Tier{
-		"vault kv get":  TierRead,
-		"vault kv list": TierRead,
-		"vault kv put":  TierWrite,
+		"vault kv get":      TierRead,
+		"vault kv list":     TierRead,
+		"vault kv put":      TierWrite,
+		"vault kv tree":     TierRead,
+		"vault kv find":     TierRead,
+		"vault kv diff":     TierRead,
+		"vault kv history":  TierRead,
+		"vault kv rollback": TierWrite,
+		"vault rotate":      TierWrite,
 	}
 	got := map[string]Tier{}
 	for _, c := range vaultCommands() {
@@ -1055,3 +1065,540 @@ func TestVaultHelpMentionsBothSystems(t *testing.T) {
 		t.Error("vault help must name HashiCorp Vault / OpenBao (the infra secrets store)")
 	}
 }
+
+// kvTreeRunner serves exact `vault kv list/get` answers for a small tree:
+// secret/{app1, app2/{db, web}, locked/} where locked/ is not listable.
+func kvTreeRunner(name string, argv, envv []string) (string, error) {
+	switch strings.Join(argv, " ") {
+	case "kv list -format=json secret/":
+		return `["app1","app2/","locked/"]`, nil
+	case "kv list -format=json secret/app2/":
+		return `["db","web"]`, nil
+	case "kv get -format=json secret/app1":
+		return `{"data":{"data":{"api_key":"AAA","db_password":"SAME"}}}`, nil
+	case "kv get -format=json secret/app2/db":
+		return `{"data":{"data":{"db_password":"SAME","host":"pg"}}}`, nil
+	case "kv get -format=json secret/app2/web":
+		return `{"data":{"data":{"session_secret":"x"}}}`, nil
+	}
+	return "", fmt.Errorf("permission denied")
+}
+
+func TestKVWalkRecursesAndReportsDenied(t *testing.T) {
+	leaves, denied, err := kvWalk(kvTreeRunner, "secret", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(leaves, []string{"secret/app1", "secret/app2/db", "secret/app2/web"}) {
+		t.Fatalf("leaves = %v", leaves)
+	}
+	if !reflect.DeepEqual(denied, []string{"secret/locked/"}) {
+		t.Fatalf("an unlistable sub-path is reported, not fatal: %v", denied)
+	}
+	shallow, _, _ := kvWalk(kvTreeRunner, "secret/", 1, 2)
+	if !reflect.DeepEqual(shallow, []string{"secret/app1", "secret/app2/", "secret/locked/"}) {
+		t.Fatalf("--depth 1 = %v", shallow)
+	}
+	if _, _, err := kvWalk(kvTreeRunner, "nope/", 0, 2); err == nil {
+		t.Fatal("a root that cannot be listed is an error")
+	}
+}
+
+func TestRenderKVTree(t *testing.T) {
+	got := renderKVTree("secret", []string{"secret/app1", "secret/app2/db", "secret/app2/web"})
+	want := "secret/\n  app1\n  app2/\n    db\n    web\n"
+	if got != want {
+		t.Fatalf("tree =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestKVFindMatchesNamesNeverValues(t *testing.T) {
+	hits, _, err := kvFind(kvTreeRunner, "secret/", "db", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"secret/app1#db_password", "secret/app2/db", "secret/app2/db#db_password"}
+	if !reflect.DeepEqual(hits, want) {
+		t.Fatalf("hits = %v, want %v", hits, want)
+	}
+	// "pg" is only a VALUE (host=pg): it must not match.
+	if hits, _, _ := kvFind(kvTreeRunner, "secret/", "pg", 3); len(hits) != 0 {
+		t.Fatalf("values must never be matched: %v", hits)
+	}
+	if hits, _, _ := kvFind(kvTreeRunner, "secret/", "*_SECRET", 3); !reflect.DeepEqual(hits, []string{"secret/app2/web#session_secret"}) {
+		t.Fatalf("glob, case-insensitive: %v", hits)
+	}
+}
+
+func TestKVFindReportsUnparseableLeaves(t *testing.T) {
+	run := func(name string, argv, envv []string) (string, error) {
+		if strings.Join(argv, " ") == "kv get -format=json secret/app2/web" {
+			return "not json", nil
+		}
+		return kvTreeRunner(name, argv, envv)
+	}
+	_, denied, err := kvFind(run, "secret/", "db", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(denied, []string{"secret/app2/web", "secret/locked/"}) {
+		t.Fatalf("an unparseable leaf must be reported, not dropped: %v", denied)
+	}
+}
+
+func TestDiffKVHashesNeverShowsValues(t *testing.T) {
+	a, err := kvHashes(kvTreeRunner, "secret/app1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := kvHashes(kvTreeRunner, "secret/app2/db")
+	d := diffKVHashes(a, b)
+	if !reflect.DeepEqual(d.OnlyA, []string{"api_key"}) || !reflect.DeepEqual(d.OnlyB, []string{"host"}) ||
+		!reflect.DeepEqual(d.Same, []string{"db_password"}) || len(d.Differ) != 0 {
+		t.Fatalf("diff = %+v", d)
+	}
+	out := formatKVKeyDiff("secret/app1", "secret/app2/db", d)
+	for _, v := range []string{"AAA", "SAME", "pg"} {
+		if strings.Contains(out, v) {
+			t.Fatalf("value %q leaked into the diff:\n%s", v, out)
+		}
+	}
+	if !strings.Contains(out, "- api_key  (only in secret/app1)") || !strings.HasSuffix(out, "1 same, 0 differ, 1 only in A, 1 only in B\n") {
+		t.Fatalf("diff output:\n%s", out)
+	}
+}
+
+// kvVersionsRunner serves a secret with four versions: v1 {a,b}, v2 changes
+// b and adds c, v3 is deleted, v4 (current) removes a.
+func kvVersionsRunner(name string, argv, envv []string) (string, error) {
+	switch strings.Join(argv, " ") {
+	case "kv metadata get -format=json secret/app":
+		return `{"data":{"current_version":4,"versions":{
+			"1":{"created_time":"2026-10-01T09:00:00.1Z","deletion_time":"","destroyed":false},
+			"2":{"created_time":"2026-10-02T09:00:00.1Z","deletion_time":"","destroyed":false},
+			"3":{"created_time":"2026-10-03T09:00:00.1Z","deletion_time":"2026-10-04T00:00:00Z","destroyed":false},
+			"4":{"created_time":"2026-10-05T09:00:00.1Z","deletion_time":"","destroyed":false}}}}`, nil
+	case "kv get -format=json -version=1 secret/app":
+		return `{"data":{"data":{"a":"1","b":"old"}}}`, nil
+	case "kv get -format=json -version=2 secret/app":
+		return `{"data":{"data":{"a":"1","b":"new","c":"3"}}}`, nil
+	case "kv get -format=json -version=4 secret/app":
+		return `{"data":{"data":{"b":"new","c":"3"}}}`, nil
+	}
+	return "", fmt.Errorf("unexpected %v", argv)
+}
+
+func TestKVHistoryDiffsConsecutiveReadableVersions(t *testing.T) {
+	versions, err := kvHistory(kvVersionsRunner, "secret/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 4 {
+		t.Fatalf("versions = %+v", versions)
+	}
+	if !reflect.DeepEqual(versions[0].OnlyB, []string{"a", "b"}) {
+		t.Fatalf("v1 adds every key: %+v", versions[0])
+	}
+	if !reflect.DeepEqual(versions[1].Differ, []string{"b"}) || !reflect.DeepEqual(versions[1].OnlyB, []string{"c"}) {
+		t.Fatalf("v2 = %+v", versions[1])
+	}
+	if versions[2].State != "deleted" {
+		t.Fatalf("v3 = %+v", versions[2])
+	}
+	// v4 is compared with v2, the last readable version before it.
+	if !reflect.DeepEqual(versions[3].OnlyA, []string{"a"}) || !versions[3].Current {
+		t.Fatalf("v4 = %+v", versions[3])
+	}
+	out := formatKVHistory("secret/app", versions)
+	for _, want := range []string{"v4*   2026-10-05 09:00  -a", "v3    2026-10-03 09:00  (deleted)", "v2    2026-10-02 09:00  +c ~b", "v1    2026-10-01 09:00  +a +b"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("history missing %q:\n%s", want, out)
+		}
+	}
+	for _, v := range []string{"old", "new"} {
+		if strings.Contains(out, v) {
+			t.Fatalf("value %q leaked into the history:\n%s", v, out)
+		}
+	}
+	if strings.Index(out, "v4*") > strings.Index(out, "v1 ") {
+		t.Fatalf("newest first:\n%s", out)
+	}
+}
+
+func TestFormatRollbackPlan(t *testing.T) {
+	cur, _ := kvVersionHashes(kvVersionsRunner, "secret/app", 4)
+	target, _ := kvVersionHashes(kvVersionsRunner, "secret/app", 1)
+	out := formatRollbackPlan("secret/app", 4, 1, diffKVHashes(cur, target))
+	for _, want := range []string{"v4 (current) → contents of v1", "+ a  (restored)", "~ b  (value changes)", "- c  (removed)", "0 key(s) unchanged"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := generateSecret(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := generateSecret(32)
+	if len(a) != 32 || a == b || strings.Trim(a, rotateAlphabet) != "" {
+		t.Fatalf("generateSecret = %q, %q", a, b)
+	}
+}
+
+func TestESOKVKey(t *testing.T) {
+	if rel, err := esoKVKey("secret/authentik/"); err != nil || rel != "authentik" {
+		t.Fatalf("esoKVKey = %q, %v", rel, err)
+	}
+	for _, bad := range []string{"authentik", "secret/", "database/creds/x"} {
+		if _, err := esoKVKey(bad); err == nil {
+			t.Errorf("esoKVKey(%q) should be refused", bad)
+		}
+	}
+}
+
+func TestFindESConsumers(t *testing.T) {
+	list := `{"items":[
+	 {"metadata":{"name":"forgejo-email","namespace":"forgejo"},"spec":{
+	   "secretStoreRef":{"name":"vault-kv","kind":"ClusterSecretStore"},"target":{"name":"forgejo-email"},
+	   "data":[{"secretKey":"PASSWD","remoteRef":{"key":"authentik","property":"smtp_password"}}]}},
+	 {"metadata":{"name":"authentik","namespace":"authentik"},"spec":{
+	   "secretStoreRef":{"name":"vault-kv","kind":"ClusterSecretStore"},"target":{},
+	   "dataFrom":[{"extract":{"key":"authentik"}}]}},
+	 {"metadata":{"name":"other-key","namespace":"x"},"spec":{
+	   "secretStoreRef":{"name":"vault-kv","kind":"ClusterSecretStore"},"target":{"name":"x"},
+	   "data":[{"secretKey":"K","remoteRef":{"key":"authentik","property":"api_token"}}]}},
+	 {"metadata":{"name":"db","namespace":"speedtest"},"spec":{
+	   "secretStoreRef":{"name":"vault-database","kind":"ClusterSecretStore"},"target":{"name":"db"},
+	   "data":[{"secretKey":"K","remoteRef":{"key":"authentik","property":"smtp_password"}}]}}]}`
+	got, err := findESConsumers(list, "authentik", "smtp_password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []esConsumer{
+		{Namespace: "authentik", Name: "authentik", Secret: "authentik", SecretKey: "smtp_password"},
+		{Namespace: "forgejo", Name: "forgejo-email", Secret: "forgejo-email", SecretKey: "PASSWD"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("consumers = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFindDeployConsumers(t *testing.T) {
+	list := `{"items":[
+	 {"metadata":{"name":"forgejo","namespace":"forgejo"},"spec":{"template":{"spec":{
+	   "containers":[{"env":[{"name":"FORGEJO__mailer__PASSWD","valueFrom":{"secretKeyRef":{"name":"forgejo-email","key":"PASSWD"}}}]}]}}}},
+	 {"metadata":{"name":"worker","namespace":"authentik","annotations":{"secret.reloader.stakater.com/reload":"other, authentik"}},
+	   "spec":{"template":{"spec":{"containers":[{}]}}}},
+	 {"metadata":{"name":"server","namespace":"authentik"},"spec":{"template":{"spec":{
+	   "containers":[{"envFrom":[{"secretRef":{"name":"unrelated"}}]}],
+	   "volumes":[{"projected":{"sources":[{"secret":{"name":"authentik"}}]}}]}}}},
+	 {"metadata":{"name":"same-name-elsewhere","namespace":"x"},"spec":{"template":{"spec":{
+	   "containers":[{"envFrom":[{"secretRef":{"name":"authentik"}}]}]}}}}]}`
+	secrets := map[string]map[string]bool{"forgejo": {"forgejo-email": true}, "authentik": {"authentik": true}}
+	got, err := findDeployConsumers(list, secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []deployConsumer{
+		{Namespace: "authentik", Name: "server", Via: "volume authentik"},
+		{Namespace: "authentik", Name: "worker", Via: "reloader annotation authentik"},
+		{Namespace: "forgejo", Name: "forgejo", Via: "env forgejo-email"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("deployments = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFormatRotatePlanListsUnrestarted(t *testing.T) {
+	plan := rotatePlan{
+		ES:          []esConsumer{{Namespace: "authentik", Name: "authentik", Secret: "authentik", SecretKey: "smtp_password"}},
+		Deploys:     []deployConsumer{{Namespace: "authentik", Name: "server", Via: "volume authentik"}},
+		Unrestarted: map[string][]deployConsumer{"statefulset": {{Namespace: "authentik", Name: "redis", Via: "env authentik"}}},
+	}
+	out := formatRotatePlan("secret/authentik", "smtp_password", plan)
+	for _, want := range []string{
+		"deployment authentik/server  (volume authentik)",
+		"statefulset authentik/redis  (env authentik) — not restarted",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTFReadsKVPath(t *testing.T) {
+	src := `data "vault_kv_secret_v2" "secrets" {
+  mount = "secret"
+  name  = "platform"
+}
+
+data "vault_kv_secret_v2" "other" {
+  mount = "kv"
+  name  = "authentik"
+}
+`
+	if !tfReadsKVPath(src, "platform") || tfReadsKVPath(src, "authentik") || tfReadsKVPath(src, "plat") {
+		t.Fatal("only secret/platform is read here")
+	}
+}
+
+func TestSecretKeyHashAndDeploymentReady(t *testing.T) {
+	sec := `{"data":{"PASSWD":"` + base64.StdEncoding.EncodeToString([]byte("new-value")) + `"}}`
+	if h, ok := secretKeyHash(sec, "PASSWD"); !ok || h != sha256.Sum256([]byte("new-value")) {
+		t.Fatal("synced key should hash to the new value")
+	}
+	if _, ok := secretKeyHash(sec, "missing"); ok {
+		t.Fatal("a missing key is not synced")
+	}
+	ready := `{"metadata":{"generation":4},"spec":{"replicas":2},"status":{"observedGeneration":4,"updatedReplicas":2,"readyReplicas":2,"availableReplicas":2}}`
+	if ok, msg := deploymentReady(ready); !ok || msg != "2/2 ready, 2 updated" {
+		t.Fatalf("ready = %v %q", ok, msg)
+	}
+	stale := `{"metadata":{"generation":5},"spec":{"replicas":2},"status":{"observedGeneration":4,"updatedReplicas":2,"readyReplicas":2,"availableReplicas":2}}`
+	if ok, _ := deploymentReady(stale); ok {
+		t.Fatal("a spec the controller hasn't observed is not ready")
+	}
+}
+
+// --- vault add / edit (Vaultwarden writes) ---------------------------------
+
+// bwWriteRunner is a fakeRunner with an unlockable session and a `bw list`
+// answer for the write verbs.
+func bwWriteRunner(list string) *fakeRunner {
+	return &fakeRunner{out: map[string]string{
+		"vault kv get -field=vaultwarden_master_password secret/workstation/claude-users/emo": "pw",
+		"vault kv get -field=vaultwarden_client_id secret/workstation/claude-users/emo":       "user.x",
+		"vault kv get -field=vaultwarden_client_secret secret/workstation/claude-users/emo":   "cs",
+		"bw status":     `{"status":"locked"}`,
+		"bw unlock":     "SESS",
+		"bw list items": list,
+	}}
+}
+
+func decodeBWPayload(t *testing.T, stdin string) map[string]interface{} {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(stdin)
+	if err != nil {
+		t.Fatalf("payload is not bw-encoded: %v", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestAddItemCreatesViaStdinAndRefusesDuplicates(t *testing.T) {
+	uid := fmt.Sprintf("%d", os.Getuid())
+	var calls []recStdin
+	runStdin := func(name string, argv, envv []string, stdin string) (string, error) {
+		calls = append(calls, recStdin{append([]string{name}, argv...), stdin})
+		return `{"id":"new"}`, nil
+	}
+	// "github-work" contains the name but is not it: bw's search is a substring match.
+	f := bwWriteRunner(`[{"id":"1","name":"github-work"}]`)
+	o := addOpts{name: "github", username: "viktor", uri: "https://github.com"}
+	if err := addItem(f.run, runStdin, "emo", uid, o, "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || strings.Join(calls[0].argv, " ") != "bw create item" {
+		t.Fatalf("calls = %+v", calls)
+	}
+	for _, c := range f.calls {
+		if strings.Contains(strings.Join(c, " "), "s3cret") {
+			t.Fatalf("password leaked into argv: %v", c)
+		}
+	}
+	item := decodeBWPayload(t, calls[0].stdin)
+	login := item["login"].(map[string]interface{})
+	uris := login["uris"].([]interface{})
+	if item["name"] != "github" || login["username"] != "viktor" || login["password"] != "s3cret" ||
+		uris[0].(map[string]interface{})["uri"] != "https://github.com" {
+		t.Fatalf("item = %v", item)
+	}
+
+	dup := bwWriteRunner(`[{"id":"1","name":"github"}]`)
+	calls = nil
+	if err := addItem(dup.run, runStdin, "emo", uid, o, "s3cret"); err == nil || !strings.Contains(err.Error(), "vault edit") {
+		t.Fatalf("an existing item must be refused, got %v", err)
+	}
+	if len(calls) != 0 {
+		t.Fatal("nothing may be created when the name exists")
+	}
+}
+
+func TestEditItemKeepsOtherFields(t *testing.T) {
+	uid := fmt.Sprintf("%d", os.Getuid())
+	list := `[{"id":"abc","name":"github","notes":"keep","passwordHistory":[{"password":"older"}],
+	  "login":{"username":"viktor","password":"old","uris":[{"uri":"https://github.com","match":null}]},
+	  "fields":[{"name":"recovery","value":"r1","type":1}]}]`
+	var got recStdin
+	runStdin := func(name string, argv, envv []string, stdin string) (string, error) {
+		got = recStdin{append([]string{name}, argv...), stdin}
+		return "", nil
+	}
+	if err := editItem(bwWriteRunner(list).run, runStdin, "emo", uid, "github", "password", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got.argv, " ") != "bw edit item abc" {
+		t.Fatalf("argv = %v", got.argv)
+	}
+	item := decodeBWPayload(t, got.stdin)
+	login := item["login"].(map[string]interface{})
+	if login["password"] != "new" || login["username"] != "viktor" || item["notes"] != "keep" || item["passwordHistory"] == nil {
+		t.Fatalf("edit must change only the password: %v", item)
+	}
+	if err := editItem(bwWriteRunner(`[]`).run, runStdin, "emo", uid, "github", "password", "x"); err == nil {
+		t.Fatal("a missing item is an error")
+	}
+	if err := editItem(bwWriteRunner(`[{"id":"1","name":"github"},{"id":"2","name":"github"}]`).run, runStdin, "emo", uid, "github", "password", "x"); err == nil {
+		t.Fatal("duplicate names are ambiguous and must be refused")
+	}
+}
+
+func TestSetItemFieldCustomAndURI(t *testing.T) {
+	item := map[string]interface{}{
+		"login":  map[string]interface{}{"uris": []interface{}{}},
+		"fields": []interface{}{map[string]interface{}{"name": "recovery", "value": "r1", "type": float64(1)}},
+	}
+	if err := setItemField(item, "recovery", "r2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setItemField(item, "api_key", "k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setItemField(item, "uri", "https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+	fields := item["fields"].([]interface{})
+	if len(fields) != 2 || fields[0].(map[string]interface{})["value"] != "r2" ||
+		fields[1].(map[string]interface{})["name"] != "api_key" || fields[1].(map[string]interface{})["type"] != bwFieldHidden {
+		t.Fatalf("fields = %v", fields)
+	}
+	uris := item["login"].(map[string]interface{})["uris"].([]interface{})
+	if len(uris) != 1 || uris[0].(map[string]interface{})["uri"] != "https://example.com" {
+		t.Fatalf("uris = %v", uris)
+	}
+	if err := setItemField(map[string]interface{}{"type": float64(2)}, "password", "x"); err == nil {
+		t.Fatal("a secure note has no password")
+	}
+}
+
+func TestParseAddArgs(t *testing.T) {
+	o, err := parseAddArgs([]string{"github", "--username", "viktor", "--generate", "24"})
+	if err != nil || o.name != "github" || o.username != "viktor" || o.generate != 24 {
+		t.Fatalf("parseAddArgs = %+v, %v", o, err)
+	}
+	if _, err := parseAddArgs([]string{"github", "--generate", "4"}); err == nil {
+		t.Fatal("a 4-character password is refused")
+	}
+	if _, err := parseAddArgs([]string{"--username", "viktor"}); err == nil {
+		t.Fatal("a name is required")
+	}
+}
+
+// --- vault exec ------------------------------------------------------------
+
+func TestParseExecArgs(t *testing.T) {
+	refs, argv, err := parseExecArgs([]string{"--env", "DB=kv:secret/app#db_password", "--env=GH=vw:git#hub#username", "--", "./run.sh", "--env", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []execRef{
+		{Name: "DB", Store: "kv", Path: "secret/app", Field: "db_password"},
+		{Name: "GH", Store: "vw", Path: "git#hub", Field: "username"}, // the field follows the LAST '#'
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Fatalf("refs = %+v", refs)
+	}
+	if !reflect.DeepEqual(argv, []string{"./run.sh", "--env", "x"}) {
+		t.Fatalf("flags after -- belong to the command: %v", argv)
+	}
+	for _, bad := range [][]string{
+		{"--env", "A=kv:secret/a#b"},                            // no command
+		{"--env", "A=kv:secret/a#b", "--"},                      // empty command
+		{"--", "env"},                                           // nothing to inject
+		{"--env", "A=kv:x#b", "--env", "A=kv:y#c", "--", "env"}, // duplicate name
+	} {
+		if _, _, err := parseExecArgs(bad); err == nil {
+			t.Errorf("parseExecArgs(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseExecRef(t *testing.T) {
+	if r, err := parseExecRef("TOKEN=vw:github"); err != nil || r.Field != "password" {
+		t.Fatalf("vw field defaults to password: %+v %v", r, err)
+	}
+	for _, bad := range []string{
+		"TOKEN",                   // no value
+		"1X=kv:secret/a#b",        // bad name
+		"X=secret/a#b",            // no store
+		"X=kv:secret/a",           // kv needs a field
+		"X=vw:github#totp",        // TOTP only via `vault code`
+		"X=vw:github#credit_card", // not a vw field
+	} {
+		if _, err := parseExecRef(bad); err == nil {
+			t.Errorf("parseExecRef(%q) should fail", bad)
+		}
+	}
+}
+
+func TestResolveExecRefsKVBeforeVaultwarden(t *testing.T) {
+	var logged []string
+	orig := logExecResolve
+	logExecResolve = func(verb string, r execRef) { logged = append(logged, verb) }
+	defer func() { logExecResolve = orig }()
+
+	f := &fakeRunner{out: map[string]string{
+		"vault kv get -field=db_password secret/app": "dbpw",
+		"bw get password github":                     "ghpw",
+	}}
+	opened := 0
+	vw := func() (session, error) {
+		opened++
+		// by now every kv ref has been read with the caller's token
+		if len(f.calls) != 1 {
+			t.Errorf("the Vaultwarden session must open after the kv reads, calls=%v", f.calls)
+		}
+		return session{env: []string{"BW_SESSION=S"}}, nil
+	}
+	refs := []execRef{
+		{Name: "GH", Store: "vw", Path: "github", Field: "password"},
+		{Name: "DB", Store: "kv", Path: "secret/app", Field: "db_password"},
+	}
+	env, err := resolveExecRefs(f.run, refs, vw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env, []string{"GH=ghpw", "DB=dbpw"}) || opened != 1 {
+		t.Fatalf("env=%v opened=%d", env, opened)
+	}
+	if !reflect.DeepEqual(logged, []string{"exec-kv", "exec-vw"}) {
+		t.Fatalf("op-log verbs = %v", logged)
+	}
+
+	onlyKV := &fakeRunner{out: map[string]string{"vault kv get -field=db_password secret/app": "dbpw"}}
+	if _, err := resolveExecRefs(onlyKV.run, refs[1:], func() (session, error) {
+		t.Fatal("no vw refs: no Vaultwarden session")
+		return session{}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecOpLogNeverNamesTheSecret(t *testing.T) {
+	line := opLogLine(opRecord{User: "emo", Verb: "exec-vw", ItemName: "github"})
+	if strings.Contains(line, "github") {
+		t.Fatalf("item name leaked into the op-log: %s", line)
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	got := mergeEnv([]string{"PATH=/bin", "DB=stale", "HOME=/h"}, []string{"DB=new", "GH=x"})
+	if !reflect.DeepEqual(got, []string{"PATH=/bin", "HOME=/h", "DB=new", "GH=x"}) {
+		t.Fatalf("mergeEnv = %v", got)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// `vault add` / `vault edit` are the Vaultwarden write verbs. They use the same
+// lock → openSession flow as the reads. Values never travel via argv: the item
+// JSON is base64-encoded (what `bw encode` does) and piped to `bw create item`
+// / `bw edit item <id>` on stdin, and bw's echo of the saved item (which holds
+// the password) is discarded.
+
+// bwLoginType is Bitwarden's item type for a login; bwFieldHidden is the
+// custom-field type shown masked in the clients.
+const (
+	bwLoginType   = 1
+	bwFieldHidden = 1
+)
+
+func bwCreateArgs() []string        { return []string{"create", "item"} }
+func bwEditArgs(id string) []string { return []string{"edit", "item", id} }
+
+// bwEncode is `bw encode`: the create/edit payload is base64 of the JSON.
+func bwEncode(item []byte) string { return base64.StdEncoding.EncodeToString(item) }
+
+// itemsNamed returns the raw items in a `bw list items` payload whose name is
+// exactly name. `bw list --search` and `bw get` match substrings, so neither
+// alone is safe for picking the item to write.
+func itemsNamed(listJSON, name string) ([]map[string]interface{}, error) {
+	var items []map[string]interface{}
+	if err := json.Unmarshal([]byte(listJSON), &items); err != nil {
+		return nil, fmt.Errorf("parse bw list: %w", err)
+	}
+	var out []map[string]interface{}
+	for _, it := range items {
+		if n, _ := it["name"].(string); n == name {
+			out = append(out, it)
+		}
+	}
+	return out, nil
+}
+
+// addOpts is a parsed `vault add`.
+type addOpts struct {
+	name     string
+	username string
+	uri      string
+	generate int // 0 → read the password from stdin / prompt
+}
+
+func parseAddArgs(args []string) (addOpts, error) {
+	o := addOpts{username: flagValue(args, "--username"), uri: flagValue(args, "--uri")}
+	pos := positionalsSkipping(args, map[string]bool{"--username": true, "--uri": true, "--generate": true})
+	if len(pos) != 1 {
+		return o, fmt.Errorf("usage: homelab vault add <name> [--username U] [--uri URL] [--generate N]")
+	}
+	o.name = pos[0]
+	if v := flagValue(args, "--generate"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 8 || n > 128 {
+			return o, fmt.Errorf("bad --generate %q: want a length from 8 to 128", v)
+		}
+		o.generate = n
+	}
+	return o, nil
+}
+
+// newLoginItem builds a `bw create item` login payload.
+func newLoginItem(o addOpts, password string) ([]byte, error) {
+	login := map[string]interface{}{"username": o.username, "password": password, "totp": nil, "uris": []interface{}{}}
+	if o.uri != "" {
+		login["uris"] = []interface{}{map[string]interface{}{"uri": o.uri, "match": nil}}
+	}
+	return json.Marshal(map[string]interface{}{
+		"type": bwLoginType, "name": o.name, "notes": nil, "favorite": false, "reprompt": 0,
+		"folderId": nil, "organizationId": nil, "collectionIds": nil, "fields": []interface{}{}, "login": login,
+	})
+}
+
+// setItemField sets one field of a raw bw item, keeping everything else as it
+// was. password/username/uri/totp are login fields (uri replaces the first
+// URI), notes is the note, and any other name is a custom field — updated if
+// present, added as hidden otherwise.
+func setItemField(item map[string]interface{}, field, value string) error {
+	switch field {
+	case "password", "username", "totp", "uri":
+		login, _ := item["login"].(map[string]interface{})
+		if login == nil {
+			return fmt.Errorf("item is not a login; only notes and custom fields can be edited")
+		}
+		if field != "uri" {
+			login[field] = value
+			return nil
+		}
+		uris, _ := login["uris"].([]interface{})
+		if len(uris) > 0 {
+			if first, ok := uris[0].(map[string]interface{}); ok {
+				first["uri"] = value
+				return nil
+			}
+		}
+		login["uris"] = append([]interface{}{map[string]interface{}{"uri": value, "match": nil}}, uris...)
+	case "notes":
+		item["notes"] = value
+	default:
+		fields, _ := item["fields"].([]interface{})
+		for _, f := range fields {
+			if m, ok := f.(map[string]interface{}); ok && m["name"] == field {
+				if t, _ := m["type"].(float64); int(t) == bwFieldLinked {
+					return fmt.Errorf("custom field %q is linked to another field; edit that one instead", field)
+				}
+				m["value"] = value
+				return nil
+			}
+		}
+		item["fields"] = append(fields, map[string]interface{}{"name": field, "value": value, "type": bwFieldHidden, "linkedId": nil})
+	}
+	return nil
+}
+
+// addItem creates a login named o.name, refusing when one already exists (so
+// an agent re-running a signup never forks a second entry).
+func addItem(run cmdRunner, runStdin cmdRunnerStdin, user, uid string, o addOpts, password string) error {
+	s, err := openSession(run, user, uid)
+	if err != nil {
+		return err
+	}
+	list, err := run("bw", bwListArgs(o.name), s.env)
+	if err != nil {
+		return err
+	}
+	existing, err := itemsNamed(list, o.name)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("an item named %q already exists; use `homelab vault edit %s --field password`", o.name, o.name)
+	}
+	item, err := newLoginItem(o, password)
+	if err != nil {
+		return err
+	}
+	if _, err := runStdin("bw", bwCreateArgs(), s.env, bwEncode(item)); err != nil {
+		return fmt.Errorf("bw create item failed: %w", err)
+	}
+	return nil
+}
+
+// editItem sets field on the one item named name.
+func editItem(run cmdRunner, runStdin cmdRunnerStdin, user, uid, name, field, value string) error {
+	s, err := openSession(run, user, uid)
+	if err != nil {
+		return err
+	}
+	list, err := run("bw", bwListArgs(name), s.env)
+	if err != nil {
+		return err
+	}
+	items, err := itemsNamed(list, name)
+	if err != nil {
+		return err
+	}
+	switch len(items) {
+	case 0:
+		return fmt.Errorf("no item named exactly %q (see `homelab vault search %s`)", name, name)
+	case 1:
+	default:
+		return fmt.Errorf("%d items are named %q; rename or remove the duplicates in the web vault first", len(items), name)
+	}
+	item := items[0]
+	id, _ := item["id"].(string)
+	if id == "" {
+		return fmt.Errorf("bw item %q has no id", name)
+	}
+	if err := setItemField(item, field, value); err != nil {
+		return err
+	}
+	b, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if _, err := runStdin("bw", bwEditArgs(id), s.env, bwEncode(b)); err != nil {
+		return fmt.Errorf("bw edit item failed: %w", err)
+	}
+	return nil
+}
+
+func vaultAdd(args []string) error {
+	hardenProcess()
+	ensureVaultToken()
+	o, err := parseAddArgs(args)
+	if err != nil {
+		return err
+	}
+	var password string
+	if o.generate > 0 {
+		if password, err = generateSecret(o.generate); err != nil {
+			return err
+		}
+	} else {
+		if password, err = readSecretValue("Password for " + o.name + ": "); err != nil {
+			return err
+		}
+		if password == "" {
+			return fmt.Errorf("empty password; aborting (nothing stored)")
+		}
+	}
+	uid := vaultCurrentUID()
+	unlock, err := withUserLock(uid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	user := vaultCurrentUser()
+	if err := addItem(realRunner, realRunnerStdin, user, uid, o, password); err != nil {
+		return err
+	}
+	writeOpLog(opRecord{User: user, Verb: "add", PID: os.Getpid(), PPID: os.Getppid(), ParentComm: parentComm(os.Getppid()), ItemName: o.name})
+	fmt.Fprintln(os.Stderr, "stored "+o.name)
+	if o.generate > 0 {
+		// Stored first, so the value is never handed out unsaved.
+		emitSecret(password)
+	}
+	return nil
+}
+
+func vaultEdit(args []string) error {
+	hardenProcess()
+	ensureVaultToken()
+	pos := positionalsSkipping(args, map[string]bool{"--field": true, "--generate": true})
+	field := flagValue(args, "--field")
+	if len(pos) != 1 || field == "" {
+		return fmt.Errorf("usage: homelab vault edit <name> --field password|username|uri|notes|totp|<custom> [--generate N]")
+	}
+	name := pos[0]
+	var value string
+	var err error
+	if v := flagValue(args, "--generate"); v != "" {
+		n, aerr := strconv.Atoi(v)
+		if aerr != nil || n < 8 || n > 128 {
+			return fmt.Errorf("bad --generate %q: want a length from 8 to 128", v)
+		}
+		if value, err = generateSecret(n); err != nil {
+			return err
+		}
+	} else {
+		if value, err = readSecretValue("New " + field + " for " + name + ": "); err != nil {
+			return err
+		}
+		// Only notes, uri and totp may be cleared; an empty secret is a mistake.
+		if value == "" && field != "notes" && field != "uri" && field != "totp" {
+			return fmt.Errorf("empty value; aborting (nothing changed)")
+		}
+	}
+	uid := vaultCurrentUID()
+	unlock, err := withUserLock(uid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	user := vaultCurrentUser()
+	if err := editItem(realRunner, realRunnerStdin, user, uid, name, field, value); err != nil {
+		return err
+	}
+	writeOpLog(opRecord{User: user, Verb: "edit", PID: os.Getpid(), PPID: os.Getppid(), ParentComm: parentComm(os.Getppid()), ItemName: name})
+	fmt.Fprintf(os.Stderr, "updated %s of %s\n", field, name)
+	if flagValue(args, "--generate") != "" {
+		emitSecret(value)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func workCommands() []Command {
@@ -31,6 +32,35 @@ func flagValue(args []string, name string) string {
 	return ""
 }
 
+// flagValues collects every occurrence of a repeatable `--name value` /
+// `--name=value` flag, in order.
+func flagValues(args []string, name string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == name && i+1 < len(args) {
+			out = append(out, args[i+1])
+			i++
+		} else if strings.HasPrefix(a, name+"=") {
+			out = append(out, strings.TrimPrefix(a, name+"="))
+		}
+	}
+	return out
+}
+
+// durationFlag reads an optional positive duration flag, def when absent.
+func durationFlag(args []string, name string, def time.Duration) (time.Duration, error) {
+	v := flagValue(args, name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("bad %s %q: want a positive duration like 90s or 15m", name, v)
+	}
+	return d, nil
+}
+
 func remotesOrEmpty(repoRoot string) []string {
 	r, _ := gitRemotes(repoRoot)
 	return r
@@ -72,48 +102,66 @@ func workStart(args []string) error {
 // verify, push HEAD:master (retrying on non-fast-forward), with a feature-branch
 // fallback when the direct push is rejected (e.g. branch protection).
 func workLand(args []string) error {
+	r, err := landBranch(args)
+	if err != nil || r.viaPR {
+		return err
+	}
+	if containsArg(args, "--no-ci-watch") {
+		fmt.Println("homelab: --no-ci-watch set; not waiting for CI.")
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "homelab: watching CI for the landed commit...")
+	if err := ciWatch([]string{r.sha}); err != nil {
+		return fmt.Errorf("landed, but CI did not go green: %w", err)
+	}
+	return nil
+}
+
+// landResult records what landBranch did: either sha landed on master, or the
+// direct push was refused and branch was pushed for a PR instead (viaPR).
+type landResult struct {
+	branch string
+	sha    string
+	viaPR  bool
+}
+
+// landBranch is the land half of `work land`, without the CI watch, so `ship`
+// can chain its own stages after it.
+func landBranch(args []string) (landResult, error) {
 	verifyCmd := flagValue(args, "--verify-cmd")
 	cwd, _ := os.Getwd()
 	repoRoot, err := gitRepoRoot(cwd)
 	if err != nil {
-		return fmt.Errorf("not in a git repository: %w", err)
+		return landResult{}, fmt.Errorf("not in a git repository: %w", err)
 	}
 	branch, err := gitOutput(repoRoot, "rev-parse", "--abbrev-ref", "HEAD")
 	if err != nil {
-		return err
+		return landResult{}, err
 	}
 	if branch == "master" || branch == "main" {
-		return fmt.Errorf("refusing to land: already on %s", branch)
+		return landResult{}, fmt.Errorf("refusing to land: already on %s", branch)
 	}
 	remote := preferRemote(remotesOrEmpty(repoRoot))
 	if remote == "" {
-		return fmt.Errorf("no git remote configured in %s", repoRoot)
+		return landResult{}, fmt.Errorf("no git remote configured in %s", repoRoot)
 	}
 	flags := cryptFlagsFor(repoRoot)
 
 	if err := gitStream(repoRoot, flags, "fetch", remote); err != nil {
-		return fmt.Errorf("fetch failed: %w", err)
+		return landResult{}, fmt.Errorf("fetch failed: %w", err)
 	}
 	if err := gitStream(repoRoot, flags, "merge", "--no-edit", remote+"/master"); err != nil {
-		return fmt.Errorf("merging %s/master failed — resolve conflicts then re-run `homelab work land`: %w", remote, err)
+		return landResult{}, fmt.Errorf("merging %s/master failed — resolve conflicts then re-run `homelab work land`: %w", remote, err)
 	}
 	if err := runVerify(repoRoot, verifyCmd, containsArg(args, "--no-verify")); err != nil {
-		return fmt.Errorf("not landing: %w", err)
+		return landResult{}, fmt.Errorf("not landing: %w", err)
 	}
 	if err := pushWithRetry(repoRoot, flags, remote, 3); err != nil {
-		return landFallback(repoRoot, flags, remote, branch, err)
+		return landResult{branch: branch, viaPR: true}, landFallback(repoRoot, flags, remote, branch, err)
 	}
 	fmt.Printf("homelab: landed %s -> %s/master.\n", branch, remote)
-	if containsArg(args, "--no-ci-watch") {
-		fmt.Println("homelab: --no-ci-watch set; not waiting for CI.")
-		return nil
-	}
 	landed, _ := gitOutput(repoRoot, "rev-parse", "HEAD")
-	fmt.Fprintln(os.Stderr, "homelab: watching CI for the landed commit...")
-	if err := ciWatch([]string{landed}); err != nil {
-		return fmt.Errorf("landed, but CI did not go green: %w", err)
-	}
-	return nil
+	return landResult{branch: branch, sha: landed}, nil
 }
 
 // runVerify runs the explicit --verify-cmd, else auto-detects (go test). If
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// crowdsecAllowlist is the centralized allowlist (CrowdSec ≥1.6.8) the
+// `crowdsec allowlist` verbs manage. LAPI refuses decisions on anything in
+// it, so an address added here cannot be banned by hand, by a scenario, or by
+// a bulk import. The parser whitelist in stacks/crowdsec stays for the
+// reviewable, permanent entries; this list is the quick operational one.
+const crowdsecAllowlist = "homelab-manual"
+
+// csDecision is one decision as cscli/LAPI report it.
+type csDecision struct {
+	ID       int64  `json:"id"`
+	Origin   string `json:"origin"`
+	Type     string `json:"type"`
+	Scope    string `json:"scope"`
+	Value    string `json:"value"`
+	Duration string `json:"duration"`
+	Scenario string `json:"scenario"`
+}
+
+// csAlert is one alert — what a scenario (or a manual ban) recorded — with the
+// decisions it produced.
+type csAlert struct {
+	ID          int64        `json:"id"`
+	Scenario    string       `json:"scenario"`
+	Message     string       `json:"message"`
+	EventsCount int          `json:"events_count"`
+	StartAt     string       `json:"start_at"`
+	StopAt      string       `json:"stop_at"`
+	CreatedAt   string       `json:"created_at"`
+	Decisions   []csDecision `json:"decisions"`
+	Source      struct {
+		IP     string `json:"ip"`
+		Range  string `json:"range"`
+		Scope  string `json:"scope"`
+		Value  string `json:"value"`
+		AsName string `json:"as_name"`
+		Cn     string `json:"cn"`
+	} `json:"source"`
+}
+
+// csAllowlistItem is one allowlist entry.
+type csAllowlistItem struct {
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+	Expiration  string `json:"expiration,omitempty"`
+}
+
+// parseCSAlerts decodes `cscli ... -o json` alert output; cscli prints
+// "null" for no results.
+func parseCSAlerts(out []byte) ([]csAlert, error) {
+	var alerts []csAlert
+	if err := json.Unmarshal(out, &alerts); err != nil {
+		return nil, fmt.Errorf("cannot parse CrowdSec output: %w", err)
+	}
+	return alerts, nil
+}
+
+// activeBans is every value with a live ban decision, across the alerts.
+func activeBans(alerts []csAlert) map[string]bool {
+	banned := map[string]bool{}
+	for _, a := range alerts {
+		for _, d := range a.Decisions {
+			if d.Type == "ban" {
+				banned[d.Value] = true
+			}
+		}
+	}
+	return banned
+}
+
+// parseBanFile reads one IP or CIDR per line; `#` starts a comment and blank
+// lines are skipped. Every line is validated before anything is banned, so a
+// typo on line 40 doesn't leave 39 bans applied. Duplicates are dropped.
+func parseBanFile(content, reason string) ([]string, error) {
+	var targets, bad []string
+	seen := map[string]bool{}
+	for i, line := range strings.Split(content, "\n") {
+		if j := strings.IndexByte(line, '#'); j >= 0 {
+			line = line[:j]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		if err := validateBanRequest(line, reason); err != nil {
+			bad = append(bad, fmt.Sprintf("line %d: %v", i+1, err))
+			continue
+		}
+		seen[line] = true
+		targets = append(targets, line)
+	}
+	if len(bad) > 0 {
+		return nil, fmt.Errorf("nothing banned; fix these lines first:\n  %s", strings.Join(bad, "\n  "))
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no addresses in the file")
+	}
+	return targets, nil
+}
+
+// toNet parses an IP or CIDR as a network (an IP is its own /32 or /128).
+func toNet(s string) *net.IPNet {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+}
+
+// allowlistCovering returns the allowlist entry overlapping target, if any:
+// banning a range that contains an allowlisted address would ban it too.
+func allowlistCovering(items []csAllowlistItem, target string) (csAllowlistItem, bool) {
+	t := toNet(target)
+	if t == nil {
+		return csAllowlistItem{}, false
+	}
+	for _, it := range items {
+		a := toNet(it.Value)
+		if a != nil && (a.Contains(t.IP) || t.Contains(a.IP)) {
+			return it, true
+		}
+	}
+	return csAllowlistItem{}, false
+}
+
+// bulkResult is one row of a bulk action's summary.
+type bulkResult struct {
+	Target string
+	Result string
+	Failed bool
+}
+
+// formatBulkSummary renders the summary table and a count per result.
+func formatBulkSummary(results []bulkResult) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tRESULT")
+	counts := map[string]int{}
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\n", r.Target, r.Result)
+		key := r.Result
+		if r.Failed {
+			key = "failed"
+		}
+		counts[key]++
+	}
+	w.Flush()
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%d %s", counts[k], k)
+	}
+	fmt.Fprintf(&b, "%d addresses: %s\n", len(results), strings.Join(parts, ", "))
+	return b.String()
+}
+
+// formatAllowlist renders the allowlist entries.
+func formatAllowlist(items []csAllowlistItem) string {
+	if len(items) == 0 {
+		return fmt.Sprintf("(allowlist %s is empty)\n", crowdsecAllowlist)
+	}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VALUE\tADDED\tDESCRIPTION")
+	for _, it := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", it.Value, shortTime(it.CreatedAt), it.Description)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// shortTime trims an RFC 3339 timestamp to minutes for tables; anything
+// unparseable is shown as-is.
+func shortTime(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return t.UTC().Format("2006-01-02 15:04")
+}
+
+// csOriginLabel spells out the decision origins that did not come from our
+// own scenarios or cscli, so a blocklist entry is not mistaken for local
+// detection.
+func csOriginLabel(origin string) string {
+	switch strings.ToUpper(origin) {
+	case "CAPI":
+		return origin + " (community blocklist)"
+	case "LISTS":
+		return origin + " (subscribed blocklist)"
+	}
+	return origin
+}
+
+// formatHistory renders an address's alerts oldest first, each with the
+// decisions it produced and whether they are still in force.
+func formatHistory(ip string, alerts []csAlert, active map[int64]bool) string {
+	if len(alerts) == 0 {
+		return fmt.Sprintf("no CrowdSec alerts for %s\n", ip)
+	}
+	sorted := append([]csAlert(nil), alerts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt < sorted[j].CreatedAt })
+	var b strings.Builder
+	for _, a := range sorted {
+		events := ""
+		if a.EventsCount > 0 {
+			events = fmt.Sprintf(" (%d events)", a.EventsCount)
+		}
+		fmt.Fprintf(&b, "%s  %s%s\n", shortTime(a.CreatedAt), a.Scenario, events)
+		if len(a.Decisions) == 0 {
+			b.WriteString("    no decision\n")
+		}
+		for _, d := range a.Decisions {
+			state := "expired/removed"
+			if active[d.ID] {
+				state = "ACTIVE"
+			}
+			fmt.Fprintf(&b, "    %s %s for %s via %s — %s\n", d.Type, d.Value, d.Duration, csOriginLabel(d.Origin), state)
+		}
+	}
+	return b.String()
+}
+
+// formatExplain renders why ip is (or was) blocked: the alerts behind its
+// active decisions, with the scenario, event count, window and CrowdSec's
+// message, and where the address is from. Alerts with no active decision are
+// only counted — `crowdsec history` lists them.
+func formatExplain(ip string, alerts []csAlert, active map[int64]bool) string {
+	var b strings.Builder
+	var current []csAlert
+	for _, a := range alerts {
+		for _, d := range a.Decisions {
+			if active[d.ID] {
+				current = append(current, a)
+				break
+			}
+		}
+	}
+	from := ""
+	for _, a := range alerts {
+		if a.Source.AsName != "" || a.Source.Cn != "" {
+			from = strings.TrimSpace(a.Source.AsName + " " + a.Source.Cn)
+			break
+		}
+	}
+	fmt.Fprintf(&b, "%s", ip)
+	if from != "" {
+		fmt.Fprintf(&b, "  (%s)", from)
+	}
+	b.WriteString("\n")
+	if len(current) == 0 {
+		b.WriteString("no active decision for this address\n")
+	}
+	sort.SliceStable(current, func(i, j int) bool { return current[i].CreatedAt < current[j].CreatedAt })
+	for _, a := range current {
+		for _, d := range a.Decisions {
+			if active[d.ID] {
+				fmt.Fprintf(&b, "ACTIVE %s on %s via %s, %s left\n", d.Type, d.Value, csOriginLabel(d.Origin), d.Duration)
+			}
+		}
+		fmt.Fprintf(&b, "  scenario: %s\n", a.Scenario)
+		if a.EventsCount > 0 {
+			fmt.Fprintf(&b, "  events:   %d between %s and %s\n", a.EventsCount, shortTime(a.StartAt), shortTime(a.StopAt))
+		}
+		if a.Message != "" {
+			fmt.Fprintf(&b, "  message:  %s\n", a.Message)
+		}
+	}
+	if past := len(alerts) - len(current); past > 0 {
+		fmt.Fprintf(&b, "%d earlier alert(s) without an active decision: homelab crowdsec history %s\n", past, ip)
+	}
+	return b.String()
+}
+
+// csDecisionRow is one decision in force, flattened with its alert's source
+// for `crowdsec decisions`.
+type csDecisionRow struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Scope    string `json:"scope"`
+	Value    string `json:"value"`
+	Origin   string `json:"origin"`
+	Scenario string `json:"scenario"`
+	Expires  string `json:"expires_in"`
+	AlertID  int64  `json:"alert_id"`
+	AsName   string `json:"as_name,omitempty"`
+	Country  string `json:"country,omitempty"`
+}
+
+// decisionRows flattens alerts into their decisions, newest alert first.
+func decisionRows(alerts []csAlert) []csDecisionRow {
+	var rows []csDecisionRow
+	for _, a := range alerts {
+		for _, d := range a.Decisions {
+			rows = append(rows, csDecisionRow{
+				ID: d.ID, Type: d.Type, Scope: d.Scope, Value: d.Value, Origin: d.Origin,
+				Scenario: d.Scenario, Expires: d.Duration, AlertID: a.ID,
+				AsName: a.Source.AsName, Country: a.Source.Cn,
+			})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].AlertID != rows[j].AlertID {
+			return rows[i].AlertID > rows[j].AlertID
+		}
+		return rows[i].ID > rows[j].ID
+	})
+	return rows
+}
+
+// formatDecisions renders the decision table.
+func formatDecisions(rows []csDecisionRow) string {
+	if len(rows) == 0 {
+		return "(no decisions in force)\n"
+	}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSOURCE\tTYPE\tORIGIN\tREASON\tEXPIRES IN\tAS/COUNTRY")
+	for _, r := range rows {
+		from := strings.TrimSpace(r.AsName + " " + r.Country)
+		fmt.Fprintf(w, "%d\t%s:%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Scope, r.Value, r.Type, r.Origin, r.Scenario, r.Expires, from)
+	}
+	w.Flush()
+	fmt.Fprintf(&b, "%d decision(s)\n", len(rows))
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBanFile(t *testing.T) {
+	got, err := parseBanFile("# scanners seen 2026-10-18\n1.2.3.4\n\n5.6.7.0/24  # whole range\n1.2.3.4\n", "scanner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "1.2.3.4,5.6.7.0/24" {
+		t.Fatalf("targets = %v", got)
+	}
+}
+
+func TestParseBanFileRejectsWholeFileOnBadLine(t *testing.T) {
+	_, err := parseBanFile("1.2.3.4\nexample.com\n5.6.7.8\n", "scanner")
+	if err == nil {
+		t.Fatal("a bad line must fail the whole file, so nothing is half-applied")
+	}
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("error should name the line: %v", err)
+	}
+	if _, err := parseBanFile("# only comments\n\n", "scanner"); err == nil {
+		t.Fatal("an empty file should be an error")
+	}
+}
+
+func TestAllowlistCovering(t *testing.T) {
+	items := []csAllowlistItem{
+		{Value: "137.220.71.46", Description: "London WAN egress"},
+		{Value: "10.0.20.0/24", Description: "LAN"},
+	}
+	cases := map[string]string{
+		"137.220.71.46":   "137.220.71.46", // exact
+		"137.220.71.0/24": "137.220.71.46", // a range containing an allowlisted address
+		"10.0.20.7":       "10.0.20.0/24",  // inside an allowlisted range
+		"8.8.8.8":         "",
+		"bogus":           "",
+	}
+	for target, want := range cases {
+		it, ok := allowlistCovering(items, target)
+		if ok != (want != "") || it.Value != want {
+			t.Errorf("allowlistCovering(%q) = %q, %v; want %q", target, it.Value, ok, want)
+		}
+	}
+}
+
+func TestActiveBans(t *testing.T) {
+	alerts, err := parseCSAlerts([]byte(`[{"id":1,"decisions":[{"id":10,"type":"ban","value":"1.2.3.4"},{"id":11,"type":"captcha","value":"5.6.7.8"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := activeBans(alerts)
+	if !b["1.2.3.4"] || b["5.6.7.8"] {
+		t.Fatalf("activeBans = %v, want only the ban", b)
+	}
+	if alerts, err := parseCSAlerts([]byte("null")); err != nil || alerts != nil {
+		t.Fatalf("cscli's null should parse as no alerts, got %v, %v", alerts, err)
+	}
+}
+
+func TestFormatBulkSummary(t *testing.T) {
+	out := formatBulkSummary([]bulkResult{
+		{Target: "1.2.3.4", Result: "banned 24h"},
+		{Target: "5.6.7.8", Result: "already banned"},
+		{Target: "9.9.9.9", Result: "failed: cscli decisions add: exit 1", Failed: true},
+	})
+	if !strings.Contains(out, "TARGET") || !strings.Contains(out, "9.9.9.9") {
+		t.Fatalf("missing table:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "3 addresses: 1 already banned, 1 banned 24h, 1 failed\n") {
+		t.Fatalf("failures should be counted together:\n%s", out)
+	}
+}
+
+func TestFormatHistory(t *testing.T) {
+	alerts := []csAlert{
+		{ID: 2, Scenario: "manual 'ban' from 'homelab'", CreatedAt: "2026-10-18T09:00:00Z",
+			Decisions: []csDecision{{ID: 20, Type: "ban", Value: "1.2.3.4", Duration: "23h10m", Origin: "cscli"}}},
+		{ID: 1, Scenario: "crowdsecurity/http-probing", EventsCount: 11, CreatedAt: "2026-10-01T12:30:00Z",
+			Decisions: []csDecision{{ID: 5, Type: "ban", Value: "1.2.3.4", Duration: "4h", Origin: "crowdsec"}}},
+	}
+	out := formatHistory("1.2.3.4", alerts, map[int64]bool{20: true})
+	first := strings.Index(out, "http-probing")
+	second := strings.Index(out, "manual")
+	if first < 0 || second < 0 || first > second {
+		t.Fatalf("alerts should be oldest first:\n%s", out)
+	}
+	if !strings.Contains(out, "(11 events)") || !strings.Contains(out, "— ACTIVE") || !strings.Contains(out, "— expired/removed") {
+		t.Fatalf("unexpected history:\n%s", out)
+	}
+	if got := formatHistory("8.8.8.8", nil, nil); got != "no CrowdSec alerts for 8.8.8.8\n" {
+		t.Fatalf("empty history = %q", got)
+	}
+}
+
+func TestParseCrowdsecBanArgsFromFile(t *testing.T) {
+	_, file, reason, _, err := parseCrowdsecBanArgs([]string{"--from-file", "ips.txt", "--reason", "scanners"})
+	if err != nil || file != "ips.txt" || reason != "scanners" {
+		t.Fatalf("got file=%q reason=%q err=%v", file, reason, err)
+	}
+	if _, _, _, _, err := parseCrowdsecBanArgs([]string{"1.2.3.4", "--from-file", "ips.txt"}); err == nil {
+		t.Fatal("a target and --from-file together should be refused")
+	}
+}
+
+func TestFormatExplain(t *testing.T) {
+	a := csAlert{ID: 1, Scenario: "crowdsecurity/http-probing", EventsCount: 11, Message: "Ip 1.2.3.4 performed 'crowdsecurity/http-probing' (11 events over 4s)",
+		CreatedAt: "2026-10-19T08:00:00Z", StartAt: "2026-10-19T07:59:56Z", StopAt: "2026-10-19T08:00:00Z",
+		Decisions: []csDecision{{ID: 7, Type: "ban", Value: "1.2.3.4", Origin: "crowdsec", Duration: "3h10m"}}}
+	a.Source.AsName, a.Source.Cn = "EXAMPLE-AS", "NL"
+	old := csAlert{ID: 0, Scenario: "crowdsecurity/http-bad-user-agent", CreatedAt: "2026-09-01T00:00:00Z"}
+	out := formatExplain("1.2.3.4", []csAlert{old, a}, map[int64]bool{7: true})
+	for _, want := range []string{
+		"1.2.3.4  (EXAMPLE-AS NL)",
+		"ACTIVE ban on 1.2.3.4 via crowdsec, 3h10m left",
+		"scenario: crowdsecurity/http-probing",
+		"events:   11 between 2026-10-19 07:59 and 2026-10-19 08:00",
+		"1 earlier alert(s) without an active decision",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("explain missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "bad-user-agent") {
+		t.Errorf("inactive alerts are only counted:\n%s", out)
+	}
+	capi := csAlert{ID: 9, Scenario: "update : +15000/-0 IPs", Decisions: []csDecision{{ID: 8, Type: "ban", Value: "5.6.7.8", Origin: "CAPI", Duration: "100h"}}}
+	if out := formatExplain("5.6.7.8", []csAlert{capi}, map[int64]bool{8: true}); !strings.Contains(out, "ACTIVE ban on 5.6.7.8 via CAPI (community blocklist), 100h left") {
+		t.Errorf("CAPI origin not labelled:\n%s", out)
+	}
+	if out := formatExplain("8.8.8.8", nil, nil); !strings.Contains(out, "no active decision") {
+		t.Errorf("no alerts: %q", out)
+	}
+}
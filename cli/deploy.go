@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// workloadKinds maps the accepted `deploy wait` kind spellings to the kubectl
+// resource name. Deployments are the default when a target omits the kind.
+var workloadKinds = map[string]string{
+	"deploy": "deployment", "deployment": "deployment", "deployments": "deployment",
+	"sts": "statefulset", "statefulset": "statefulset", "statefulsets": "statefulset",
+	"ds": "daemonset", "daemonset": "daemonset", "daemonsets": "daemonset",
+}
+
+// workloadRef names one rollout-able workload.
+type workloadRef struct {
+	ns   string
+	kind string // deployment | statefulset | daemonset
+	name string
+}
+
+func (w workloadRef) String() string { return w.ns + "/" + w.kind + "/" + w.name }
+
+// objectRef is the kubectl `<kind>/<name>` form.
+func (w workloadRef) objectRef() string { return w.kind + "/" + w.name }
+
+// deployTarget is a parsed `deploy wait` target: either one named workload, or
+// every deployment/statefulset/daemonset in ns matching selector.
+type deployTarget struct {
+	ns       string
+	one      workloadRef
+	selector string
+}
+
+// parseDeployTarget reads `<ns>/<name>`, `<ns>/<kind>/<name>`, or `<ns>` plus
+// a label selector.
+func parseDeployTarget(target, selector string) (deployTarget, error) {
+	parts := strings.Split(target, "/")
+	if selector != "" {
+		if len(parts) != 1 || parts[0] == "" {
+			return deployTarget{}, fmt.Errorf("with -l, the target is just the namespace (got %q)", target)
+		}
+		return deployTarget{ns: parts[0], selector: selector}, nil
+	}
+	switch len(parts) {
+	case 2:
+		if parts[0] != "" && parts[1] != "" {
+			return deployTarget{ns: parts[0], one: workloadRef{ns: parts[0], kind: "deployment", name: parts[1]}}, nil
+		}
+	case 3:
+		kind, ok := workloadKinds[parts[1]]
+		if !ok {
+			return deployTarget{}, fmt.Errorf("unknown workload kind %q (want deploy, sts or ds)", parts[1])
+		}
+		if parts[0] != "" && parts[2] != "" {
+			return deployTarget{ns: parts[0], one: workloadRef{ns: parts[0], kind: kind, name: parts[2]}}, nil
+		}
+	}
+	return deployTarget{}, fmt.Errorf("target must be <ns>/<deploy>, <ns>/<sts|ds|deploy>/<name>, or <ns> -l <selector>: %q", target)
+}
+
+// workloadObject is the subset of a deployment/statefulset/daemonset the wait
+// reads.
+type workloadObject struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name       string `json:"name"`
+		Namespace  string `json:"namespace"`
+		Generation int64  `json:"generation"`
+	} `json:"metadata"`
+	Spec struct {
+		Selector struct {
+			MatchLabels map[string]string `json:"matchLabels"`
+		} `json:"selector"`
+		Template struct {
+			Spec podSpecImages `json:"spec"`
+		} `json:"template"`
+	} `json:"spec"`
+	Status struct {
+		UpdateRevision string `json:"updateRevision"` // statefulsets only
+	} `json:"status"`
+}
+
+type podSpecImages struct {
+	Containers []struct {
+		Image string `json:"image"`
+	} `json:"containers"`
+	InitContainers []struct {
+		Image string `json:"image"`
+	} `json:"initContainers"`
+}
+
+// images lists every container and init container image of a pod spec.
+func (p podSpecImages) images() []string {
+	var out []string
+	for _, c := range p.InitContainers {
+		out = append(out, c.Image)
+	}
+	for _, c := range p.Containers {
+		out = append(out, c.Image)
+	}
+	return out
+}
+
+func (w workloadObject) ref() workloadRef {
+	return workloadRef{ns: w.Metadata.Namespace, kind: strings.ToLower(w.Kind), name: w.Metadata.Name}
+}
+
+// parseWorkloads accepts either a single object or a List, as kubectl returns
+// for `get <kind> <name> -o json` and `get deploy,sts,ds -l … -o json`.
+func parseWorkloads(kubectlJSON string) ([]workloadObject, error) {
+	var list struct {
+		Kind  string           `json:"kind"`
+		Items []workloadObject `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(kubectlJSON), &list); err != nil {
+		return nil, fmt.Errorf("cannot parse workload JSON: %w", err)
+	}
+	if list.Kind == "List" || strings.HasSuffix(list.Kind, "List") {
+		return list.Items, nil
+	}
+	var one workloadObject
+	if err := json.Unmarshal([]byte(kubectlJSON), &one); err != nil {
+		return nil, fmt.Errorf("cannot parse workload JSON: %w", err)
+	}
+	return []workloadObject{one}, nil
+}
+
+// imageRepo strips the tag/digest from an image reference, keeping a registry
+// port intact (registry:5000/app:tag → registry:5000/app).
+func imageRepo(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// imagesMatchSHA reports whether the workload runs sha everywhere it should.
+// A repo "carries" the sha when any of its images reference it; every other
+// image from that same repo — typically a migration init container built from
+// the app image — must reference it too, or the pod would start half-old.
+// Sidecars from unrelated repos are ignored. stale lists the laggards.
+func imagesMatchSHA(images []string, sha string) (ok bool, stale []string) {
+	carrying := map[string]bool{}
+	for _, img := range images {
+		if strings.Contains(img, sha) {
+			carrying[imageRepo(img)] = true
+		}
+	}
+	if len(carrying) == 0 {
+		return false, nil
+	}
+	for _, img := range images {
+		if carrying[imageRepo(img)] && !strings.Contains(img, sha) {
+			stale = append(stale, img)
+		}
+	}
+	return len(stale) == 0, stale
+}
+
+// selectorString renders matchLabels as a kubectl -l selector, sorted so it's
+// stable.
+func selectorString(labels map[string]string) string {
+	var kv []string
+	for k, v := range labels {
+		kv = append(kv, k+"="+v)
+	}
+	sort.Strings(kv)
+	return strings.Join(kv, ",")
+}
+
+// podObject is the subset of a pod the failure report reads.
+type podObject struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Labels          map[string]string `json:"labels"`
+		OwnerReferences []struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec   podSpecImages `json:"spec"`
+	Status struct {
+		Phase             string `json:"phase"`
+		ContainerStatuses []struct {
+			Ready bool `json:"ready"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+func (p podObject) ready() bool {
+	if p.Status.Phase != "Running" || len(p.Status.ContainerStatuses) == 0 {
+		return false
+	}
+	for _, c := range p.Status.ContainerStatuses {
+		if !c.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// owner returns the name of the pod's controller of the given kind ("" if none).
+func (p podObject) owner(kind string) string {
+	for _, o := range p.Metadata.OwnerReferences {
+		if o.Kind == kind {
+			return o.Name
+		}
+	}
+	return ""
+}
+
+func parsePods(kubectlJSON string) ([]podObject, error) {
+	var list struct {
+		Items []podObject `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(kubectlJSON), &list); err != nil {
+		return nil, fmt.Errorf("cannot parse pod JSON: %w", err)
+	}
+	return list.Items, nil
+}
+
+// newestReplicaSet picks the ReplicaSet with the highest
+// deployment.kubernetes.io/revision from a `get rs -o json` listing.
+func newestReplicaSet(kubectlJSON string) (string, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name        string            `json:"name"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(kubectlJSON), &list); err != nil {
+		return "", fmt.Errorf("cannot parse replicaset JSON: %w", err)
+	}
+	best, bestRev := "", int64(-1)
+	for _, rs := range list.Items {
+		rev, err := strconv.ParseInt(rs.Metadata.Annotations["deployment.kubernetes.io/revision"], 10, 64)
+		if err == nil && rev > bestRev {
+			best, bestRev = rs.Metadata.Name, rev
+		}
+	}
+	return best, nil
+}
+
+// stuckPod is a pod that has not made it onto the current revision.
+type stuckPod struct {
+	name   string
+	reason string
+}
+
+// findStuckPods returns the pods of w that are on an old revision (old
+// ReplicaSet for deployments, old controller revision for statefulsets, old
+// template generation for daemonsets), or on the current revision but not
+// Ready. current is the newest ReplicaSet name for deployments and unused
+// otherwise.
+func findStuckPods(w workloadObject, pods []podObject, current string) []stuckPod {
+	var out []stuckPod
+	for _, p := range pods {
+		old := ""
+		switch strings.ToLower(w.Kind) {
+		case "deployment":
+			if rs := p.owner("ReplicaSet"); current != "" && rs != current {
+				old = "on old ReplicaSet " + rs
+			}
+		case "statefulset":
+			if h := p.Metadata.Labels["controller-revision-hash"]; w.Status.UpdateRevision != "" && h != w.Status.UpdateRevision {
+				old = "on old revision " + h
+			}
+		case "daemonset":
+			if g := p.Metadata.Labels["pod-template-generation"]; g != "" && g != strconv.FormatInt(w.Metadata.Generation, 10) {
+				old = "on old template generation " + g
+			}
+		}
+		switch {
+		case old != "":
+			out = append(out, stuckPod{name: p.Metadata.Name, reason: old})
+		case !p.ready():
+			out = append(out, stuckPod{name: p.Metadata.Name, reason: "new revision but not Ready (" + p.Status.Phase + ")"})
+		}
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDeployTarget(t *testing.T) {
+	cases := []struct {
+		target, sel string
+		want        deployTarget
+	}{
+		{"tripit/tripit", "", deployTarget{ns: "tripit", one: workloadRef{ns: "tripit", kind: "deployment", name: "tripit"}}},
+		{"dbaas/sts/mysql-standalone", "", deployTarget{ns: "dbaas", one: workloadRef{ns: "dbaas", kind: "statefulset", name: "mysql-standalone"}}},
+		{"monitoring/ds/node-exporter", "", deployTarget{ns: "monitoring", one: workloadRef{ns: "monitoring", kind: "daemonset", name: "node-exporter"}}},
+		{"immich/deploy/immich-server", "", deployTarget{ns: "immich", one: workloadRef{ns: "immich", kind: "deployment", name: "immich-server"}}},
+		{"immich", "app.kubernetes.io/part-of=immich", deployTarget{ns: "immich", selector: "app.kubernetes.io/part-of=immich"}},
+	}
+	for _, c := range cases {
+		got, err := parseDeployTarget(c.target, c.sel)
+		if err != nil {
+			t.Errorf("parseDeployTarget(%q, %q) error: %v", c.target, c.sel, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseDeployTarget(%q, %q) = %+v, want %+v", c.target, c.sel, got, c.want)
+		}
+	}
+	for _, bad := range [][2]string{{"tripit", ""}, {"a/cronjob/x", ""}, {"a//", ""}, {"a/b", "k=v"}, {"", "k=v"}} {
+		if _, err := parseDeployTarget(bad[0], bad[1]); err == nil {
+			t.Errorf("parseDeployTarget(%q, %q) should fail", bad[0], bad[1])
+		}
+	}
+}
+
+func TestImageRepo(t *testing.T) {
+	cases := map[string]string{
+		"ghcr.io/viktorbarzin/tripit:abc123":   "ghcr.io/viktorbarzin/tripit",
+		"registry:5000/app:v1":                 "registry:5000/app",
+		"registry:5000/app":                    "registry:5000/app",
+		"redis@sha256:deadbeef":                "redis",
+		"ghcr.io/x/app:abc123@sha256:deadbeef": "ghcr.io/x/app",
+	}
+	for in, want := range cases {
+		if got := imageRepo(in); got != want {
+			t.Errorf("imageRepo(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestImagesMatchSHA(t *testing.T) {
+	// app + sidecar from another repo: the sidecar never carries our sha.
+	if ok, stale := imagesMatchSHA([]string{"ghcr.io/v/app:abc123", "redis:7"}, "abc123"); !ok || stale != nil {
+		t.Errorf("unrelated sidecar must not block: ok=%v stale=%v", ok, stale)
+	}
+	// migration init container built from the app image but still on the old tag.
+	ok, stale := imagesMatchSHA([]string{"ghcr.io/v/app:old999", "ghcr.io/v/app:abc123"}, "abc123")
+	if ok || !reflect.DeepEqual(stale, []string{"ghcr.io/v/app:old999"}) {
+		t.Errorf("half-old pod must not match: ok=%v stale=%v", ok, stale)
+	}
+	if ok, _ := imagesMatchSHA([]string{"ghcr.io/v/app:old999"}, "abc123"); ok {
+		t.Error("no image carrying the sha must not match")
+	}
+}
+
+func TestParseWorkloadsSingleAndList(t *testing.T) {
+	one := `{"kind":"StatefulSet","metadata":{"name":"db","namespace":"dbaas"},"spec":{"template":{"spec":{"initContainers":[{"image":"init:1"}],"containers":[{"image":"db:abc"}]}}}}`
+	ws, err := parseWorkloads(one)
+	if err != nil || len(ws) != 1 {
+		t.Fatalf("single: %v %v", ws, err)
+	}
+	if got := ws[0].Spec.Template.Spec.images(); !reflect.DeepEqual(got, []string{"init:1", "db:abc"}) {
+		t.Errorf("images() = %q, want init containers first then containers", got)
+	}
+	if r := ws[0].ref(); r.kind != "statefulset" || r.objectRef() != "statefulset/db" {
+		t.Errorf("ref() = %+v", r)
+	}
+	list := `{"kind":"List","items":[{"kind":"Deployment","metadata":{"name":"a"}},{"kind":"DaemonSet","metadata":{"name":"b"}}]}`
+	if ws, err := parseWorkloads(list); err != nil || len(ws) != 2 || ws[1].Kind != "DaemonSet" {
+		t.Fatalf("list: %+v %v", ws, err)
+	}
+}
+
+func TestNewestReplicaSet(t *testing.T) {
+	rs := `{"items":[
+	  {"metadata":{"name":"app-old","annotations":{"deployment.kubernetes.io/revision":"9"}}},
+	  {"metadata":{"name":"app-new","annotations":{"deployment.kubernetes.io/revision":"10"}}}]}`
+	if got, err := newestReplicaSet(rs); err != nil || got != "app-new" {
+		t.Fatalf("newestReplicaSet = %q, %v (revision must compare numerically)", got, err)
+	}
+}
+
+func TestFindStuckPods(t *testing.T) {
+	pods, err := parsePods(`{"items":[
+	  {"metadata":{"name":"app-new-1","ownerReferences":[{"kind":"ReplicaSet","name":"app-new"}]},"status":{"phase":"Running","containerStatuses":[{"ready":true}]}},
+	  {"metadata":{"name":"app-new-2","ownerReferences":[{"kind":"ReplicaSet","name":"app-new"}]},"status":{"phase":"Pending"}},
+	  {"metadata":{"name":"app-old-1","ownerReferences":[{"kind":"ReplicaSet","name":"app-old"}]},"status":{"phase":"Running","containerStatuses":[{"ready":true}]}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w workloadObject
+	w.Kind = "Deployment"
+	got := findStuckPods(w, pods, "app-new")
+	if len(got) != 2 || got[0].name != "app-new-2" || got[1].name != "app-old-1" {
+		t.Fatalf("findStuckPods = %+v, want the pending new pod and the old-RS pod", got)
+	}
+
+	var sts workloadObject
+	sts.Kind = "StatefulSet"
+	sts.Status.UpdateRevision = "db-rev2"
+	stsPods, _ := parsePods(`{"items":[
+	  {"metadata":{"name":"db-0","labels":{"controller-revision-hash":"db-rev2"}},"status":{"phase":"Running","containerStatuses":[{"ready":true}]}},
+	  {"metadata":{"name":"db-1","labels":{"controller-revision-hash":"db-rev1"}},"status":{"phase":"Running","containerStatuses":[{"ready":true}]}}]}`)
+	if got := findStuckPods(sts, stsPods, ""); len(got) != 1 || got[0].name != "db-1" {
+		t.Fatalf("statefulset findStuckPods = %+v", got)
+	}
+}
+
+func TestSelectorStringSorted(t *testing.T) {
+	if got := selectorString(map[string]string{"b": "2", "a": "1"}); got != "a=1,b=2" {
+		t.Errorf("selectorString = %q", got)
+	}
+}
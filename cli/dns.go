@@ -0,0 +1,372 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// An in-process DNS client, so `dns lookup`/`dns audit` and the probes work on
+// machines without bind-utils and get structured answers (TTL, AA, AD) instead
+// of parsed `dig +short` text. It asks one server directly — no search domains,
+// no /etc/hosts, no system cache — which is the point: the verbs compare what
+// specific resolvers say.
+
+// dnsTimeout mirrors the old `dig +time=3 +tries=1`: a resolver that does not
+// answer in 3s is reported as unreachable rather than retried.
+const dnsTimeout = 3 * time.Second
+
+// dnsUDPSize is the EDNS0 payload size advertised (the DNS flag day 2020 value).
+const dnsUDPSize = 1232
+
+// headerBitAD is the DNSSEC "authenticated data" flag (RFC 4035 §3.1.6). The
+// pinned x/net dnsmessage predates its Header.AuthenticData field, so it is read
+// and set on the wire bytes directly.
+const headerBitAD = 1 << 5
+
+// headerBitTC is the truncation flag; a truncated UDP answer is retried over TCP.
+const headerBitTC = 1 << 9
+
+var dnsTypes = map[string]dnsmessage.Type{
+	"A": dnsmessage.TypeA, "AAAA": dnsmessage.TypeAAAA, "TXT": dnsmessage.TypeTXT,
+	"MX": dnsmessage.TypeMX, "PTR": dnsmessage.TypePTR, "CNAME": dnsmessage.TypeCNAME,
+	"SRV": dnsmessage.TypeSRV, "NS": dnsmessage.TypeNS,
+}
+
+// dnsRecord is one answer-section record.
+type dnsRecord struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	TTL   uint32 `json:"ttl"`
+	Value string `json:"value"`
+}
+
+// dnsResponse is one server's answer to one question.
+type dnsResponse struct {
+	Server        string      `json:"server"`
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	RCode         string      `json:"rcode"`
+	Authoritative bool        `json:"authoritative"`
+	AuthenticData bool        `json:"authenticated_data"` // AD: the resolver DNSSEC-validated the answer
+	Answers       []dnsRecord `json:"answers"`
+	Error         string      `json:"error,omitempty"` // set, with RCode "ERROR", when the query itself failed
+}
+
+// values lists the answer values the way `dig +short` did: the CNAME chain
+// targets, then the records themselves.
+func (r dnsResponse) values() []string {
+	out := make([]string, len(r.Answers))
+	for i, a := range r.Answers {
+		out[i] = a.Value
+	}
+	return out
+}
+
+// sameAnswers reports whether two responses carry the same values, compared
+// as sets: round-robin records come back in a different order each query.
+func sameAnswers(a, b dnsResponse) bool {
+	av, bv := a.values(), b.values()
+	sort.Strings(av)
+	sort.Strings(bv)
+	return strings.Join(av, ",") == strings.Join(bv, ",")
+}
+
+// ips keeps only the A/AAAA addresses.
+func (r dnsResponse) ips() []string {
+	var out []string
+	for _, a := range r.Answers {
+		if a.Type == "A" || a.Type == "AAAA" {
+			out = append(out, a.Value)
+		}
+	}
+	return out
+}
+
+// dnsQuery asks server ("ip" or "ip:port") for name's rrtype records ("" = A).
+// A PTR query for an IP address is turned into its reverse name. A truncated
+// UDP answer is retried over TCP. NXDOMAIN is a response, not an error.
+func dnsQuery(name, server, rrtype string) (dnsResponse, error) {
+	rrtype = strings.ToUpper(rrtype)
+	if rrtype == "" {
+		rrtype = "A"
+	}
+	t, ok := dnsTypes[rrtype]
+	if !ok {
+		return dnsResponse{}, fmt.Errorf("unsupported record type %q (want A, AAAA, TXT, MX, PTR, CNAME, SRV or NS)", rrtype)
+	}
+	if t == dnsmessage.TypePTR {
+		if rev, ok := reverseName(name); ok {
+			name = rev
+		}
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	var idb [2]byte
+	if _, err := rand.Read(idb[:]); err != nil {
+		return dnsResponse{}, err
+	}
+	id := binary.BigEndian.Uint16(idb[:])
+	q, err := buildDNSQuery(id, name, t)
+	if err != nil {
+		return dnsResponse{}, err
+	}
+	msg, err := dnsExchange("udp", server, q)
+	if err == nil && len(msg) > 2 && msg[2]&byte(headerBitTC>>8) != 0 {
+		msg, err = dnsExchange("tcp", server, q)
+	}
+	if err != nil {
+		return dnsResponse{}, err
+	}
+	r, err := parseDNSResponse(msg, id, t)
+	if err != nil {
+		return dnsResponse{}, fmt.Errorf("%s: %w", server, err)
+	}
+	r.Server = strings.TrimSuffix(server, ":53")
+	return r, nil
+}
+
+// buildDNSQuery packs a recursive query for name/t with an EDNS0 OPT record
+// and the AD bit set, which asks a validating resolver to report whether it
+// validated the answer (RFC 6840 §5.7) without pulling in the RRSIGs.
+func buildDNSQuery(id uint16, name string, t dnsmessage.Type) ([]byte, error) {
+	n, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("bad name %q: %w", name, err)
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: n, Type: t, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(dnsUDPSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	msg[3] |= headerBitAD
+	return msg, nil
+}
+
+// dnsExchange sends q over network ("udp"/"tcp") and returns the raw reply.
+func dnsExchange(network, server string, q []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(network, server, dnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsTimeout))
+	if network == "tcp" {
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(len(q)))
+		if _, err := conn.Write(append(l[:], q...)); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return nil, err
+		}
+		msg := make([]byte, binary.BigEndian.Uint16(l[:]))
+		_, err := io.ReadFull(conn, msg)
+		return msg, err
+	}
+	if _, err := conn.Write(q); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// parseDNSResponse decodes a reply, checking it answers query id for type t.
+func parseDNSResponse(msg []byte, id uint16, t dnsmessage.Type) (dnsResponse, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return dnsResponse{}, fmt.Errorf("malformed DNS reply: %w", err)
+	}
+	if h.ID != id || !h.Response {
+		return dnsResponse{}, fmt.Errorf("DNS reply does not match the query")
+	}
+	q, err := p.Question()
+	if err != nil || q.Type != t {
+		return dnsResponse{}, fmt.Errorf("DNS reply does not match the query")
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return dnsResponse{}, err
+	}
+	r := dnsResponse{
+		Name:          strings.TrimSuffix(q.Name.String(), "."),
+		Type:          rrTypeName(t),
+		RCode:         rcodeName(h.RCode),
+		Authoritative: h.Authoritative,
+		AuthenticData: msg[3]&headerBitAD != 0,
+	}
+	for {
+		rh, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return dnsResponse{}, fmt.Errorf("malformed DNS answer: %w", err)
+		}
+		v, err := rrValue(&p, rh.Type)
+		if err != nil {
+			return dnsResponse{}, fmt.Errorf("malformed %s record: %w", rrTypeName(rh.Type), err)
+		}
+		r.Answers = append(r.Answers, dnsRecord{
+			Name: strings.TrimSuffix(rh.Name.String(), "."), Type: rrTypeName(rh.Type), TTL: rh.TTL, Value: v,
+		})
+	}
+	return r, nil
+}
+
+// rrValue reads the current answer's body as its presentation value.
+func rrValue(p *dnsmessage.Parser, t dnsmessage.Type) (string, error) {
+	trim := func(n dnsmessage.Name) string { return strings.TrimSuffix(n.String(), ".") }
+	switch t {
+	case dnsmessage.TypeA:
+		r, err := p.AResource()
+		return net.IP(r.A[:]).String(), err
+	case dnsmessage.TypeAAAA:
+		r, err := p.AAAAResource()
+		return net.IP(r.AAAA[:]).String(), err
+	case dnsmessage.TypeCNAME:
+		r, err := p.CNAMEResource()
+		return trim(r.CNAME), err
+	case dnsmessage.TypeNS:
+		r, err := p.NSResource()
+		return trim(r.NS), err
+	case dnsmessage.TypePTR:
+		r, err := p.PTRResource()
+		return trim(r.PTR), err
+	case dnsmessage.TypeMX:
+		r, err := p.MXResource()
+		return fmt.Sprintf("%d %s", r.Pref, trim(r.MX)), err
+	case dnsmessage.TypeSRV:
+		r, err := p.SRVResource()
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, trim(r.Target)), err
+	case dnsmessage.TypeTXT:
+		r, err := p.TXTResource()
+		return strings.Join(r.TXT, ""), err
+	}
+	return "(unsupported type)", p.SkipAnswer()
+}
+
+func rrTypeName(t dnsmessage.Type) string {
+	for name, v := range dnsTypes {
+		if v == t {
+			return name
+		}
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+func rcodeName(c dnsmessage.RCode) string {
+	switch c {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	}
+	return "RCODE" + strconv.Itoa(int(c))
+}
+
+// reverseName maps an IP to its in-addr.arpa / ip6.arpa name (like `dig -x`).
+func reverseName(s string) (string, bool) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return "", false
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0]), true
+	}
+	const hex = "0123456789abcdef"
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		b.WriteByte(hex[ip[i]&0xf])
+		b.WriteByte('.')
+		b.WriteByte(hex[ip[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa")
+	return b.String(), true
+}
+
+// firstIPv4 resolves name on server and returns its first A record, or "".
+func firstIPv4(name, server string) string {
+	r, err := dnsQuery(name, server, "A")
+	if err != nil {
+		return ""
+	}
+	for _, a := range r.Answers {
+		if a.Type == "A" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// systemResolver is the first nameserver in /etc/resolv.conf, falling back to
+// Cloudflare — for callers that want "whatever this machine uses" but still
+// need a server to ask.
+func systemResolver() string {
+	if b, err := os.ReadFile("/etc/resolv.conf"); err == nil {
+		for _, l := range strings.Split(string(b), "\n") {
+			f := strings.Fields(l)
+			if len(f) >= 2 && f[0] == "nameserver" && net.ParseIP(f[1]) != nil {
+				return f[1]
+			}
+		}
+	}
+	return "1.1.1.1"
+}
+
+// lookupIPs resolves name's A records on the system resolver through
+// dnsQuery — the dynamic-DNS updater's lookups, which used to go through
+// net.LookupIP and so could be answered from /etc/hosts or a stale cache.
+// It is IPv4-only (no AAAA query): the updater compares IPv4 addresses only.
+func lookupIPs(name string) ([]net.IP, error) {
+	r, err := dnsQuery(name, systemResolver(), "A")
+	if err != nil {
+		return nil, err
+	}
+	if r.RCode != "NOERROR" {
+		return nil, fmt.Errorf("%s: %s from %s", name, r.RCode, r.Server)
+	}
+	var ips []net.IP
+	for _, s := range r.ips() {
+		ips = append(ips, net.ParseIP(s))
+	}
+	return ips, nil
+}
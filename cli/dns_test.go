@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsReply answers query q with the given answers, echoing its ID/question.
+func dnsReply(t *testing.T, q []byte, aa, ad, tc bool, answers func(b *dnsmessage.Builder, n dnsmessage.Name)) []byte {
+	t.Helper()
+	var p dnsmessage.Parser
+	h, err := p.Start(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qq, err := p.Question()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, Authoritative: aa, Truncated: tc, RecursionAvailable: true})
+	b.StartQuestions()
+	b.Question(qq)
+	b.StartAnswers()
+	if !tc && answers != nil {
+		answers(&b, qq.Name)
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ad {
+		msg[3] |= headerBitAD
+	}
+	return msg
+}
+
+func cnameThenA(b *dnsmessage.Builder, n dnsmessage.Name) {
+	target := dnsmessage.MustNewName("75182cd7.cfargotunnel.com.")
+	b.CNAMEResource(dnsmessage.ResourceHeader{Name: n, Class: dnsmessage.ClassINET, TTL: 300}, dnsmessage.CNAMEResource{CNAME: target})
+	b.AResource(dnsmessage.ResourceHeader{Name: target, Class: dnsmessage.ClassINET, TTL: 60}, dnsmessage.AResource{A: [4]byte{104, 21, 3, 4}})
+}
+
+func TestDNSQueryUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no loopback UDP:", err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if buf[3]&headerBitAD == 0 {
+			return // query must ask for AD; no reply fails the test by timeout
+		}
+		pc.WriteTo(dnsReply(t, buf[:n], false, true, false, cnameThenA), addr)
+	}()
+	r, err := dnsQuery("tripit.viktorbarzin.me", pc.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Type != "A" || r.RCode != "NOERROR" || !r.AuthenticData || r.Authoritative {
+		t.Errorf("header = %+v", r)
+	}
+	if got := strings.Join(r.values(), ","); got != "75182cd7.cfargotunnel.com,104.21.3.4" {
+		t.Errorf("values = %q", got)
+	}
+	if got := strings.Join(r.ips(), ","); got != "104.21.3.4" {
+		t.Errorf("ips = %q", got)
+	}
+	if r.Answers[0].TTL != 300 || r.Answers[1].TTL != 60 {
+		t.Errorf("TTLs = %d/%d", r.Answers[0].TTL, r.Answers[1].TTL)
+	}
+	if got := fmtDNSAnswer(r); got != "75182cd7.cfargotunnel.com, 104.21.3.4  ttl=60s ad" {
+		t.Errorf("fmtDNSAnswer = %q", got)
+	}
+}
+
+// A truncated UDP answer is retried over TCP on the same port.
+func TestDNSQueryTCPFallback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no loopback TCP:", err)
+	}
+	defer ln.Close()
+	pc, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		t.Skip("UDP port taken:", err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		n, addr, err := pc.ReadFrom(buf)
+		if err == nil {
+			pc.WriteTo(dnsReply(t, buf[:n], true, false, true, nil), addr)
+		}
+	}()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		var l [2]byte
+		if _, err := io.ReadFull(c, l[:]); err != nil {
+			return
+		}
+		q := make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(c, q); err != nil {
+			return
+		}
+		reply := dnsReply(t, q, true, false, false, func(b *dnsmessage.Builder, n dnsmessage.Name) {
+			b.TXTResource(dnsmessage.ResourceHeader{Name: n, Class: dnsmessage.ClassINET, TTL: 3600},
+				dnsmessage.TXTResource{TXT: []string{"v=spf1 include:_spf.", "example.com ~all"}})
+		})
+		binary.BigEndian.PutUint16(l[:], uint16(len(reply)))
+		c.Write(append(l[:], reply...))
+	}()
+	r, err := dnsQuery("viktorbarzin.me", ln.Addr().String(), "txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Authoritative || len(r.Answers) != 1 || r.Answers[0].Value != "v=spf1 include:_spf.example.com ~all" {
+		t.Errorf("TCP answer = %+v", r)
+	}
+}
+
+func TestParseDNSResponseRejectsMismatch(t *testing.T) {
+	q, err := buildDNSQuery(42, "tripit.viktorbarzin.me", dnsmessage.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := dnsReply(t, q, false, false, false, nil)
+	if _, err := parseDNSResponse(reply, 43, dnsmessage.TypeA); err == nil {
+		t.Error("reply with the wrong ID accepted")
+	}
+	if _, err := parseDNSResponse(reply, 42, dnsmessage.TypeAAAA); err == nil {
+		t.Error("reply for the wrong type accepted")
+	}
+	if r, err := parseDNSResponse(reply, 42, dnsmessage.TypeA); err != nil || r.RCode != "NOERROR" || len(r.Answers) != 0 {
+		t.Errorf("empty reply = %+v, %v", r, err)
+	}
+}
+
+func TestDNSQueryUnsupportedType(t *testing.T) {
+	if _, err := dnsQuery("x", "127.0.0.1", "HINFO"); err == nil || !strings.Contains(err.Error(), "unsupported record type") {
+		t.Errorf("HINFO err = %v", err)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	cases := map[string]string{
+		"10.0.20.203": "203.20.0.10.in-addr.arpa",
+		"2001:db8::1": "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+	}
+	for in, want := range cases {
+		if got, ok := reverseName(in); !ok || got != want {
+			t.Errorf("reverseName(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	if _, ok := reverseName("tripit.viktorbarzin.me"); ok {
+		t.Error("reverseName accepted a hostname")
+	}
+}
+
+func TestSameAnswersIgnoresOrder(t *testing.T) {
+	rr := func(vals ...string) dnsResponse {
+		var r dnsResponse
+		for _, v := range vals {
+			r.Answers = append(r.Answers, dnsRecord{Type: "A", Value: v})
+		}
+		return r
+	}
+	if !sameAnswers(rr("1.1.1.1", "1.0.0.1"), rr("1.0.0.1", "1.1.1.1")) {
+		t.Error("round-robin order reported as a mismatch")
+	}
+	if sameAnswers(rr("1.1.1.1"), rr("10.0.20.203")) {
+		t.Error("split-horizon answers reported as equal")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// auditResolver is one vantage point `dns audit` asks.
+type auditResolver struct {
+	Name   string `json:"name"`
+	Addr   string `json:"addr"`
+	Public bool   `json:"public"`
+}
+
+// auditResolvers: three public resolvers (what the internet sees), then the
+// LAN side — Technitium is authoritative for the split-horizon views, and the
+// pfSense and OpenWRT resolvers are what clients actually query, so a name
+// they answer differently from Technitium is a forwarding or cache problem.
+var auditResolvers = []auditResolver{
+	{Name: "cloudflare", Addr: "1.1.1.1", Public: true},
+	{Name: "google", Addr: "8.8.8.8", Public: true},
+	{Name: "quad9", Addr: "9.9.9.9", Public: true},
+	{Name: "technitium", Addr: "10.0.20.201"},
+	{Name: "pfsense", Addr: "10.0.20.1"},
+	{Name: "openwrt", Addr: strings.TrimSuffix(openWRTHost, ":22")},
+}
+
+// resolverAnswer is one resolver's A answer for a name. Err is set when the
+// resolver could not be asked at all (unreachable from here), which is not
+// the same as an empty answer.
+type resolverAnswer struct {
+	Resolver auditResolver `json:"resolver"`
+	IPs      []string      `json:"ips"`
+	Err      string        `json:"error,omitempty"`
+}
+
+// nameAudit is the verdict for one name.
+type nameAudit struct {
+	Name     string           `json:"name"`
+	Answers  []resolverAnswer `json:"answers"`
+	Findings []string         `json:"findings,omitempty"`
+}
+
+// answerIPs keeps the addresses from a response, dropping the CNAME chain,
+// sorted so answers compare as sets.
+func answerIPs(r dnsResponse) []string {
+	ips := r.ips()
+	sort.Strings(ips)
+	return ips
+}
+
+func isPrivateIP(s string) bool {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		if _, n, _ := net.ParseCIDR(cidr); n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditName compares the answers and explains what is wrong, if anything.
+// Split horizon itself is expected — an internal-only .me name (ADR-0021
+// "internal" type) answers the LB 10.0.20.203 everywhere, and a proxied name
+// answers Cloudflare publicly and may answer the LB on the LAN. The findings
+// are the cases that are never intended:
+//   - a .lan name resolving publicly (it must not exist outside Technitium);
+//   - a name public DNS knows but a LAN resolver does not — the LAN zone has
+//     no wildcard, so every recordless proxied app needs the catch-all there;
+//   - a LAN answer that is a public IP other than the public answer;
+//   - resolvers on the same side disagreeing (propagation gap, stale cache,
+//     pfSense/OpenWRT not forwarding to Technitium).
+func auditName(name string, answers []resolverAnswer) nameAudit {
+	a := nameAudit{Name: name, Answers: answers}
+	var pub, lan []resolverAnswer
+	for _, ans := range answers {
+		if ans.Err != "" {
+			continue
+		}
+		if ans.Resolver.Public {
+			pub = append(pub, ans)
+		} else {
+			lan = append(lan, ans)
+		}
+	}
+	pubSet, pubOK := agreedSet(pub)
+	lanSet, lanOK := agreedSet(lan)
+	lanName := strings.HasSuffix(strings.TrimSuffix(name, "."), ".lan")
+
+	if len(pub)+len(lan) > 0 && allEmpty(pub) && allEmpty(lan) {
+		a.Findings = append(a.Findings, "no A record on any resolver")
+		return a
+	}
+	if !pubOK {
+		a.Findings = append(a.Findings, "public resolvers disagree: "+describeAnswers(pub))
+	}
+	if !lanOK {
+		a.Findings = append(a.Findings, "LAN resolvers disagree: "+describeAnswers(lan))
+	}
+	if lanName && !allEmpty(pub) {
+		a.Findings = append(a.Findings, ".lan name resolves publicly: "+describeAnswers(pub))
+	}
+	if !lanName {
+		for _, ans := range lan {
+			if len(ans.IPs) == 0 && !allEmpty(pub) {
+				a.Findings = append(a.Findings, fmt.Sprintf("missing on %s (%s) but public DNS answers — LAN zone lacks the record", ans.Resolver.Name, ans.Resolver.Addr))
+			}
+		}
+	}
+	if pubOK && lanOK && len(pubSet) > 0 && len(lanSet) > 0 && strings.Join(pubSet, ",") != strings.Join(lanSet, ",") {
+		for _, ip := range lanSet {
+			if !isPrivateIP(ip) {
+				a.Findings = append(a.Findings, fmt.Sprintf("split-horizon surprise: LAN answers public IP(s) %s, public DNS answers %s",
+					strings.Join(lanSet, ","), strings.Join(pubSet, ",")))
+				break
+			}
+		}
+	}
+	return a
+}
+
+// agreedSet returns the common answer of answers, and whether they all agree.
+// An empty answer disagrees with a non-empty one.
+func agreedSet(answers []resolverAnswer) ([]string, bool) {
+	if len(answers) == 0 {
+		return nil, true
+	}
+	first := strings.Join(answers[0].IPs, ",")
+	for _, ans := range answers[1:] {
+		if strings.Join(ans.IPs, ",") != first {
+			return nil, false
+		}
+	}
+	return answers[0].IPs, true
+}
+
+func allEmpty(answers []resolverAnswer) bool {
+	for _, ans := range answers {
+		if len(ans.IPs) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func describeAnswers(answers []resolverAnswer) string {
+	parts := make([]string, len(answers))
+	for i, ans := range answers {
+		parts[i] = ans.Resolver.Name + "=" + oneLineList(strings.Join(ans.IPs, "\n"))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatDNSAudit renders the audit: one line per name, findings indented
+// under it, then unreachable resolvers and a footer.
+func formatDNSAudit(audits []nameAudit) string {
+	var b strings.Builder
+	flagged := 0
+	unreachable := map[string]string{}
+	for _, a := range audits {
+		var pubSet, lanSet []string
+		for _, ans := range a.Answers {
+			if ans.Err != "" {
+				unreachable[ans.Resolver.Name+" ("+ans.Resolver.Addr+")"] = ans.Err
+				continue
+			}
+			if ans.Resolver.Public && pubSet == nil {
+				pubSet = ans.IPs
+			} else if !ans.Resolver.Public && lanSet == nil {
+				lanSet = ans.IPs
+			}
+		}
+		mark := "✓"
+		if len(a.Findings) > 0 {
+			mark = "✗"
+			flagged++
+		}
+		fmt.Fprintf(&b, "%s %-36s public=%s lan=%s\n", mark, a.Name,
+			oneLineList(strings.Join(pubSet, "\n")), oneLineList(strings.Join(lanSet, "\n")))
+		for _, f := range a.Findings {
+			fmt.Fprintf(&b, "    %s\n", f)
+		}
+	}
+	if len(unreachable) > 0 {
+		var rs []string
+		for r := range unreachable {
+			rs = append(rs, r)
+		}
+		sort.Strings(rs)
+		fmt.Fprintf(&b, "not asked (unreachable from here): %s\n", strings.Join(rs, ", "))
+	}
+	fmt.Fprintf(&b, "%d names, %d flagged\n", len(audits), flagged)
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// answersFor builds one answer per auditResolvers entry: pub for all three
+// public resolvers, then the three LAN resolvers individually.
+func answersFor(pub []string, technitium, pfsense, openwrt []string) []resolverAnswer {
+	byName := map[string][]string{
+		"cloudflare": pub, "google": pub, "quad9": pub,
+		"technitium": technitium, "pfsense": pfsense, "openwrt": openwrt,
+	}
+	var out []resolverAnswer
+	for _, r := range auditResolvers {
+		out = append(out, resolverAnswer{Resolver: r, IPs: byName[r.Name]})
+	}
+	return out
+}
+
+func TestAnswerIPs(t *testing.T) {
+	r := dnsResponse{Answers: []dnsRecord{
+		{Type: "CNAME", Value: "75182cd7.cfargotunnel.com"},
+		{Type: "A", Value: "172.67.1.2"},
+		{Type: "A", Value: "104.21.3.4"},
+	}}
+	if got := strings.Join(answerIPs(r), ","); got != "104.21.3.4,172.67.1.2" {
+		t.Errorf("answerIPs = %q", got)
+	}
+	if got := answerIPs(dnsResponse{RCode: "NXDOMAIN"}); len(got) != 0 {
+		t.Errorf("empty = %v", got)
+	}
+}
+
+func TestAuditName(t *testing.T) {
+	cf := []string{"104.21.3.4", "172.67.1.2"}
+	lb := []string{"10.0.20.203"}
+	cases := []struct {
+		desc    string
+		name    string
+		answers []resolverAnswer
+		want    []string // substrings, one per expected finding; nil = clean
+	}{
+		{"proxied app, LAN short-circuits to the LB", "tripit.viktorbarzin.me",
+			answersFor(cf, lb, lb, lb), nil},
+		{"internal-type .me answers the LB everywhere (ADR-0021)", "family.viktorbarzin.me",
+			answersFor(lb, lb, lb, lb), nil},
+		{".lan only on the LAN", "ha.viktorbarzin.lan",
+			answersFor(nil, lb, lb, lb), nil},
+		{"LAN zone lacks a proxied name", "new-app.viktorbarzin.me",
+			answersFor(cf, nil, nil, nil), []string{"missing on technitium", "missing on pfsense", "missing on openwrt"}},
+		{"openwrt not forwarding to technitium", "tripit.viktorbarzin.me",
+			answersFor(cf, lb, lb, cf), []string{"LAN resolvers disagree"}},
+		{".lan leaked publicly", "ha.viktorbarzin.lan",
+			answersFor(lb, lb, lb, lb), []string{".lan name resolves publicly"}},
+		{"LAN pins a stale public IP", "immich.viktorbarzin.me",
+			answersFor([]string{"176.12.22.76"}, []string{"1.2.3.4"}, []string{"1.2.3.4"}, []string{"1.2.3.4"}), []string{"split-horizon surprise"}},
+		{"gone everywhere", "typo.viktorbarzin.lan",
+			answersFor(nil, nil, nil, nil), []string{"no A record on any resolver"}},
+	}
+	for _, c := range cases {
+		got := auditName(c.name, c.answers).Findings
+		if len(got) != len(c.want) {
+			t.Errorf("%s: findings = %q, want %d matching %q", c.desc, got, len(c.want), c.want)
+			continue
+		}
+		for i, w := range c.want {
+			if !strings.Contains(got[i], w) {
+				t.Errorf("%s: finding %d = %q, want it to mention %q", c.desc, i, got[i], w)
+			}
+		}
+	}
+}
+
+func TestAuditNameSkipsUnreachable(t *testing.T) {
+	answers := answersFor([]string{"104.21.3.4"}, []string{"10.0.20.203"}, nil, nil)
+	answers[4].Err, answers[5].Err = "exit status 9", "exit status 9" // off-LAN: pfSense/OpenWRT unreachable
+	a := auditName("tripit.viktorbarzin.me", answers)
+	if len(a.Findings) != 0 {
+		t.Errorf("unreachable resolvers produced findings: %q", a.Findings)
+	}
+	out := formatDNSAudit([]nameAudit{a})
+	for _, want := range []string{"✓ tripit.viktorbarzin.me", "public=104.21.3.4 lan=10.0.20.203", "not asked (unreachable from here): openwrt (192.168.1.1), pfsense (10.0.20.1)", "1 names, 0 flagged"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("audit output missing %q:\n%s", want, out)
+		}
+	}
+}
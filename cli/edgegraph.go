@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// graphEdge is one edge row with its flow count, for `edges graph`.
+type graphEdge struct {
+	Src    string `json:"src"`
+	Dst    string `json:"dst"`
+	Action string `json:"action"`
+	Flows  int64  `json:"flow_count"`
+}
+
+// edgeGraphQuery selects every edge (or those seen within window) as a JSON
+// array.
+func edgeGraphQuery(window string) (string, error) {
+	where := ""
+	if window != "" {
+		c, ok := sinceCond("last_seen", window)
+		if !ok {
+			return "", fmt.Errorf("--window must be a duration (e.g. 7d, 24h) or a date (YYYY-MM-DD): %q", window)
+		}
+		where = " WHERE " + c
+	}
+	return "SELECT coalesce(json_agg(row_to_json(t)), '[]') FROM (" +
+		"SELECT src_ns AS src, dst_ns AS dst, action, flow_count FROM edge" + where + ") t", nil
+}
+
+func parseGraphEdges(out string) ([]graphEdge, error) {
+	var edges []graphEdge
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &edges); err != nil {
+		return nil, fmt.Errorf("cannot parse edges: %w", err)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.Src != b.Src {
+			return a.Src < b.Src
+		}
+		if a.Dst != b.Dst {
+			return a.Dst < b.Dst
+		}
+		return a.Action < b.Action
+	})
+	return edges, nil
+}
+
+// neighbourhood keeps the part of the graph within depth hops of ns, walking
+// edges in either direction (a dependency and a dependent are both relevant
+// to a review). An edge is kept when both ends are reached and at least one
+// is inside the radius, so the outer ring's edges among themselves are not.
+func neighbourhood(edges []graphEdge, ns string, depth int) []graphEdge {
+	adj := map[string][]string{}
+	for _, e := range edges {
+		adj[e.Src] = append(adj[e.Src], e.Dst)
+		adj[e.Dst] = append(adj[e.Dst], e.Src)
+	}
+	dist := map[string]int{ns: 0}
+	queue := []string{ns}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if dist[n] == depth {
+			continue
+		}
+		for _, m := range adj[n] {
+			if _, seen := dist[m]; !seen {
+				dist[m] = dist[n] + 1
+				queue = append(queue, m)
+			}
+		}
+	}
+	var out []graphEdge
+	for _, e := range edges {
+		ds, okS := dist[e.Src]
+		dd, okD := dist[e.Dst]
+		if okS && okD && (ds < depth || dd < depth) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// graphNodes is every namespace in edges, sorted, with its weight: the flows
+// through it in either direction.
+func graphNodes(edges []graphEdge) ([]string, map[string]int64) {
+	w := map[string]int64{}
+	for _, e := range edges {
+		w[e.Src] += e.Flows
+		w[e.Dst] += e.Flows
+	}
+	nodes := make([]string, 0, len(w))
+	for n := range w {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+	return nodes, w
+}
+
+// flowScale maps a flow count onto 0..~6 (log10), so a namespace with a
+// million flows is drawn bigger than one with ten, but not 100000x bigger.
+func flowScale(flows int64) float64 {
+	if flows < 1 {
+		return 0
+	}
+	return math.Log10(float64(flows))
+}
+
+// renderDOT renders Graphviz DOT: node size and edge width by flow count,
+// denied edges dashed red, the focus namespace (if any) doubled.
+func renderDOT(edges []graphEdge, focus string) string {
+	nodes, w := graphNodes(edges)
+	var b strings.Builder
+	b.WriteString("digraph edges {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString(`  node [shape=box, style="rounded,filled", fillcolor="#eef3fb", fontname="Helvetica"];` + "\n")
+	b.WriteString(`  edge [fontname="Helvetica", fontsize=9, color="#5b6b82"];` + "\n")
+	for _, n := range nodes {
+		s := flowScale(w[n])
+		attrs := fmt.Sprintf("fontsize=%.0f, penwidth=%.1f, tooltip=%q", 10+2*s, 1+s/2, fmt.Sprintf("%s: %d flows", n, w[n]))
+		if n == focus {
+			attrs += `, peripheries=2, fillcolor="#ffe9a8"`
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", n, attrs)
+	}
+	for _, e := range edges {
+		label, style := fmt.Sprint(e.Flows), ""
+		if e.Action == "deny" {
+			label, style = "denied "+label, `, style=dashed, color="#d0342c", fontcolor="#d0342c"`
+		}
+		attrs := fmt.Sprintf("penwidth=%.1f, label=%q%s", 1+flowScale(e.Flows)/2, label, style)
+		fmt.Fprintf(&b, "  %q -> %q [%s];\n", e.Src, e.Dst, attrs)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// renderMermaid renders a Mermaid flowchart. Mermaid cannot size nodes, so
+// weight is shown as three classes (light/medium/heavy by flow decade) plus
+// the count in the label; denied edges are dotted and red.
+func renderMermaid(edges []graphEdge, focus string) string {
+	nodes, w := graphNodes(edges)
+	id := map[string]string{}
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, n := range nodes {
+		id[n] = fmt.Sprintf("n%d", i)
+		class := "light"
+		switch s := flowScale(w[n]); {
+		case s >= 5:
+			class = "heavy"
+		case s >= 3:
+			class = "medium"
+		}
+		if n == focus {
+			class = "focus"
+		}
+		fmt.Fprintf(&b, "  %s[\"%s<br/><small>%d flows</small>\"]:::%s\n", id[n], n, w[n], class)
+	}
+	var denied []int
+	for i, e := range edges {
+		if e.Action == "deny" {
+			fmt.Fprintf(&b, "  %s -. \"denied %d\" .-> %s\n", id[e.Src], e.Flows, id[e.Dst])
+			denied = append(denied, i)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -- \"%d\" --> %s\n", id[e.Src], e.Flows, id[e.Dst])
+	}
+	b.WriteString("  classDef light fill:#f4f6fa,stroke:#9aa5b5\n")
+	b.WriteString("  classDef medium fill:#dce6f5,stroke:#5b6b82,stroke-width:2px\n")
+	b.WriteString("  classDef heavy fill:#b9cdea,stroke:#2f3f57,stroke-width:3px\n")
+	b.WriteString("  classDef focus fill:#ffe9a8,stroke:#8a6d00,stroke-width:3px\n")
+	if len(denied) > 0 {
+		idx := make([]string, len(denied))
+		for i, d := range denied {
+			idx[i] = fmt.Sprint(d)
+		}
+		fmt.Fprintf(&b, "  linkStyle %s stroke:#d0342c,color:#d0342c\n", strings.Join(idx, ","))
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var graphFixture = []graphEdge{
+	{"immich", "dbaas", "allow", 120000},
+	{"immich", "redis", "allow", 800},
+	{"tripit", "dbaas", "allow", 50},
+	{"tripit", "immich", "deny", 3},
+	{"monitoring", "tripit", "allow", 10},
+	{"far", "monitoring", "allow", 1},
+}
+
+func TestNeighbourhood(t *testing.T) {
+	got := neighbourhood(graphFixture, "immich", 1)
+	var pairs []string
+	for _, e := range got {
+		pairs = append(pairs, e.Src+">"+e.Dst)
+	}
+	// depth 1: immich's direct edges only; tripit→dbaas joins two one-hop
+	// namespaces, neither inside the radius.
+	if strings.Join(pairs, " ") != "immich>dbaas immich>redis tripit>immich" {
+		t.Errorf("depth 1 = %v", pairs)
+	}
+	got = neighbourhood(graphFixture, "immich", 2)
+	if len(got) != 5 {
+		t.Errorf("depth 2 kept %d edges, want 5 (all but far→monitoring): %v", len(got), got)
+	}
+	if len(neighbourhood(graphFixture, "nope", 2)) != 0 {
+		t.Error("unknown namespace should yield no edges")
+	}
+}
+
+func TestEdgeGraphQuery(t *testing.T) {
+	q, err := edgeGraphQuery("")
+	if err != nil || strings.Contains(q, "WHERE") || !strings.Contains(q, "flow_count") {
+		t.Errorf("no window: %q, %v", q, err)
+	}
+	if q, _ := edgeGraphQuery("7d"); !strings.Contains(q, "WHERE last_seen >= now() - interval '7 days'") {
+		t.Errorf("window: %q", q)
+	}
+	if _, err := edgeGraphQuery("x'"); err == nil {
+		t.Error("bad window accepted")
+	}
+}
+
+func TestRenderDOT(t *testing.T) {
+	out := renderDOT(graphFixture[:4], "immich")
+	for _, want := range []string{
+		"digraph edges {",
+		`"immich" [fontsize=20, penwidth=3.5`, // 120803 flows: log10 ≈ 5.08
+		`peripheries=2`,
+		`"immich" -> "dbaas" [penwidth=3.5, label="120000"];`,
+		`"tripit" -> "immich" [penwidth=1.2, label="denied 3", style=dashed, color="#d0342c"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "peripheries") != 1 {
+		t.Error("only the focus namespace should be doubled")
+	}
+}
+
+func TestRenderMermaid(t *testing.T) {
+	out := renderMermaid(graphFixture[:4], "")
+	for _, want := range []string{
+		"flowchart LR\n",
+		`n0["dbaas<br/><small>120050 flows</small>"]:::heavy`,
+		`n3["tripit<br/><small>53 flows</small>"]:::light`,
+		`n2["redis<br/><small>800 flows</small>"]:::light`,
+		`n1 -- "120000" --> n0`,
+		`n3 -. "denied 3" .-> n1`,
+		"linkStyle 3 stroke:#d0342c",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("mermaid missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // edgesOpts is the parsed filter set for `homelab edges` (the who-talks-to-whom
@@ -97,14 +100,24 @@ var (
 // newSinceCond turns a duration (24h/7d/30m/90s) or a date (YYYY-MM-DD[ HH:MM])
 // into a first_seen predicate.
 func newSinceCond(v string) (string, error) {
+	c, ok := sinceCond("first_seen", v)
+	if !ok {
+		return "", fmt.Errorf("--new-since must be a duration (e.g. 24h, 7d, 30m) or a date (YYYY-MM-DD): %q", v)
+	}
+	return c, nil
+}
+
+// sinceCond renders `col >= <duration ago | date>`; ok is false when v is
+// neither.
+func sinceCond(col, v string) (string, bool) {
 	if m := durRE.FindStringSubmatch(v); m != nil {
 		unit := map[string]string{"s": "seconds", "m": "minutes", "h": "hours", "d": "days"}[m[2]]
-		return fmt.Sprintf("first_seen >= now() - interval '%s %s'", m[1], unit), nil
+		return fmt.Sprintf("%s >= now() - interval '%s %s'", col, m[1], unit), true
 	}
 	if dateRE.MatchString(v) {
-		return "first_seen >= " + sqlStr(v), nil
+		return col + " >= " + sqlStr(v), true
 	}
-	return "", fmt.Errorf("--new-since must be a duration (e.g. 24h, 7d, 30m) or a date (YYYY-MM-DD): %q", v)
+	return "", false
 }
 
 // buildEdgesQuery renders the SQL for the given filters against the `edge` table.
@@ -162,3 +175,150 @@ func buildEdgesQuery(o edgesOpts) (string, error) {
 	}
 	return q, nil
 }
+
+// edgesBaselineFile is where `edges baseline save` writes the approved edge
+// set, relative to the infra root — next to the aggregator stack, so a
+// NetworkPolicy change and the baseline update land in the same review.
+const edgesBaselineFile = "stacks/goldmane-edge-aggregator/edges-baseline.json"
+
+// edgesDefaultWindow is how recently an edge must have been seen to count as
+// current for `edges baseline save` / `edges diff`: long enough to include the
+// weekly CronJobs, short enough that a removed app's edges drop out.
+const edgesDefaultWindow = "7d"
+
+// baselineEdge is one (src_ns, dst_ns, action) edge — the key the aggregator
+// upserts on.
+type baselineEdge struct {
+	Src    string `json:"src"`
+	Dst    string `json:"dst"`
+	Action string `json:"action"`
+}
+
+func (e baselineEdge) String() string { return e.Src + " → " + e.Dst + " (" + e.Action + ")" }
+
+// edgesBaseline is the checked-in file. Edges are sorted so a re-save diffs
+// cleanly in review.
+type edgesBaseline struct {
+	SavedAt time.Time      `json:"saved_at"`
+	Window  string         `json:"window"`
+	Edges   []baselineEdge `json:"edges"`
+}
+
+// edgeSetQuery selects the distinct edges seen within window as a JSON array.
+func edgeSetQuery(window string) (string, error) {
+	c, ok := sinceCond("last_seen", window)
+	if !ok {
+		return "", fmt.Errorf("--window must be a duration (e.g. 7d, 24h) or a date (YYYY-MM-DD): %q", window)
+	}
+	return "SELECT coalesce(json_agg(row_to_json(t) ORDER BY t.src, t.dst, t.action), '[]') FROM (" +
+		"SELECT DISTINCT src_ns AS src, dst_ns AS dst, action FROM edge WHERE " + c + ") t", nil
+}
+
+// parseEdgeSet decodes edgeSetQuery's output, sorted.
+func parseEdgeSet(out string) ([]baselineEdge, error) {
+	var edges []baselineEdge
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &edges); err != nil {
+		return nil, fmt.Errorf("cannot parse edge set: %w", err)
+	}
+	sortEdges(edges)
+	return edges, nil
+}
+
+func sortEdges(edges []baselineEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.Src != b.Src {
+			return a.Src < b.Src
+		}
+		if a.Dst != b.Dst {
+			return a.Dst < b.Dst
+		}
+		return a.Action < b.Action
+	})
+}
+
+// edgesDiff is the current edge set against the baseline.
+type edgesDiff struct {
+	NewPeers    []baselineEdge `json:"new_peers"`    // allowed edges the baseline lacks: unexpected new egress
+	NewlyDenied []baselineEdge `json:"newly_denied"` // denied edges the baseline lacks: a policy now blocks something
+	NewOther    []baselineEdge `json:"new_other"`    // new edges whose action is neither allow nor deny (audit, log, …)
+	Vanished    []baselineEdge `json:"vanished"`     // baseline edges not seen in the window
+}
+
+func (d edgesDiff) empty() bool {
+	return len(d.NewPeers)+len(d.NewlyDenied)+len(d.NewOther)+len(d.Vanished) == 0
+}
+
+// diffEdges compares edge sets; ns, when set, keeps only edges touching it.
+func diffEdges(base, cur []baselineEdge, ns string) edgesDiff {
+	touches := func(e baselineEdge) bool { return ns == "" || e.Src == ns || e.Dst == ns }
+	inBase, inCur := map[baselineEdge]bool{}, map[baselineEdge]bool{}
+	for _, e := range base {
+		inBase[e] = true
+	}
+	for _, e := range cur {
+		inCur[e] = true
+	}
+	d := edgesDiff{NewPeers: []baselineEdge{}, NewlyDenied: []baselineEdge{}, NewOther: []baselineEdge{}, Vanished: []baselineEdge{}}
+	for _, e := range cur {
+		if inBase[e] || !touches(e) {
+			continue
+		}
+		switch e.Action {
+		case "allow":
+			d.NewPeers = append(d.NewPeers, e)
+		case "deny":
+			d.NewlyDenied = append(d.NewlyDenied, e)
+		default:
+			d.NewOther = append(d.NewOther, e)
+		}
+	}
+	for _, e := range base {
+		if !inCur[e] && touches(e) {
+			d.Vanished = append(d.Vanished, e)
+		}
+	}
+	for _, l := range [][]baselineEdge{d.NewPeers, d.NewlyDenied, d.NewOther, d.Vanished} {
+		sortEdges(l)
+	}
+	return d
+}
+
+// formatEdgesDiff groups the diff by source namespace (the namespace whose
+// egress changed, which is whose NetworkPolicy to look at): `+` new peer,
+// `!` newly denied, `?` new with another action, `-` vanished.
+func formatEdgesDiff(d edgesDiff) string {
+	if d.empty() {
+		return "no changes against the baseline\n"
+	}
+	byNS := map[string][]string{}
+	add := func(mark, what string, edges []baselineEdge) {
+		for _, e := range edges {
+			byNS[e.Src] = append(byNS[e.Src], fmt.Sprintf("%s → %-24s %s", mark, e.Dst, what))
+		}
+	}
+	add("+", "new peer (allow)", d.NewPeers)
+	add("!", "newly denied", d.NewlyDenied)
+	for _, e := range d.NewOther {
+		byNS[e.Src] = append(byNS[e.Src], fmt.Sprintf("? → %-24s new (%s)", e.Dst, e.Action))
+	}
+	add("-", "vanished", d.Vanished)
+	var nss []string
+	for ns := range byNS {
+		nss = append(nss, ns)
+	}
+	sort.Strings(nss)
+	var b strings.Builder
+	for _, ns := range nss {
+		fmt.Fprintf(&b, "%s:\n", ns)
+		for _, l := range byNS[ns] {
+			fmt.Fprintf(&b, "  %s\n", l)
+		}
+	}
+	fmt.Fprintf(&b, "%d new peer(s), %d newly denied, ", len(d.NewPeers), len(d.NewlyDenied))
+	if len(d.NewOther) > 0 {
+		fmt.Fprintf(&b, "%d other, ", len(d.NewOther))
+	}
+	fmt.Fprintf(&b, "%d vanished\n", len(d.Vanished))
+	return b.String()
+}
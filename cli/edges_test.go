@@ -161,3 +161,93 @@ func TestValidateNS(t *testing.T) {
 		}
 	}
 }
+
+func TestEdgeSetQuery(t *testing.T) {
+	q, err := edgeSetQuery("7d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"SELECT DISTINCT src_ns AS src, dst_ns AS dst, action FROM edge", "last_seen >= now() - interval '7 days'", "json_agg"} {
+		if !strings.Contains(q, want) {
+			t.Errorf("query %q missing %q", q, want)
+		}
+	}
+	if _, err := edgeSetQuery("7d; DROP TABLE edge"); err == nil {
+		t.Error("edgeSetQuery accepted an injection attempt")
+	}
+}
+
+func TestParseEdgeSetSorts(t *testing.T) {
+	got, err := parseEdgeSet(`[{"src":"b","dst":"a","action":"allow"},{"src":"a","dst":"z","action":"deny"},{"src":"a","dst":"z","action":"allow"}]` + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []baselineEdge{{"a", "z", "allow"}, {"a", "z", "deny"}, {"b", "a", "allow"}}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("parseEdgeSet order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDiffEdges(t *testing.T) {
+	base := []baselineEdge{
+		{"immich", "dbaas", "allow"},
+		{"immich", "redis", "allow"},
+		{"tripit", "dbaas", "allow"},
+	}
+	cur := []baselineEdge{
+		{"immich", "dbaas", "allow"},
+		{"immich", "authentik", "allow"}, // new peer
+		{"tripit", "dbaas", "allow"},
+		{"tripit", "redis", "deny"}, // newly denied
+		{"tripit", "smtp", "audit"}, // neither: not a new peer
+		// immich → redis vanished
+	}
+	d := diffEdges(base, cur, "")
+	if len(d.NewPeers) != 1 || d.NewPeers[0] != (baselineEdge{"immich", "authentik", "allow"}) {
+		t.Errorf("NewPeers = %v", d.NewPeers)
+	}
+	if len(d.NewlyDenied) != 1 || d.NewlyDenied[0].Src != "tripit" {
+		t.Errorf("NewlyDenied = %v", d.NewlyDenied)
+	}
+	if len(d.NewOther) != 1 || d.NewOther[0] != (baselineEdge{"tripit", "smtp", "audit"}) {
+		t.Errorf("NewOther = %v", d.NewOther)
+	}
+	if len(d.Vanished) != 1 || d.Vanished[0] != (baselineEdge{"immich", "redis", "allow"}) {
+		t.Errorf("Vanished = %v", d.Vanished)
+	}
+
+	only := diffEdges(base, cur, "tripit")
+	if len(only.NewPeers) != 0 || len(only.NewlyDenied) != 1 || len(only.NewOther) != 1 || len(only.Vanished) != 0 {
+		t.Errorf("--ns tripit diff = %+v", only)
+	}
+	if !diffEdges(base, base, "").empty() {
+		t.Error("identical sets should diff empty")
+	}
+}
+
+func TestFormatEdgesDiff(t *testing.T) {
+	d := edgesDiff{
+		NewPeers:    []baselineEdge{{"immich", "authentik", "allow"}},
+		NewlyDenied: []baselineEdge{{"tripit", "redis", "deny"}},
+		NewOther:    []baselineEdge{{"tripit", "smtp", "audit"}},
+		Vanished:    []baselineEdge{{"immich", "redis", "allow"}},
+	}
+	out := formatEdgesDiff(d)
+	for _, want := range []string{
+		"immich:\n  + → authentik",
+		"new peer (allow)",
+		"  - → redis",
+		"tripit:\n  ! → redis",
+		"? → smtp                     new (audit)",
+		"1 new peer(s), 1 newly denied, 1 other, 1 vanished",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if formatEdgesDiff(edgesDiff{}) != "no changes against the baseline\n" {
+		t.Error("empty diff text")
+	}
+}
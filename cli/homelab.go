@@ -19,8 +19,10 @@ func buildRegistry() []Command {
 	reg = append(reg, pagesCommands()...)
 	reg = append(reg, ciCommands()...)
 	reg = append(reg, deployCommands()...)
+	reg = append(reg, shipCommands()...)
 	reg = append(reg, netCommands()...)
 	reg = append(reg, obsCommands()...)
+	reg = append(reg, alertsCommands()...)
 	reg = append(reg, edgesCommands()...)
 	reg = append(reg, usageCommands()...)
 	reg = append(reg, claudeUsageCommands()...)
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The crowdsec verbs reach LAPI one of two ways. With machine credentials
+// configured (CROWDSEC_MACHINE_ID / CROWDSEC_MACHINE_PASSWORD, from
+// `cscli machines add homelab-cli --auto` on the LAPI pod) they talk to the
+// LAPI HTTP API directly: structured JSON, real status codes, and no
+// pods/exec grant. Without them they fall back to exec'ing cscli on the LAPI
+// pod, as they always did.
+//
+// The native client is a machine (watcher), not a bouncer like the Traefik
+// plugin in stacks/traefik: a bouncer key can read /v1/decisions but cannot
+// create or delete them, and cannot read alerts. LAPI has no write endpoint
+// for allowlists, so `allowlist add/rm` always go through cscli.
+
+// crowdsecLapiService is the in-cluster LAPI Service the native client
+// port-forwards to when CROWDSEC_LAPI_URL is not set.
+const (
+	crowdsecLapiService = "crowdsec-service"
+	crowdsecLapiPort    = 8080
+)
+
+// csFilter narrows an alert/decision listing. Origin and Scope are sent to
+// LAPI and applied again locally (filterDecisions), so both backends filter
+// the same way whatever the server honours. Scenario is filtered locally only:
+// the server would match it against the alert, not the decision.
+type csFilter struct {
+	Target      string // IP or CIDR; "" for all
+	Active      bool   // only alerts with a decision still in force
+	IncludeCAPI bool
+	Origin      string
+	Scenario    string
+	Scope       string
+}
+
+// csBackend is what the crowdsec verbs need from LAPI.
+type csBackend interface {
+	// alerts lists alerts with their decisions.
+	alerts(f csFilter) ([]csAlert, error)
+	// addBan creates a manual ban decision.
+	addBan(target string, d time.Duration, reason string) error
+	// deleteDecisions removes every decision on target; -1 when the backend
+	// cannot tell how many.
+	deleteDecisions(target string) (int, error)
+	// allowlist reads the crowdsecAllowlist entries; exists is false when
+	// the list has not been created.
+	allowlist() (items []csAllowlistItem, exists bool, err error)
+	close()
+}
+
+// newCSBackend picks the native client when machine credentials are set and
+// the cscli exec path otherwise.
+func newCSBackend() (csBackend, error) {
+	id, pw := firstEnv("CROWDSEC_MACHINE_ID"), firstEnv("CROWDSEC_MACHINE_PASSWORD")
+	if id == "" || pw == "" {
+		pod, err := crowdsecLapiPod()
+		if err != nil {
+			return nil, err
+		}
+		return cscliBackend{pod}, nil
+	}
+	return newLapiClient(id, pw)
+}
+
+// csScope normalises a --scope value to LAPI's spelling ("Ip", "Range");
+// other scopes (Country, AS, ...) pass through.
+func csScope(s string) string {
+	switch strings.ToLower(s) {
+	case "ip":
+		return "Ip"
+	case "range":
+		return "Range"
+	}
+	return s
+}
+
+// filterDecisions keeps only the decisions matching f (case-insensitively,
+// as LAPI capitalises scopes) and drops alerts left with none. With f.Active
+// it also drops decisions LAPI reports as already expired (a negative
+// remaining duration).
+func filterDecisions(alerts []csAlert, f csFilter) []csAlert {
+	match := func(want, got string) bool { return want == "" || strings.EqualFold(want, got) }
+	var out []csAlert
+	for _, a := range alerts {
+		var keep []csDecision
+		for _, d := range a.Decisions {
+			if f.Active && strings.HasPrefix(d.Duration, "-") {
+				continue
+			}
+			if !f.IncludeCAPI && strings.EqualFold(d.Origin, "CAPI") {
+				continue
+			}
+			if match(f.Origin, d.Origin) && match(f.Scenario, d.Scenario) && match(f.Scope, d.Scope) {
+				keep = append(keep, d)
+			}
+		}
+		if len(keep) > 0 {
+			a.Decisions = keep
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// --- native LAPI client ----------------------------------------------------
+
+type lapiClient struct {
+	base     string
+	token    string
+	http     *http.Client
+	teardown func()
+}
+
+// newLapiClient logs in as a machine. It uses CROWDSEC_LAPI_URL when set
+// (e.g. when run inside the cluster), else a port-forward to the LAPI
+// Service, which needs only the pods/portforward grant the browser verbs
+// already rely on.
+func newLapiClient(id, password string) (*lapiClient, error) {
+	c := &lapiClient{http: &http.Client{Timeout: 20 * time.Second}, teardown: func() {}}
+	if u := firstEnv("CROWDSEC_LAPI_URL"); u != "" {
+		c.base = strings.TrimRight(strings.TrimSuffix(strings.TrimRight(u, "/"), "/v1"), "/")
+	} else {
+		port, td, logbuf, err := startForwardIn(crowdsecNamespace, "svc/"+crowdsecLapiService, crowdsecLapiPort)
+		if err != nil {
+			return nil, err
+		}
+		c.teardown = td
+		c.base = fmt.Sprintf("http://127.0.0.1:%d", port)
+		if err := waitHTTP(c.base+"/health", 15*time.Second); err != nil {
+			td()
+			return nil, fmt.Errorf("CrowdSec LAPI not reachable via port-forward: %w\n--- port-forward log ---\n%s", err, logbuf.String())
+		}
+	}
+	body, err := c.do("POST", "/v1/watchers/login", nil, map[string]interface{}{
+		"machine_id": id, "password": password, "scenarios": []string{},
+	})
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("LAPI login as machine %q failed (check CROWDSEC_MACHINE_ID/CROWDSEC_MACHINE_PASSWORD, or unset them to use cscli): %w", id, err)
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &login); err != nil || login.Token == "" {
+		c.close()
+		return nil, fmt.Errorf("LAPI login: no token in response")
+	}
+	c.token = login.Token
+	return c, nil
+}
+
+func (c *lapiClient) close() { c.teardown() }
+
+// lapiError is the error body LAPI answers with.
+type lapiError struct {
+	Message string `json:"message"`
+}
+
+// errLapiNotFound marks a 404, which callers may treat as "absent".
+type errLapiNotFound struct{ error }
+
+func (c *lapiClient) do(method, path string, q url.Values, body interface{}) ([]byte, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	u := c.base + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "homelab-cli")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(out))
+		var e lapiError
+		if json.Unmarshal(out, &e) == nil && e.Message != "" {
+			msg = e.Message
+		}
+		err := fmt.Errorf("LAPI %s %s -> %d: %s", method, path, resp.StatusCode, msg)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, errLapiNotFound{err}
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// targetParam is the LAPI query parameter for a target: a CIDR is a range.
+func targetParam(target string) string {
+	return strings.TrimPrefix(cscliTargetFlag(target), "--")
+}
+
+func (c *lapiClient) alerts(f csFilter) ([]csAlert, error) {
+	q := url.Values{}
+	q.Set("limit", "0")
+	if f.Target != "" {
+		q.Set(targetParam(f.Target), f.Target)
+	}
+	if f.Active {
+		q.Set("has_active_decision", "true")
+	}
+	q.Set("include_capi", strconv.FormatBool(f.IncludeCAPI))
+	if f.Origin != "" {
+		q.Set("origin", f.Origin)
+	}
+	// No scenario parameter: LAPI matches it against the alert's scenario, which
+	// for a manual ban is "manual 'ban' from '<machine>'", not the reason the
+	// decision carries. filterDecisions matches the decision's instead.
+	if f.Scope != "" {
+		q.Set("scope", csScope(f.Scope))
+	}
+	body, err := c.do("GET", "/v1/alerts", q, nil)
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := parseCSAlerts(body)
+	if err != nil {
+		return nil, err
+	}
+	if f.Active || f.Origin != "" || f.Scenario != "" || f.Scope != "" {
+		alerts = filterDecisions(alerts, f)
+	}
+	return alerts, nil
+}
+
+// manualBanAlert is the alert cscli itself posts for `decisions add`: LAPI
+// only creates decisions as part of an alert.
+func manualBanAlert(machine, target string, d time.Duration, reason string, now time.Time) map[string]interface{} {
+	scope, ip := "Ip", target
+	if strings.Contains(target, "/") {
+		scope, ip = "Range", ""
+	}
+	msg := fmt.Sprintf("manual 'ban' from '%s'", machine)
+	ts := now.UTC().Format(time.RFC3339)
+	return map[string]interface{}{
+		"scenario": msg, "scenario_hash": "", "scenario_version": "", "message": msg,
+		"capacity": 0, "leakspeed": "0", "simulated": false, "remediation": true,
+		"events": []interface{}{}, "events_count": 1,
+		"start_at": ts, "stop_at": ts,
+		"source": map[string]string{"scope": scope, "value": target, "ip": ip},
+		"decisions": []map[string]string{{
+			"duration": humanDuration(d), "origin": "cscli", "scenario": reason,
+			"scope": scope, "type": "ban", "value": target,
+		}},
+	}
+}
+
+func (c *lapiClient) addBan(target string, d time.Duration, reason string) error {
+	alert := manualBanAlert(firstEnv("CROWDSEC_MACHINE_ID"), target, d, reason, time.Now())
+	_, err := c.do("POST", "/v1/alerts", nil, []interface{}{alert})
+	return err
+}
+
+func (c *lapiClient) deleteDecisions(target string) (int, error) {
+	q := url.Values{}
+	q.Set(targetParam(target), target)
+	body, err := c.do("DELETE", "/v1/decisions", q, nil)
+	if err != nil {
+		return 0, err
+	}
+	var r struct {
+		NbDeleted string `json:"nbDeleted"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(r.NbDeleted)
+	if err != nil {
+		return -1, nil
+	}
+	return n, nil
+}
+
+func (c *lapiClient) allowlist() ([]csAllowlistItem, bool, error) {
+	q := url.Values{}
+	q.Set("with_content", "true")
+	body, err := c.do("GET", "/v1/allowlists/"+url.PathEscape(crowdsecAllowlist), q, nil)
+	if _, ok := err.(errLapiNotFound); ok {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	items, err := parseCSAllowlist(body)
+	return items, true, err
+}
+
+// parseCSAllowlist decodes an allowlist (`cscli allowlists inspect -o json` and
+// LAPI's GET /v1/allowlists/{name} share the shape).
+func parseCSAllowlist(body []byte) ([]csAllowlistItem, error) {
+	var l struct {
+		Items []csAllowlistItem `json:"items"`
+	}
+	if err := json.Unmarshal(body, &l); err != nil {
+		return nil, fmt.Errorf("cannot parse allowlist: %w", err)
+	}
+	return l.Items, nil
+}
+
+// --- cscli exec fallback ---------------------------------------------------
+
+type cscliBackend struct{ pod string }
+
+func (b cscliBackend) close() {}
+
+func (b cscliBackend) alerts(f csFilter) ([]csAlert, error) {
+	var args []string
+	if f.Active {
+		args = []string{"decisions", "list", "-o", "json", "--limit", "0"}
+		if f.IncludeCAPI {
+			args = append(args, "-a")
+		}
+		if f.Origin != "" {
+			args = append(args, "--origin", f.Origin)
+		}
+		if f.Scope != "" {
+			args = append(args, "--scope", csScope(f.Scope))
+		}
+	} else {
+		args = []string{"alerts", "list", "-o", "json", "--limit", "0"}
+	}
+	if f.Target != "" {
+		args = append(args, cscliTargetFlag(f.Target), f.Target)
+	}
+	out, err := cscliCapture(b.pod, args...)
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := parseCSAlerts(out)
+	if err != nil {
+		return nil, err
+	}
+	if f.Active || f.Origin != "" || f.Scenario != "" || f.Scope != "" {
+		alerts = filterDecisions(alerts, f)
+	}
+	return alerts, nil
+}
+
+func (b cscliBackend) addBan(target string, d time.Duration, reason string) error {
+	_, err := cscliCapture(b.pod, "decisions", "add", cscliTargetFlag(target), target, "--duration", humanDuration(d), "--reason", reason)
+	return err
+}
+
+// cscliDeletedRe reads the count from cscli's "N decision(s) deleted" log.
+var cscliDeletedRe = regexp.MustCompile(`(\d+) decision\(s\) deleted`)
+
+func (b cscliBackend) deleteDecisions(target string) (int, error) {
+	out, err := cscliCombined(b.pod, "decisions", "delete", cscliTargetFlag(target), target)
+	if err != nil {
+		return 0, err
+	}
+	if m := cscliDeletedRe.FindStringSubmatch(string(out)); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n, nil
+	}
+	return -1, nil
+}
+
+func (b cscliBackend) allowlist() ([]csAllowlistItem, bool, error) {
+	return csAllowlistItems(b.pod)
+}
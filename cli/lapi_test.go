@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newLapiTestServer fakes the LAPI endpoints the native client uses and
+// points newLapiClient at it.
+func newLapiTestServer(t *testing.T, handler http.HandlerFunc) *lapiClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/watchers/login" {
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["machine_id"] != "homelab-cli" || body["password"] != "pw" {
+				w.WriteHeader(http.StatusForbidden)
+				io.WriteString(w, `{"message":"incorrect Username or Password"}`)
+				return
+			}
+			io.WriteString(w, `{"code":200,"expire":"2026-10-19T12:00:00Z","token":"jwt"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer jwt" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("CROWDSEC_LAPI_URL", srv.URL+"/v1/")
+	t.Setenv("CROWDSEC_MACHINE_ID", "homelab-cli")
+	c, err := newLapiClient("homelab-cli", "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLapiLoginFailureIsExplained(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"message":"incorrect Username or Password"}`)
+	}))
+	defer srv.Close()
+	t.Setenv("CROWDSEC_LAPI_URL", srv.URL)
+	_, err := newLapiClient("homelab-cli", "wrong")
+	if err == nil || !strings.Contains(err.Error(), "incorrect Username or Password") || !strings.Contains(err.Error(), "unset them to use cscli") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLapiAlertsFiltersTwice(t *testing.T) {
+	var query string
+	c := newLapiTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		// a LAPI that ignored the origin filter: the client must still apply it
+		io.WriteString(w, `[{"id":3,"decisions":[
+			{"id":30,"origin":"cscli","scenario":"scanner range","type":"ban","scope":"Ip","value":"1.2.3.4","duration":"3h"},
+			{"id":31,"origin":"crowdsec","type":"ban","scope":"Ip","value":"1.2.3.4","duration":"1h"},
+			{"id":32,"origin":"cscli","type":"ban","scope":"Ip","value":"1.2.3.4","duration":"-5m"}]}]`)
+	})
+	alerts, err := c.alerts(csFilter{Target: "1.2.3.4", Active: true, Origin: "cscli", Scope: "ip", Scenario: "scanner range"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(query, "scenario") {
+		t.Errorf("query %q sends scenario: LAPI would match it against the alert, not the decision", query)
+	}
+	for _, want := range []string{"ip=1.2.3.4", "has_active_decision=true", "include_capi=false", "origin=cscli", "scope=Ip", "limit=0"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query %q missing %q", query, want)
+		}
+	}
+	if len(alerts) != 1 || len(alerts[0].Decisions) != 1 || alerts[0].Decisions[0].ID != 30 {
+		t.Fatalf("want only the active cscli decision, got %+v", alerts)
+	}
+}
+
+func TestLapiAddBanPostsManualAlert(t *testing.T) {
+	var posted []map[string]interface{}
+	c := newLapiTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/alerts" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&posted)
+		io.WriteString(w, `["42"]`)
+	})
+	if err := c.addBan("5.6.7.0/24", 4*time.Hour, "scanner range"); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 1 {
+		t.Fatalf("posted %v", posted)
+	}
+	d := posted[0]["decisions"].([]interface{})[0].(map[string]interface{})
+	if d["scope"] != "Range" || d["value"] != "5.6.7.0/24" || d["duration"] != "4h" || d["scenario"] != "scanner range" || d["origin"] != "cscli" {
+		t.Fatalf("decision = %v", d)
+	}
+	if posted[0]["scenario"] != "manual 'ban' from 'homelab-cli'" {
+		t.Fatalf("scenario = %v", posted[0]["scenario"])
+	}
+}
+
+func TestLapiDeleteAndAllowlist(t *testing.T) {
+	c := newLapiTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "DELETE" && r.URL.Query().Get("range") == "5.6.7.0/24":
+			io.WriteString(w, `{"nbDeleted":"2"}`)
+		case r.URL.Path == "/v1/allowlists/"+crowdsecAllowlist:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message":"allowlist not found"}`)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	if n, err := c.deleteDecisions("5.6.7.0/24"); err != nil || n != 2 {
+		t.Fatalf("deleteDecisions = %d, %v", n, err)
+	}
+	items, exists, err := c.allowlist()
+	if err != nil || exists || items != nil {
+		t.Fatalf("a missing allowlist is empty, not an error: %v %v %v", items, exists, err)
+	}
+}
+
+func TestDecisionRowsAndFormat(t *testing.T) {
+	a := csAlert{ID: 9, Decisions: []csDecision{{ID: 90, Type: "ban", Scope: "Ip", Value: "1.2.3.4", Origin: "crowdsec", Scenario: "crowdsecurity/http-probing", Duration: "3h59m"}}}
+	a.Source.AsName, a.Source.Cn = "EXAMPLE-AS", "NL"
+	older := csAlert{ID: 2, Decisions: []csDecision{{ID: 20, Type: "ban", Scope: "Range", Value: "5.6.7.0/24", Origin: "cscli", Scenario: "scanner range", Duration: "20h"}}}
+	rows := decisionRows([]csAlert{older, a})
+	if len(rows) != 2 || rows[0].ID != 90 || rows[0].Country != "NL" {
+		t.Fatalf("rows = %+v", rows)
+	}
+	out := formatDecisions(rows)
+	if !strings.Contains(out, "Ip:1.2.3.4") || !strings.Contains(out, "EXAMPLE-AS NL") || !strings.HasSuffix(out, "2 decision(s)\n") {
+		t.Fatalf("table:\n%s", out)
+	}
+	if formatDecisions(nil) != "(no decisions in force)\n" {
+		t.Fatal("empty table")
+	}
+}
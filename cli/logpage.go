@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// logEntry is one Loki log line with its stream labels.
+type logEntry struct {
+	TS     int64             `json:"ts"` // unix ns
+	Labels map[string]string `json:"labels"`
+	Line   string            `json:"line"`
+}
+
+func (e logEntry) key() string {
+	return strconv.FormatInt(e.TS, 10) + "\x00" + labelStr(e.Labels) + "\x00" + e.Line
+}
+
+// parseStreams decodes a /loki/api/v1/query_range streams body.
+func parseStreams(body []byte) ([]logEntry, error) {
+	var r struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Result []struct {
+				Stream map[string]string `json:"stream"`
+				Values [][2]string       `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("cannot parse Loki response: %w", err)
+	}
+	if r.Status == "error" {
+		return nil, fmt.Errorf("loki: %s", r.Error)
+	}
+	var out []logEntry
+	for _, s := range r.Data.Result {
+		for _, v := range s.Values {
+			ts, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			out = append(out, logEntry{TS: ts, Labels: s.Stream, Line: v[1]})
+		}
+	}
+	return out, nil
+}
+
+// pageBackwards fetches [startNs, endNs) a page at a time, newest first — the
+// order Loki's limit works in — moving end back to the oldest timestamp each
+// page returned, until a page comes back short (the window is covered) or
+// maxLines are collected. fetch(end) must return at most pageLimit entries
+// older than end.
+//
+// Lines sharing the boundary timestamp can straddle two pages, so the next
+// page asks for end = oldest+1 and drops what it has already seen. When a
+// whole page is lines at one timestamp the cursor cannot move that way, so it
+// steps past the timestamp instead (some of those lines may be skipped —
+// reported via the complete=false result).
+//
+// The result is in time order, de-duplicated; complete reports whether the
+// whole window was covered.
+func pageBackwards(fetch func(endNs int64) ([]logEntry, error), startNs, endNs int64, pageLimit, maxLines int) ([]logEntry, bool, error) {
+	seen := map[string]bool{}
+	var all []logEntry
+	end := endNs
+	complete := true
+	for {
+		page, err := fetch(end)
+		if err != nil {
+			return nil, false, err
+		}
+		oldest, added := end, 0
+		for _, e := range page {
+			if e.TS < oldest {
+				oldest = e.TS
+			}
+			if seen[e.key()] {
+				continue
+			}
+			seen[e.key()] = true
+			all = append(all, e)
+			added++
+		}
+		if len(page) < pageLimit || oldest <= startNs {
+			break
+		}
+		if len(all) >= maxLines {
+			complete = false
+			break
+		}
+		next := oldest + 1
+		if added == 0 || next >= end {
+			next = oldest // stuck on one timestamp: step over it
+			complete = false
+		}
+		end = next
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].TS < all[j].TS })
+	if len(all) > maxLines {
+		all = all[len(all)-maxLines:] // keep the newest, as a single query would
+		complete = false
+	}
+	return all, complete, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// logMasks turn a log line into its template. Order matters: timestamps and
+// UUIDs contain digit runs the later masks would otherwise split, and an IP
+// must go before the plain-number mask eats its octets.
+var logMasks = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`), "<TS>"},
+	{regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}(?: [+-]\d{4})?`), "<TS>"}, // common log format
+	{regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(?:[.,]\d+)?\b`), "<TS>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<UUID>"},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?::\d+)?\b`), "<IP>"},
+	{regexp.MustCompile(`(?i)\b(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{1,4}\b`), "<IP>"},
+	{regexp.MustCompile(`\b(?:0x)?[0-9a-fA-F]{8,}\b`), "<HEX>"}, // see logTemplate
+	// a number not glued to a preceding letter: "took 231ms" and "pod-7"
+	// vary, "k8s" and "http2" are names
+	{regexp.MustCompile(`(^|[^A-Za-z0-9_.])\d+(?:\.\d+)?`), "${1}<NUM>"},
+}
+
+// logTemplate masks the variable parts of a line — timestamps, UUIDs, IPs,
+// hex ids and numbers — so lines that differ only in those group together.
+func logTemplate(line string) string {
+	t := strings.TrimSpace(line)
+	for _, m := range logMasks {
+		if m.repl == "<HEX>" {
+			// a hash or id mixes letters and digits; an all-letter run is a
+			// word ("deadline"), an all-digit one is left to <NUM>
+			t = m.re.ReplaceAllStringFunc(t, func(s string) string {
+				h := strings.TrimPrefix(s, "0x")
+				if strings.IndexAny(h, "0123456789") < 0 || strings.IndexAny(strings.ToLower(h), "abcdef") < 0 {
+					return s
+				}
+				return m.repl
+			})
+			continue
+		}
+		t = m.re.ReplaceAllString(t, m.repl)
+	}
+	return t
+}
+
+// logPattern is one template and how often it occurred.
+type logPattern struct {
+	Template string `json:"template"`
+	Count    int    `json:"count"`
+	Example  string `json:"example"`
+	New      bool   `json:"new,omitempty"`
+}
+
+// clusterLines groups lines by template, most frequent first (ties by
+// template, for stable output). When baseline is non-nil, templates absent
+// from it are marked New and sorted ahead of the rest, so --top never hides a
+// rare new template behind the usual noise.
+func clusterLines(lines []string, baseline map[string]bool) []logPattern {
+	idx := map[string]int{}
+	var out []logPattern
+	for _, l := range lines {
+		t := logTemplate(l)
+		if i, ok := idx[t]; ok {
+			out[i].Count++
+			continue
+		}
+		idx[t] = len(out)
+		out = append(out, logPattern{Template: t, Count: 1, Example: strings.TrimSpace(l), New: baseline != nil && !baseline[t]})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].New != out[j].New {
+			return out[i].New
+		}
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Template < out[j].Template
+	})
+	return out
+}
+
+// templateSet is the set of templates seen in lines — the baseline --compare
+// checks against.
+func templateSet(lines []string) map[string]bool {
+	set := map[string]bool{}
+	for _, l := range lines {
+		set[logTemplate(l)] = true
+	}
+	return set
+}
+
+// formatPatterns renders the top patterns, one per line with its count, new
+// ones marked, and a footer. Templates longer than width are cut.
+func formatPatterns(patterns []logPattern, total, top, width int, compared bool) string {
+	if len(patterns) == 0 {
+		return "(no log lines)\n"
+	}
+	var b strings.Builder
+	newCount := 0
+	for i, p := range patterns {
+		if p.New {
+			newCount++
+		}
+		if i >= top {
+			continue
+		}
+		mark := "   "
+		if p.New {
+			mark = "NEW"
+		}
+		t := p.Template
+		if r := []rune(t); width > 0 && len(r) > width {
+			t = string(r[:width-1]) + "…"
+		}
+		if compared {
+			fmt.Fprintf(&b, "%7d  %s  %s\n", p.Count, mark, t)
+		} else {
+			fmt.Fprintf(&b, "%7d  %s\n", p.Count, t)
+		}
+	}
+	fmt.Fprintf(&b, "%d lines, %d templates", total, len(patterns))
+	if len(patterns) > top {
+		fmt.Fprintf(&b, " (top %d shown)", top)
+	}
+	if compared {
+		fmt.Fprintf(&b, ", %d new since the comparison window", newCount)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
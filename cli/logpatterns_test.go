@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogTemplate(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{`2026-10-19T14:03:07.251Z INFO request done in 231ms status=200`, `<TS> INFO request done in <NUM>ms status=<NUM>`},
+		{`10.0.20.203:443 - - [19/Oct/2026:14:03:07 +0000] "GET /api/assets/0d4c2f6e-1b7a-4c55-9e0f-3a2b1c4d5e6f HTTP/1.1" 404`,
+			`<IP> - - [<TS>] "GET /api/assets/<UUID> HTTP/<NUM>" <NUM>`},
+		{`worker-7 picked job 4411 (sha 9f3e2a1bc0d4)`, `worker-<NUM> picked job <NUM> (sha <HEX>)`},
+		{`deadline exceeded on k8s-node3 via http2`, `deadline exceeded on k8s-node3 via http2`},
+		{`conn from fe80::1c2d:3e4f:5a6b:7c8d reset`, `conn from <IP> reset`},
+		{`  level=error msg="db locked"  `, `level=error msg="db locked"`},
+	} {
+		if got := logTemplate(c.in); got != c.want {
+			t.Errorf("logTemplate(%q)\n got %q\nwant %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestClusterLines(t *testing.T) {
+	lines := []string{
+		"GET /health 200 1ms",
+		"GET /health 200 3ms",
+		"upload failed: disk full",
+		"GET /health 200 2ms",
+		"job 12 done",
+		"job 13 done",
+	}
+	got := clusterLines(lines, nil)
+	if len(got) != 3 {
+		t.Fatalf("got %d templates: %+v", len(got), got)
+	}
+	if got[0].Template != "GET /health <NUM> <NUM>ms" || got[0].Count != 3 || got[0].Example != "GET /health 200 1ms" {
+		t.Errorf("top = %+v", got[0])
+	}
+	if got[1].Count != 2 || got[2].Template != "upload failed: disk full" {
+		t.Errorf("order = %+v", got)
+	}
+	for _, p := range got {
+		if p.New {
+			t.Errorf("%q marked new without a baseline", p.Template)
+		}
+	}
+
+	baseline := templateSet([]string{"GET /health 200 9ms", "job 1 done"})
+	got = clusterLines(lines, baseline)
+	if got[0].Template != "upload failed: disk full" {
+		t.Errorf("new template not first: %+v", got)
+	}
+	for _, p := range got {
+		if p.New != (p.Template == "upload failed: disk full") {
+			t.Errorf("%q New=%v", p.Template, p.New)
+		}
+	}
+}
+
+func TestFormatPatterns(t *testing.T) {
+	patterns := []logPattern{
+		{Template: "GET /health <NUM>", Count: 40},
+		{Template: "upload failed: " + strings.Repeat("x", 50), Count: 2, New: true},
+		{Template: "job <NUM> done", Count: 1},
+	}
+	out := formatPatterns(patterns, 43, 2, 30, true)
+	want := "     40       GET /health <NUM>\n" +
+		"      2  NEW  upload failed: xxxxxxxxxxxxxx…\n" +
+		"43 lines, 3 templates (top 2 shown), 1 new since the comparison window\n"
+	if out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+	if got := formatPatterns(patterns[:1], 40, 10, 0, false); got != "     40  GET /health <NUM>\n40 lines, 1 templates\n" {
+		t.Errorf("no compare:\n%s", got)
+	}
+	if formatPatterns(nil, 0, 10, 0, false) != "(no log lines)\n" {
+		t.Error("empty output")
+	}
+}
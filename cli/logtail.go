@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTailFrame decodes one /loki/api/v1/tail websocket message into entries
+// in timestamp order (a frame carries several streams, each in order), plus
+// the number of entries Loki dropped because the client fell behind.
+func parseTailFrame(msg []byte) ([]logEntry, int, error) {
+	var f struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+		DroppedEntries []json.RawMessage `json:"dropped_entries"`
+	}
+	if err := json.Unmarshal(msg, &f); err != nil {
+		return nil, 0, fmt.Errorf("cannot parse Loki tail frame: %w", err)
+	}
+	var out []logEntry
+	for _, s := range f.Streams {
+		for _, v := range s.Values {
+			ts, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			out = append(out, logEntry{TS: ts, Labels: s.Stream, Line: v[1]})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].TS < out[j].TS })
+	return out, len(f.DroppedEntries), nil
+}
+
+// tailCursor remembers how far the tail has printed, so a reconnect can ask
+// Loki to resume from the last timestamp (start is inclusive) without
+// printing the overlap twice.
+type tailCursor struct {
+	last   int64
+	atLast map[string]bool // stream+line already printed at exactly last
+}
+
+// admit reports whether e is new, and advances the cursor if so.
+func (c *tailCursor) admit(e logEntry) bool {
+	key := labelStr(e.Labels) + "\x00" + e.Line
+	switch {
+	case e.TS < c.last:
+		return false
+	case e.TS == c.last:
+		if c.atLast[key] {
+			return false
+		}
+	default:
+		c.last, c.atLast = e.TS, map[string]bool{}
+	}
+	c.atLast[key] = true
+	return true
+}
+
+// resumeFrom is the start for the next connection: the last printed
+// timestamp, or fallback before anything was printed.
+func (c *tailCursor) resumeFrom(fallback time.Time) time.Time {
+	if c.last == 0 {
+		return fallback
+	}
+	return time.Unix(0, c.last)
+}
+
+// streamTag is the short stream identity printed before each line:
+// namespace/pod[container] when the stream has them (the kubernetes-sourced
+// streams do), otherwise every label.
+func streamTag(labels map[string]string) string {
+	ns, pod, ctr := labels["namespace"], labels["pod"], labels["container"]
+	if ns == "" && pod == "" {
+		return labelStr(labels)
+	}
+	tag := ns
+	if pod != "" {
+		if tag != "" {
+			tag += "/"
+		}
+		tag += pod
+	}
+	if ctr != "" {
+		tag += "[" + ctr + "]"
+	}
+	return tag
+}
+
+// tagColours are the ANSI foregrounds streams are spread over; dim/bold and
+// red are left out so they keep meaning "dim" and "error".
+var tagColours = []string{"32", "33", "34", "35", "36", "92", "93", "94", "95", "96"}
+
+// colourTag wraps tag in a colour chosen by hashing it, so one stream keeps
+// its colour for the whole session and interleaved pods are easy to tell apart.
+func colourTag(tag string) string {
+	h := fnv.New32a()
+	h.Write([]byte(tag))
+	return "\x1b[" + tagColours[h.Sum32()%uint32(len(tagColours))] + "m" + tag + "\x1b[0m"
+}
+
+// formatTailLine renders one entry as `15:04:05.000 tag line`.
+func formatTailLine(e logEntry, colour bool) string {
+	tag := streamTag(e.Labels)
+	if colour {
+		tag = colourTag(tag)
+	}
+	return time.Unix(0, e.TS).Format("15:04:05.000") + " " + tag + " " + strings.TrimRight(e.Line, "\n")
+}
+
+// compileGrep builds the --grep filter; an empty pattern matches everything.
+func compileGrep(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("bad --grep %q: %w", pattern, err)
+	}
+	return re, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTailFrame(t *testing.T) {
+	msg := `{"streams":[
+	  {"stream":{"namespace":"immich","pod":"immich-server-0","container":"server"},"values":[["1760871602000000000","b"],["1760871604000000000","d"]]},
+	  {"stream":{"namespace":"immich","pod":"immich-ml-0"},"values":[["1760871601000000000","a"],["1760871603000000000","c"]]}
+	 ],"dropped_entries":[{"labels":{},"timestamp":"1760871600000000000"}]}`
+	entries, dropped, err := parseTailFrame([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, e := range entries {
+		lines = append(lines, e.Line)
+	}
+	if strings.Join(lines, "") != "abcd" || dropped != 1 {
+		t.Errorf("lines %v dropped %d; want abcd in time order, 1 dropped", lines, dropped)
+	}
+	if _, _, err := parseTailFrame([]byte("not json")); err == nil {
+		t.Error("bad frame accepted")
+	}
+}
+
+func TestTailCursorResume(t *testing.T) {
+	fallback := time.Unix(100, 0)
+	c := &tailCursor{}
+	if !c.resumeFrom(fallback).Equal(fallback) {
+		t.Error("fresh cursor should resume from the fallback")
+	}
+	a := map[string]string{"pod": "a"}
+	b := map[string]string{"pod": "b"}
+	first := []logEntry{{TS: 10, Labels: a, Line: "x"}, {TS: 20, Labels: a, Line: "y"}, {TS: 20, Labels: b, Line: "y"}}
+	for _, e := range first {
+		if !c.admit(e) {
+			t.Fatalf("first pass rejected %+v", e)
+		}
+	}
+	if got := c.resumeFrom(fallback).UnixNano(); got != 20 {
+		t.Errorf("resumeFrom = %d, want 20", got)
+	}
+	// After a reconnect Loki replays from ts 20 inclusive.
+	replay := []logEntry{{TS: 20, Labels: b, Line: "y"}, {TS: 20, Labels: a, Line: "y"}, {TS: 20, Labels: a, Line: "z"}, {TS: 30, Labels: a, Line: "y"}}
+	var admitted []string
+	for _, e := range replay {
+		if c.admit(e) {
+			admitted = append(admitted, e.Line)
+		}
+	}
+	if strings.Join(admitted, ",") != "z,y" {
+		t.Errorf("admitted %v after reconnect, want [z y]", admitted)
+	}
+	if c.admit(logEntry{TS: 15, Labels: a, Line: "late"}) {
+		t.Error("entry older than the cursor admitted")
+	}
+}
+
+func TestStreamTag(t *testing.T) {
+	for _, c := range []struct {
+		labels map[string]string
+		want   string
+	}{
+		{map[string]string{"namespace": "immich", "pod": "immich-server-0", "container": "server", "job": "x"}, "immich/immich-server-0[server]"},
+		{map[string]string{"namespace": "traefik"}, "traefik"},
+		{map[string]string{"job": "homelab-cli", "user": "emo"}, "{job=homelab-cli,user=emo}"},
+	} {
+		if got := streamTag(c.labels); got != c.want {
+			t.Errorf("streamTag(%v) = %q, want %q", c.labels, got, c.want)
+		}
+	}
+}
+
+func TestFormatTailLine(t *testing.T) {
+	ts := time.Date(2026, 10, 19, 14, 3, 7, 250e6, time.Local).UnixNano()
+	e := logEntry{TS: ts, Labels: map[string]string{"namespace": "immich", "pod": "p"}, Line: "GET /api 200\n"}
+	if got := formatTailLine(e, false); got != "14:03:07.250 immich/p GET /api 200" {
+		t.Errorf("plain = %q", got)
+	}
+	coloured := formatTailLine(e, true)
+	if !strings.Contains(coloured, "\x1b[") || !strings.Contains(coloured, "immich/p\x1b[0m GET") {
+		t.Errorf("coloured = %q", coloured)
+	}
+	if colourTag("immich/p") != colourTag("immich/p") {
+		t.Error("a stream's colour must be stable")
+	}
+}
+
+func TestCompileGrep(t *testing.T) {
+	if re, err := compileGrep(""); re != nil || err != nil {
+		t.Errorf("empty pattern = %v, %v", re, err)
+	}
+	re, err := compileGrep(`5\d\d`)
+	if err != nil || !re.MatchString("status=502") || re.MatchString("status=200") {
+		t.Errorf("compileGrep(5\\d\\d) = %v, %v", re, err)
+	}
+	if _, err := compileGrep("("); err == nil {
+		t.Error("bad regex accepted")
+	}
+}
@@ -166,7 +166,7 @@ func run() error {
 		glog.Infof("successfully added %s -> %s email aliasing", emailAlias, *emailToForwardTo)
 	case updatePublicIPUseCaseFlagName:
 		// Resolve the dynamic dns record
-		publicDNSIps, err := net.LookupIP(*publicDomain)
+		publicDNSIps, err := lookupIPs(*publicDomain)
 		if err != nil {
 			return errors.Wrapf(err, "failed to resolve IP addresses")
 		}
@@ -184,7 +184,7 @@ func run() error {
 		}
 
 		// Resolve the dynamic dns record
-		dynamicDNSIps, err := net.LookupIP(*dynDnsDomain)
+		dynamicDNSIps, err := lookupIPs(*dynDnsDomain)
 		if err != nil {
 			return errors.Wrap(err, "failed to resolve IP addresses")
 		}
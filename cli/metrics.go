@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sparkWidth is how many columns a `metrics query --range` sparkline spans;
+// longer series are bucketed down to it.
+const sparkWidth = 60
+
+// promMaxPoints is Prometheus' per-series cap for query_range (range/step).
+const promMaxPoints = 11000
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// rangePoint is one sample of a range-query series.
+type rangePoint struct {
+	T time.Time
+	V float64
+}
+
+// rangeSeries is one matrix series, labels already rendered by labelStr.
+type rangeSeries struct {
+	Labels string
+	Points []rangePoint
+}
+
+// parseMatrix decodes a /api/v1/query_range body. Values arrive as strings
+// (Prometheus encodes NaN and ±Inf that way); those parse to the float
+// specials and are skipped by the summaries.
+func parseMatrix(body []byte) ([]rangeSeries, error) {
+	var r struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Metric map[string]string `json:"metric"`
+				Values [][2]interface{}  `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("cannot parse query_range response: %w", err)
+	}
+	if r.Status == "error" {
+		return nil, fmt.Errorf("prometheus: %s", r.Error)
+	}
+	out := make([]rangeSeries, 0, len(r.Data.Result))
+	for _, s := range r.Data.Result {
+		rs := rangeSeries{Labels: labelStr(s.Metric)}
+		for _, v := range s.Values {
+			ts, ok := v[0].(float64)
+			str, ok2 := v[1].(string)
+			if !ok || !ok2 {
+				continue
+			}
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil {
+				continue
+			}
+			sec, frac := math.Modf(ts)
+			rs.Points = append(rs.Points, rangePoint{T: time.Unix(int64(sec), int64(frac*1e9)), V: f})
+		}
+		out = append(out, rs)
+	}
+	return out, nil
+}
+
+func finite(v float64) bool { return !math.IsNaN(v) && !math.IsInf(v, 0) }
+
+// seriesStats is the min (lo), max (hi) and last finite sample; ok is false
+// when the series has none.
+func seriesStats(points []rangePoint) (lo, hi, last float64, ok bool) {
+	for _, p := range points {
+		if !finite(p.V) {
+			continue
+		}
+		if !ok || p.V < lo {
+			lo = p.V
+		}
+		if !ok || p.V > hi {
+			hi = p.V
+		}
+		last, ok = p.V, true
+	}
+	return lo, hi, last, ok
+}
+
+// sparkline places points on width columns spanning [start, end] by
+// timestamp — so a scrape gap shows as blank columns rather than being
+// squeezed out — averaging points that share a column, and scales the column
+// values to the eight block heights. A flat series renders at the lowest tick.
+func sparkline(points []rangePoint, start, end time.Time, width int) string {
+	sums := make([]float64, width)
+	counts := make([]int, width)
+	span := end.Sub(start)
+	for _, p := range points {
+		if !finite(p.V) {
+			continue
+		}
+		col := width - 1
+		if span > 0 {
+			col = int(float64(p.T.Sub(start)) / float64(span) * float64(width-1))
+		}
+		if col < 0 || col >= width {
+			continue
+		}
+		sums[col] += p.V
+		counts[col]++
+	}
+	lo, hi, seen := 0.0, 0.0, false
+	for i := range sums {
+		if counts[i] == 0 {
+			continue
+		}
+		v := sums[i] / float64(counts[i])
+		sums[i] = v
+		if !seen || v < lo {
+			lo = v
+		}
+		if !seen || v > hi {
+			hi = v
+		}
+		seen = true
+	}
+	var b strings.Builder
+	for i := range sums {
+		switch {
+		case counts[i] == 0:
+			b.WriteRune(' ')
+		case hi == lo:
+			b.WriteRune(sparkTicks[0])
+		default:
+			idx := int((sums[i] - lo) / (hi - lo) * float64(len(sparkTicks)-1))
+			b.WriteRune(sparkTicks[idx])
+		}
+	}
+	return b.String()
+}
+
+// fmtNum is a compact number for the summaries: 4 significant digits.
+func fmtNum(v float64) string { return strconv.FormatFloat(v, 'g', 4, 64) }
+
+// formatRange renders one line per series: sparkline, min/max/last, labels.
+func formatRange(series []rangeSeries, start, end time.Time, width int) string {
+	if len(series) == 0 {
+		return "(no series)\n"
+	}
+	var b strings.Builder
+	for _, s := range series {
+		lo, hi, last, ok := seriesStats(s.Points)
+		if !ok {
+			fmt.Fprintf(&b, "%s  (no finite samples)  %s\n", strings.Repeat(" ", width), s.Labels)
+			continue
+		}
+		fmt.Fprintf(&b, "%s  min %-9s max %-9s last %-9s %s\n",
+			sparkline(s.Points, start, end, width), fmtNum(lo), fmtNum(hi), fmtNum(last), s.Labels)
+	}
+	return b.String()
+}
+
+// writeRangeCSV writes long-format CSV (series,timestamp,value), one row per
+// sample, timestamps RFC 3339 UTC — the shape spreadsheets and pandas pivot.
+func writeRangeCSV(w io.Writer, series []rangeSeries) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"series", "timestamp", "value"}); err != nil {
+		return err
+	}
+	for _, s := range series {
+		for _, p := range s.Points {
+			if err := cw.Write([]string{s.Labels, p.T.UTC().Format(time.RFC3339), strconv.FormatFloat(p.V, 'g', -1, 64)}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// parsePromDuration accepts Go durations plus Prometheus' d and w units
+// ("7d", "2w"), which are what people type for a range.
+func parsePromDuration(s string) (time.Duration, error) {
+	for unit, mult := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, unit) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(s, unit), 64)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad duration %q", s)
+			}
+			return time.Duration(n * float64(mult)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("bad duration %q: want e.g. 30m, 6h, 7d", s)
+	}
+	return d, nil
+}
+
+// defaultStep gives about two samples per sparkline column, never finer than
+// the 15s scrape interval.
+func defaultStep(rng time.Duration) time.Duration {
+	step := (rng / (2 * sparkWidth)).Round(time.Second)
+	if step < 15*time.Second {
+		step = 15 * time.Second
+	}
+	return step
+}
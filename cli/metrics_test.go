@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const matrixBody = `{"status":"success","data":{"resultType":"matrix","result":[
+ {"metric":{"__name__":"node_load1","instance":"k8s-node1"},"values":[[1760000000,"1"],[1760000060,"3"],[1760000120,"2"]]},
+ {"metric":{"job":"x"},"values":[[1760000000,"NaN"],[1760000060.5,"+Inf"]]}
+]}}`
+
+func TestParseMatrix(t *testing.T) {
+	s, err := parseMatrix([]byte(matrixBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 2 || s[0].Labels != "node_load1{instance=k8s-node1}" || len(s[0].Points) != 3 {
+		t.Fatalf("series = %+v", s)
+	}
+	if s[0].Points[1].T.Unix() != 1760000060 || s[0].Points[1].V != 3 {
+		t.Errorf("point = %+v", s[0].Points[1])
+	}
+	if !math.IsNaN(s[1].Points[0].V) || !math.IsInf(s[1].Points[1].V, 1) || s[1].Points[1].T.Nanosecond() != 5e8 {
+		t.Errorf("specials/fractional ts = %+v", s[1].Points)
+	}
+	if _, err := parseMatrix([]byte(`{"status":"error","error":"parse error at char 3"}`)); err == nil || !strings.Contains(err.Error(), "parse error") {
+		t.Errorf("error body: %v", err)
+	}
+}
+
+func TestSeriesStats(t *testing.T) {
+	pts := []rangePoint{{V: 2}, {V: math.NaN()}, {V: -1}, {V: 5}, {V: 3}, {V: math.Inf(1)}}
+	lo, hi, last, ok := seriesStats(pts)
+	if !ok || lo != -1 || hi != 5 || last != 3 {
+		t.Errorf("stats = %v %v %v %v", lo, hi, last, ok)
+	}
+	if _, _, _, ok := seriesStats([]rangePoint{{V: math.NaN()}}); ok {
+		t.Error("NaN-only series reported stats")
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	start := time.Unix(0, 0)
+	at := func(sec int64, v float64) rangePoint { return rangePoint{T: time.Unix(sec, 0), V: v} }
+	// 8 evenly spaced points over 8 columns: one per column, rising
+	var pts []rangePoint
+	for i := int64(0); i < 8; i++ {
+		pts = append(pts, at(i, float64(i)))
+	}
+	if got := sparkline(pts, start, time.Unix(7, 0), 8); got != "▁▂▃▄▅▆▇█" {
+		t.Errorf("ramp = %q", got)
+	}
+	// a gap stays blank instead of being squeezed out
+	gap := []rangePoint{at(0, 1), at(1, 2), at(6, 1), at(7, 2)}
+	if got := sparkline(gap, start, time.Unix(7, 0), 8); got != "▁█    ▁█" {
+		t.Errorf("gap = %q", got)
+	}
+	// flat
+	if got := sparkline([]rangePoint{at(0, 4), at(7, 4)}, start, time.Unix(7, 0), 4); got != "▁  ▁" {
+		t.Errorf("flat = %q", got)
+	}
+	// more points than columns average into their column
+	dense := []rangePoint{at(0, 0), at(1, 10), at(2, 10), at(3, 10)}
+	if got := sparkline(dense, start, time.Unix(3, 0), 2); got != "▁█" {
+		t.Errorf("dense = %q", got)
+	}
+}
+
+func TestFormatRangeAndCSV(t *testing.T) {
+	s, _ := parseMatrix([]byte(matrixBody))
+	out := formatRange(s, time.Unix(1760000000, 0), time.Unix(1760000120, 0), 3)
+	if !strings.Contains(out, "▁█▄  min 1         max 3         last 2         node_load1{instance=k8s-node1}") {
+		t.Errorf("formatRange:\n%s", out)
+	}
+	if !strings.Contains(out, "(no finite samples)  {job=x}") {
+		t.Errorf("NaN series line:\n%s", out)
+	}
+	var buf bytes.Buffer
+	if err := writeRangeCSV(&buf, s[:1]); err != nil {
+		t.Fatal(err)
+	}
+	want := "series,timestamp,value\n" +
+		"node_load1{instance=k8s-node1},2025-10-09T08:53:20Z,1\n" +
+		"node_load1{instance=k8s-node1},2025-10-09T08:54:20Z,3\n" +
+		"node_load1{instance=k8s-node1},2025-10-09T08:55:20Z,2\n"
+	if buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestParsePromDuration(t *testing.T) {
+	for in, want := range map[string]time.Duration{"6h": 6 * time.Hour, "1m": time.Minute, "7d": 7 * 24 * time.Hour, "2w": 14 * 24 * time.Hour, "1.5d": 36 * time.Hour} {
+		if got, err := parsePromDuration(in); err != nil || got != want {
+			t.Errorf("parsePromDuration(%q) = %v, %v", in, got, err)
+		}
+	}
+	for _, bad := range []string{"", "x", "0s", "-1h", "d"} {
+		if _, err := parsePromDuration(bad); err == nil {
+			t.Errorf("parsePromDuration(%q) accepted", bad)
+		}
+	}
+	if got := defaultStep(6 * time.Hour); got != 3*time.Minute {
+		t.Errorf("defaultStep(6h) = %s", got)
+	}
+	if got := defaultStep(10 * time.Minute); got != 15*time.Second {
+		t.Errorf("defaultStep(10m) = %s", got)
+	}
+}
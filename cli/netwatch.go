@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// legStats accumulates one `net watch` leg's probes: latency percentiles over
+// a rolling window, status counts, and flaps over the whole run.
+type legStats struct {
+	window  int
+	lat     []time.Duration // latencies of the last `window` answered probes
+	codes   map[string]int  // "200", "502", "ERR" → count
+	total   int
+	errors  int
+	flaps   int // healthy↔failing transitions
+	hasLast bool
+	lastOK  bool
+}
+
+func newLegStats(window int) *legStats {
+	return &legStats{window: window, codes: map[string]int{}}
+}
+
+// add records one probe. Health is probeHealthy (shared with ship's smoke), so
+// an auth-gated app answering 401 is not counted as an error.
+func (s *legStats) add(code int, d time.Duration, err error) {
+	s.total++
+	ok := err == nil && probeHealthy(code)
+	if err != nil {
+		s.codes["ERR"]++
+	} else {
+		s.codes[strconv.Itoa(code)]++
+		s.lat = append(s.lat, d)
+		if len(s.lat) > s.window {
+			s.lat = s.lat[len(s.lat)-s.window:]
+		}
+	}
+	if !ok {
+		s.errors++
+	}
+	if s.hasLast && ok != s.lastOK {
+		s.flaps++
+	}
+	s.hasLast, s.lastOK = true, ok
+}
+
+// errorRate is the fraction of all probes so far that failed.
+func (s *legStats) errorRate() float64 {
+	if s.total == 0 {
+		return 0
+	}
+	return float64(s.errors) / float64(s.total)
+}
+
+// percentile is the nearest-rank q-quantile of the window's latencies.
+func (s *legStats) percentile(q float64) time.Duration {
+	if len(s.lat) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), s.lat...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// codeSummary renders the status counts as "200×41 502×2 ERR×1", numeric
+// codes ascending and ERR last.
+func (s *legStats) codeSummary() string {
+	var keys []string
+	for k := range s.codes {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == "ERR") != (keys[j] == "ERR") {
+			return keys[j] == "ERR"
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s×%d", k, s.codes[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+// summary is the rolling part of each tick line and the final report.
+func (s *legStats) summary() string {
+	return fmt.Sprintf("p50 %dms p95 %dms | %s | flaps %d | err %.1f%% (%d/%d)",
+		s.percentile(0.50).Milliseconds(), s.percentile(0.95).Milliseconds(),
+		s.codeSummary(), s.flaps, 100*s.errorRate(), s.errors, s.total)
+}
+
+// parsePercent reads "5", "5%" or "2.5%" as a fraction (0.05, 0.025).
+func parsePercent(v string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "%"), 64)
+	if err != nil || f < 0 || f > 100 {
+		return 0, fmt.Errorf("bad percentage %q: want 0-100, e.g. 5 or 2.5%%", v)
+	}
+	return f / 100, nil
+}
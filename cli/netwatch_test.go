@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLegStats(t *testing.T) {
+	s := newLegStats(4)
+	ms := time.Millisecond
+	s.add(200, 10*ms, nil)
+	s.add(200, 20*ms, nil)
+	s.add(502, 30*ms, nil)             // fail: flap 1
+	s.add(0, 0, errors.New("timeout")) // still failing, no latency sample
+	s.add(401, 40*ms, nil)             // auth-gated answer is healthy: flap 2
+	s.add(200, 50*ms, nil)             // window (4) now holds 20,30,40,50
+
+	if s.total != 6 || s.errors != 2 || s.flaps != 2 {
+		t.Errorf("total/errors/flaps = %d/%d/%d, want 6/2/2", s.total, s.errors, s.flaps)
+	}
+	if got := s.codeSummary(); got != "200×3 401×1 502×1 ERR×1" {
+		t.Errorf("codeSummary = %q", got)
+	}
+	if p50, p95 := s.percentile(0.5), s.percentile(0.95); p50 != 30*ms || p95 != 50*ms {
+		t.Errorf("p50/p95 = %s/%s, want 30ms/50ms (10ms fell out of the window)", p50, p95)
+	}
+	if r := s.errorRate(); r < 0.333 || r > 0.334 {
+		t.Errorf("errorRate = %v", r)
+	}
+	if got := newLegStats(3).summary(); got != "p50 0ms p95 0ms |  | flaps 0 | err 0.0% (0/0)" {
+		t.Errorf("empty summary = %q", got)
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	for in, want := range map[string]float64{"5": 0.05, "5%": 0.05, "2.5%": 0.025, "0": 0} {
+		if got, err := parsePercent(in); err != nil || got != want {
+			t.Errorf("parsePercent(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "x", "-1", "101%"} {
+		if _, err := parsePercent(bad); err == nil {
+			t.Errorf("parsePercent(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// policyDefaultMinFlows is the flow count below which an observed peer is
+// drafted commented out: a handful of flows is as likely a one-off probe or a
+// debugging session as a real dependency.
+const policyDefaultMinFlows = 10
+
+// kubeDNSClusterIP is the kube-dns Service IP (service CIDR 10.96.0.0/12, DNS
+// always .10). Egress to it needs an ipBlock rule of its own — see the
+// whisker_allow_dns_clusterip policy in stacks/calico.
+const kubeDNSClusterIP = "10.96.0.10/32"
+
+// policyPeer is one namespace the drafted namespace talks to (or is talked
+// to by), with the evidence for it.
+type policyPeer struct {
+	NS     string `json:"ns"`
+	Flows  int64  `json:"flows"`
+	Recent bool   `json:"recent"` // seen since --new-since (always true without it)
+}
+
+// policyRow is one allowed edge touching the namespace, as the query returns it.
+type policyRow struct {
+	Src    string `json:"src"`
+	Dst    string `json:"dst"`
+	Flows  int64  `json:"flow_count"`
+	Recent bool   `json:"recent"`
+}
+
+// policyDraft is the observed ingress and egress peers of NS.
+type policyDraft struct {
+	NS      string
+	Ingress []policyPeer
+	Egress  []policyPeer
+}
+
+// policyQuery selects the allowed edges touching ns, flagging each as recent
+// when last_seen is within newSince (every edge is recent when newSince is "").
+func policyQuery(ns, newSince string) (string, error) {
+	if err := validateNS(ns); err != nil {
+		return "", err
+	}
+	recent := "true"
+	if newSince != "" {
+		c, ok := sinceCond("last_seen", newSince)
+		if !ok {
+			return "", fmt.Errorf("--new-since must be a duration (e.g. 24h, 7d, 30m) or a date (YYYY-MM-DD): %q", newSince)
+		}
+		recent = "(" + c + ")"
+	}
+	n := sqlStr(ns)
+	return "SELECT coalesce(json_agg(row_to_json(t)), '[]') FROM (" +
+		"SELECT src_ns AS src, dst_ns AS dst, flow_count, " + recent + " AS recent FROM edge " +
+		"WHERE action = 'allow' AND (src_ns = " + n + " OR dst_ns = " + n + ")) t", nil
+}
+
+// buildPolicyDraft splits rows into ingress and egress peers of ns, sorted.
+func buildPolicyDraft(ns, out string) (policyDraft, error) {
+	var rows []policyRow
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &rows); err != nil {
+		return policyDraft{}, fmt.Errorf("cannot parse edges: %w", err)
+	}
+	d := policyDraft{NS: ns}
+	for _, r := range rows {
+		switch {
+		case r.Src == ns && r.Dst != ns:
+			d.Egress = append(d.Egress, policyPeer{r.Dst, r.Flows, r.Recent})
+		case r.Dst == ns && r.Src != ns:
+			d.Ingress = append(d.Ingress, policyPeer{r.Src, r.Flows, r.Recent})
+		}
+	}
+	for _, l := range [][]policyPeer{d.Ingress, d.Egress} {
+		sort.Slice(l, func(i, j int) bool { return l[i].NS < l[j].NS })
+	}
+	return d, nil
+}
+
+// doubt is why a peer is drafted commented out, or "" if it is allowed.
+func (p policyPeer) doubt(minFlows int) string {
+	switch {
+	case !p.Recent:
+		return fmt.Sprintf("review: %d flows, none since --new-since", p.Flows)
+	case p.Flows < int64(minFlows):
+		return fmt.Sprintf("review: only %d flows (< %d)", p.Flows, minFlows)
+	}
+	return ""
+}
+
+func confidentPeers(list []policyPeer, minFlows int) int {
+	n := 0
+	for _, p := range list {
+		if p.doubt(minFlows) == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// policyHeader is the comment both renderers open with (# is a comment in
+// YAML and HCL alike).
+func policyHeader(d policyDraft, minFlows int) string {
+	lines := []string{
+		fmt.Sprintf("DRAFT NetworkPolicy for %s, from the goldmane_edges trail (ADR-0014).", d.NS),
+		"Allows exactly the observed namespace peers (action=allow); commented-out",
+		fmt.Sprintf("peers were seen fewer than %d times or not recently: review them.", minFlows),
+		"The trail has no ports, so rules are namespace-wide; narrow them if you can.",
+		"External (internet) egress is NOT in the trail: add those destinations",
+		"from the Wave-1 observation snapshot before applying, or they break.",
+	}
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString("# " + l + "\n")
+	}
+	return b.String()
+}
+
+// renderPolicyYAML renders a networking.k8s.io/v1 NetworkPolicy for every pod
+// in the namespace (Calico enforces these).
+func renderPolicyYAML(d policyDraft, minFlows int) string {
+	var b strings.Builder
+	b.WriteString(policyHeader(d, minFlows))
+	fmt.Fprintf(&b, `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: %s-observed
+  namespace: %s
+spec:
+  podSelector: {}
+  policyTypes:
+    - Ingress
+    - Egress
+`, d.NS, d.NS)
+	// A rule whose from/to list ends up empty allows everything, so when no
+	// peer is confident the whole rule is commented out, not just its peers.
+	peers := func(list []policyPeer, key string) {
+		rule := ""
+		if confidentPeers(list, minFlows) == 0 {
+			rule = "# "
+		}
+		fmt.Fprintf(&b, "    %s- %s:\n", rule, key)
+		for _, p := range list {
+			pfx, note := rule, fmt.Sprintf("%d flows", p.Flows)
+			if why := p.doubt(minFlows); why != "" {
+				pfx, note = "# ", why
+			}
+			fmt.Fprintf(&b, "        %s- namespaceSelector:  # %s\n", pfx, note)
+			fmt.Fprintf(&b, "        %s    matchLabels:\n", pfx)
+			fmt.Fprintf(&b, "        %s      kubernetes.io/metadata.name: %s\n", pfx, p.NS)
+		}
+	}
+	switch {
+	case len(d.Ingress) == 0:
+		b.WriteString("  ingress: []  # no observed ingress peers\n")
+	case confidentPeers(d.Ingress, minFlows) == 0:
+		b.WriteString("  ingress: []  # no confidently observed ingress peers; candidates:\n")
+		peers(d.Ingress, "from")
+	default:
+		b.WriteString("  ingress:\n")
+		peers(d.Ingress, "from")
+	}
+	b.WriteString(`  egress:
+    # DNS: the kube-dns pods and, separately, its ClusterIP (a podSelector-only
+    # rule does not cover ClusterIP DNS under Calico; see stacks/calico).
+    - to:
+        - namespaceSelector:
+            matchLabels:
+              kubernetes.io/metadata.name: kube-system
+          podSelector:
+            matchLabels:
+              k8s-app: kube-dns
+        - ipBlock:
+            cidr: ` + kubeDNSClusterIP + `
+      ports:
+        - protocol: UDP
+          port: 53
+        - protocol: TCP
+          port: 53
+`)
+	if len(d.Egress) > 0 {
+		peers(d.Egress, "to")
+	}
+	return b.String()
+}
+
+// renderPolicyTF renders the same policy as a kubernetes_network_policy_v1
+// resource in the style of the stacks.
+func renderPolicyTF(d policyDraft, minFlows int) string {
+	id := strings.NewReplacer("-", "_", ".", "_").Replace(d.NS)
+	var b strings.Builder
+	b.WriteString(policyHeader(d, minFlows))
+	fmt.Fprintf(&b, `resource "kubernetes_network_policy_v1" "%s_observed" {
+  metadata {
+    name      = "%s-observed"
+    namespace = "%s"
+  }
+  spec {
+    pod_selector {}
+    policy_types = ["Ingress", "Egress"]
+`, id, d.NS, d.NS)
+	// As in the YAML: a rule with no from/to allows everything, so it is
+	// commented out whole when none of its peers is confident.
+	peers := func(list []policyPeer, block, dir string) {
+		if len(list) == 0 {
+			return
+		}
+		rule := ""
+		if confidentPeers(list, minFlows) == 0 {
+			rule = "# "
+		}
+		fmt.Fprintf(&b, "    %s%s {\n", rule, block)
+		for _, p := range list {
+			pfx, note := rule, fmt.Sprintf("%d flows", p.Flows)
+			if why := p.doubt(minFlows); why != "" {
+				pfx, note = "# ", why
+			}
+			fmt.Fprintf(&b, "      %s%s {  # %s\n", pfx, dir, note)
+			fmt.Fprintf(&b, "      %s  namespace_selector { match_labels = { \"kubernetes.io/metadata.name\" = \"%s\" } }\n", pfx, p.NS)
+			fmt.Fprintf(&b, "      %s}\n", pfx)
+		}
+		fmt.Fprintf(&b, "    %s}\n", rule)
+	}
+	peers(d.Ingress, "ingress", "from")
+	b.WriteString(`    # DNS: the kube-dns pods and, separately, its ClusterIP (see
+    # whisker_allow_dns_clusterip in stacks/calico).
+    egress {
+      to {
+        namespace_selector { match_labels = { "kubernetes.io/metadata.name" = "kube-system" } }
+        pod_selector { match_labels = { "k8s-app" = "kube-dns" } }
+      }
+      to {
+        ip_block {
+          cidr = "` + kubeDNSClusterIP + `"
+        }
+      }
+      ports {
+        port     = "53"
+        protocol = "UDP"
+      }
+      ports {
+        port     = "53"
+        protocol = "TCP"
+      }
+    }
+`)
+	peers(d.Egress, "egress", "to")
+	b.WriteString("  }\n}\n")
+	return b.String()
+}
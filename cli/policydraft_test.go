@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const policyRowsFixture = `[
+ {"src":"traefik","dst":"immich","flow_count":5000,"recent":true},
+ {"src":"monitoring","dst":"immich","flow_count":4,"recent":true},
+ {"src":"immich","dst":"dbaas","flow_count":90000,"recent":true},
+ {"src":"immich","dst":"redis","flow_count":700,"recent":false},
+ {"src":"immich","dst":"immich","flow_count":1,"recent":true}
+]`
+
+func TestPolicyQuery(t *testing.T) {
+	q, err := policyQuery("immich", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"action = 'allow'", "src_ns = 'immich' OR dst_ns = 'immich'", "true AS recent"} {
+		if !strings.Contains(q, want) {
+			t.Errorf("query %q missing %q", q, want)
+		}
+	}
+	q, _ = policyQuery("immich", "30d")
+	if !strings.Contains(q, "(last_seen >= now() - interval '30 days') AS recent") {
+		t.Errorf("--new-since query: %q", q)
+	}
+	if _, err := policyQuery("im'mich", ""); err == nil {
+		t.Error("bad namespace accepted")
+	}
+	if _, err := policyQuery("immich", "yesterday"); err == nil {
+		t.Error("bad --new-since accepted")
+	}
+}
+
+func TestBuildPolicyDraft(t *testing.T) {
+	d, err := buildPolicyDraft("immich", policyRowsFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Ingress) != 2 || d.Ingress[0].NS != "monitoring" || d.Ingress[1].NS != "traefik" {
+		t.Errorf("Ingress = %+v", d.Ingress)
+	}
+	if len(d.Egress) != 2 || d.Egress[0].NS != "dbaas" || d.Egress[1].NS != "redis" {
+		t.Errorf("Egress = %+v (self-edge must be dropped)", d.Egress)
+	}
+}
+
+func TestRenderPolicyYAML(t *testing.T) {
+	d, _ := buildPolicyDraft("immich", policyRowsFixture)
+	out := renderPolicyYAML(d, 10)
+	for _, want := range []string{
+		"  name: immich-observed\n  namespace: immich\n",
+		"  ingress:\n    - from:\n",
+		"        # - namespaceSelector:  # review: only 4 flows (< 10)\n        #     matchLabels:\n        #       kubernetes.io/metadata.name: monitoring\n",
+		"        - namespaceSelector:  # 5000 flows\n            matchLabels:\n              kubernetes.io/metadata.name: traefik\n",
+		"cidr: 10.96.0.10/32",
+		"    - to:\n        - namespaceSelector:  # 90000 flows\n",
+		"        # - namespaceSelector:  # review: 700 flows, none since --new-since\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("YAML missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderPolicyNoConfidentPeers(t *testing.T) {
+	// A rule with an empty from/to allows everything, so a list with no
+	// confident peer must not leave an uncommented rule behind.
+	d := policyDraft{NS: "x", Ingress: []policyPeer{{"a", 2, true}}, Egress: []policyPeer{{"b", 1, true}}}
+	yaml := renderPolicyYAML(d, 10)
+	if !strings.Contains(yaml, "  ingress: []  # no confidently observed ingress peers; candidates:\n    # - from:\n") {
+		t.Errorf("YAML ingress:\n%s", yaml)
+	}
+	if strings.Contains(yaml, "\n    - to:\n        # ") {
+		t.Errorf("uncommented egress rule with only commented peers:\n%s", yaml)
+	}
+	tf := renderPolicyTF(d, 10)
+	if !strings.Contains(tf, "    # ingress {\n      # from {") || !strings.Contains(tf, "    # egress {\n") {
+		t.Errorf("TF:\n%s", tf)
+	}
+}
+
+func TestRenderPolicyTF(t *testing.T) {
+	d, _ := buildPolicyDraft("immich", policyRowsFixture)
+	out := renderPolicyTF(d, 10)
+	for _, want := range []string{
+		`resource "kubernetes_network_policy_v1" "immich_observed" {`,
+		`policy_types = ["Ingress", "Egress"]`,
+		"      from {  # 5000 flows\n        namespace_selector { match_labels = { \"kubernetes.io/metadata.name\" = \"traefik\" } }\n      }\n",
+		"      # from {  # review: only 4 flows (< 10)\n",
+		`cidr = "10.96.0.10/32"`,
+		"      # to {  # review: 700 flows, none since --new-since\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("TF missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "{") != strings.Count(out, "}") {
+		t.Errorf("unbalanced braces:\n%s", out)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"net"
 	"net/http"
 	"net/url"
-	"os/exec"
 	"strings"
 	"time"
 )
@@ -34,6 +33,13 @@ func clientDialingIP(ip string, timeout time.Duration) *http.Client {
 	return &http.Client{Timeout: timeout, Transport: tr}
 }
 
+// probeHealthy reports whether an HTTP status means "the service answers".
+// Auth-gated apps legitimately answer 401/403 (or a 3xx to the login page)
+// to an anonymous probe; anything 4xx-else or 5xx is a broken route or app.
+func probeHealthy(code int) bool {
+	return (code >= 200 && code < 400) || code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
 // probeURL issues a GET and returns status code + elapsed time.
 func probeURL(c *http.Client, rawurl string) (int, time.Duration, error) {
 	start := time.Now()
@@ -63,14 +69,3 @@ func lbGetBody(host, path string, q url.Values) ([]byte, error) {
 	}
 	return body, nil
 }
-
-// dig runs `dig +short` against a resolver, optionally for a record type.
-func dig(name, server, rrtype string) (string, error) {
-	args := []string{"+short", "+time=3", "+tries=1"}
-	if rrtype != "" {
-		args = append(args, rrtype)
-	}
-	args = append(args, name, "@"+server)
-	out, err := exec.Command("dig", args...).Output()
-	return strings.TrimSpace(string(out)), err
-}
@@ -47,3 +47,14 @@ func TestHostOnly(t *testing.T) {
 		t.Errorf("hostOnly = %q", got)
 	}
 }
+
+func TestProbeHealthy(t *testing.T) {
+	for code, want := range map[int]bool{
+		200: true, 204: true, 302: true, 401: true, 403: true,
+		0: false, 404: false, 429: false, 500: false, 502: false, 503: false,
+	} {
+		if got := probeHealthy(code); got != want {
+			t.Errorf("probeHealthy(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -63,38 +69,109 @@ func newWPClient() (*wpClient, error) {
 // getJSON GETs path into v, retrying the transient empty/5xx responses the
 // Woodpecker API intermittently returns under load.
 func (c *wpClient) getJSON(path string, v interface{}) error {
+	return c.doJSON("GET", path, nil, v)
+}
+
+// sendJSON is the write-side counterpart of getJSON: body (if any) is sent as
+// JSON and the response decoded into v (if non-nil). A write is retried only
+// when the connection could not be dialled: after a 5xx or an empty 2xx the
+// server may already have acted, and a replay would start a second pipeline.
+func (c *wpClient) sendJSON(method, path string, body, v interface{}) error {
+	return c.doJSON(method, path, body, v)
+}
+
+// doJSON is the shared request loop behind getJSON/sendJSON.
+func (c *wpClient) doJSON(method, path string, body, v interface{}) error {
+	var payload []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = b
+	}
 	var lastErr error
 	for attempt := 0; attempt < 5; attempt++ {
 		if attempt > 0 {
 			time.Sleep(2 * time.Second)
 		}
-		req, _ := http.NewRequest("GET", c.base+path, nil)
+		req, _ := http.NewRequest(method, c.base+path, bytes.NewReader(payload))
 		req.Header.Set("Authorization", "Bearer "+c.token)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
 		resp, err := c.http.Do(req)
 		if err != nil {
+			var op *net.OpError
+			if method != "GET" && !(errors.As(err, &op) && op.Op == "dial") {
+				return fmt.Errorf("woodpecker %s %s: %w (not retried: the request may have been received)", method, path, err)
+			}
 			lastErr = err
 			continue
 		}
-		body, _ := io.ReadAll(resp.Body)
+		respBody, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		if resp.StatusCode >= 500 || len(strings.TrimSpace(string(body))) == 0 {
-			lastErr = fmt.Errorf("woodpecker GET %s -> %d (empty/5xx, retrying)", path, resp.StatusCode)
+		empty := len(strings.TrimSpace(string(respBody))) == 0
+		if resp.StatusCode >= 500 || (empty && v != nil && resp.StatusCode < 300) {
+			if method != "GET" {
+				return fmt.Errorf("woodpecker %s %s -> %d with an empty or error body; not retried, check the UI before re-running", method, path, resp.StatusCode)
+			}
+			lastErr = fmt.Errorf("woodpecker %s %s -> %d (empty/5xx, retrying)", method, path, resp.StatusCode)
 			continue
 		}
 		if resp.StatusCode >= 300 {
-			return fmt.Errorf("woodpecker GET %s -> %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+			return fmt.Errorf("woodpecker %s %s -> %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(respBody)))
 		}
-		return json.Unmarshal(body, v)
+		if v == nil || empty {
+			return nil
+		}
+		return json.Unmarshal(respBody, v)
 	}
 	return lastErr
 }
 
 type wpPipeline struct {
-	Number  int    `json:"number"`
-	Status  string `json:"status"`
-	Event   string `json:"event"`
-	Commit  string `json:"commit"`
-	Message string `json:"message"`
+	Number   int    `json:"number"`
+	Status   string `json:"status"`
+	Event    string `json:"event"`
+	Commit   string `json:"commit"`
+	Message  string `json:"message"`
+	Branch   string `json:"branch"`
+	Created  int64  `json:"created"`
+	Finished int64  `json:"finished"`
+}
+
+// wpStep is one step (a container run) inside a workflow. PID is the
+// pipeline-scoped step number the UI shows; ID keys the log endpoint.
+type wpStep struct {
+	ID       int    `json:"id"`
+	PID      int    `json:"pid"`
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error"`
+}
+
+// wpWorkflow is one workflow (a .woodpecker/*.yaml file) and its steps.
+type wpWorkflow struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	State string   `json:"state"`
+	Steps []wpStep `json:"children"`
+}
+
+// wpPipelineDetail is the single-pipeline view, which (unlike the list
+// endpoint) carries the workflow/step tree.
+type wpPipelineDetail struct {
+	wpPipeline
+	Workflows []wpWorkflow `json:"workflows"`
+}
+
+// wpLogEntry is one log line as the log endpoint returns it; Data is the raw
+// line bytes, base64-encoded by the JSON []byte convention.
+type wpLogEntry struct {
+	Line int    `json:"line"`
+	Data string `json:"data"`
 }
 
 func (c *wpClient) recentPipelines(repoID, n int) ([]wpPipeline, error) {
@@ -124,21 +201,195 @@ func (c *wpClient) findPipeline(repoID int, commit string) (wpPipeline, error) {
 	return wpPipeline{}, fmt.Errorf("no pipeline for commit %s in the last %d", commit[:min(8, len(commit))], len(ps))
 }
 
-func (c *wpClient) repoID() (int, error) {
-	owner, repo, err := repoOwnerName()
-	if err != nil {
-		return 0, err
+// pipelineDetail fetches one pipeline by number, including its workflows+steps.
+func (c *wpClient) pipelineDetail(repoID, number int) (wpPipelineDetail, error) {
+	var p wpPipelineDetail
+	err := c.getJSON(fmt.Sprintf("/api/repos/%d/pipelines/%d", repoID, number), &p)
+	return p, err
+}
+
+// stepLogs fetches a step's full log as plain lines, in order.
+func (c *wpClient) stepLogs(repoID, number, stepID int) ([]string, error) {
+	var entries []wpLogEntry
+	if err := c.getJSON(fmt.Sprintf("/api/repos/%d/logs/%d/%d", repoID, number, stepID), &entries); err != nil {
+		return nil, err
+	}
+	return decodeLogEntries(entries), nil
+}
+
+// decodeLogEntries turns log entries into text lines ordered by line number.
+// An entry that is not valid base64 is kept verbatim rather than dropped, so a
+// server that ever sends plain text still shows something.
+func decodeLogEntries(entries []wpLogEntry) []string {
+	sorted := make([]wpLogEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Line < sorted[j].Line })
+	out := make([]string, 0, len(sorted))
+	for _, e := range sorted {
+		line := e.Data
+		if b, err := base64.StdEncoding.DecodeString(e.Data); err == nil {
+			line = string(b)
+		}
+		out = append(out, strings.TrimRight(line, "\r\n"))
 	}
-	var r struct {
-		ID int `json:"id"`
+	return out
+}
+
+// failedSteps returns every step that ended in a failure state, in workflow
+// order. Skipped steps downstream of the failure are not failures themselves.
+func failedSteps(wfs []wpWorkflow) []wpStep {
+	var out []wpStep
+	for _, wf := range wfs {
+		for _, s := range wf.Steps {
+			if isFailureStatus(s.State) {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// findStep resolves a step by name or by its pipeline-scoped number (PID).
+func findStep(wfs []wpWorkflow, ref string) (wpStep, bool) {
+	pid, numErr := strconv.Atoi(ref)
+	for _, wf := range wfs {
+		for _, s := range wf.Steps {
+			if s.Name == ref || (numErr == nil && s.PID == pid) {
+				return s, true
+			}
+		}
+	}
+	return wpStep{}, false
+}
+
+// tailLines returns the last n lines (all of them when n <= 0).
+func tailLines(lines []string, n int) []string {
+	if n <= 0 || len(lines) <= n {
+		return lines
 	}
+	return lines[len(lines)-n:]
+}
+
+// restartPipeline re-runs pipeline number; params become query parameters,
+// which Woodpecker exposes to the new run as pipeline variables.
+func (c *wpClient) restartPipeline(repoID, number int, params url.Values) (wpPipeline, error) {
+	path := fmt.Sprintf("/api/repos/%d/pipelines/%d", repoID, number)
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	var p wpPipeline
+	err := c.sendJSON("POST", path, nil, &p)
+	return p, err
+}
+
+// cancelPipeline stops a pending/running pipeline.
+func (c *wpClient) cancelPipeline(repoID, number int) error {
+	return c.sendJSON("POST", fmt.Sprintf("/api/repos/%d/pipelines/%d/cancel", repoID, number), nil, nil)
+}
+
+// wpManualReq is the body of a manual (event=manual) pipeline trigger.
+type wpManualReq struct {
+	Branch    string            `json:"branch"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// triggerManual starts an event=manual pipeline on branch.
+func (c *wpClient) triggerManual(repoID int, branch string, vars map[string]string) (wpPipeline, error) {
+	var p wpPipeline
+	err := c.sendJSON("POST", fmt.Sprintf("/api/repos/%d/pipelines", repoID), wpManualReq{Branch: branch, Variables: vars}, &p)
+	return p, err
+}
+
+type wpCron struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Branch string `json:"branch"`
+}
+
+// runCron fires the repo cron named name now (the UI's "run now" button).
+func (c *wpClient) runCron(repoID int, name string) (wpPipeline, error) {
+	var crons []wpCron
+	if err := c.getJSON(fmt.Sprintf("/api/repos/%d/cron", repoID), &crons); err != nil {
+		return wpPipeline{}, err
+	}
+	for _, cr := range crons {
+		if cr.Name == name || strconv.Itoa(cr.ID) == name {
+			var p wpPipeline
+			err := c.sendJSON("POST", fmt.Sprintf("/api/repos/%d/cron/%d", repoID, cr.ID), nil, &p)
+			return p, err
+		}
+	}
+	var names []string
+	for _, cr := range crons {
+		names = append(names, cr.Name)
+	}
+	return wpPipeline{}, fmt.Errorf("no cron %q in this repo (have: %s)", name, strings.Join(names, ", "))
+}
+
+// repoID resolves slug ("owner/name") to its Woodpecker repo id. An empty slug
+// means the repo behind the cwd's preferred git remote.
+func (c *wpClient) repoID(slug string) (int, error) {
+	r, err := c.lookupRepo(slug)
+	return r.ID, err
+}
+
+// wpRepoInfo is the part of the repo lookup the verbs need.
+type wpRepoInfo struct {
+	ID            int    `json:"id"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// lookupRepo is repoID plus the repo's default branch.
+func (c *wpClient) lookupRepo(slug string) (wpRepoInfo, error) {
+	var owner, repo string
+	var err error
+	if slug != "" {
+		owner, repo, err = parseRepoSlug(slug)
+	} else {
+		owner, repo, err = repoOwnerName()
+	}
+	if err != nil {
+		return wpRepoInfo{}, err
+	}
+	var r wpRepoInfo
 	if err := c.getJSON("/api/repos/lookup/"+owner+"/"+repo, &r); err != nil {
-		return 0, err
+		return wpRepoInfo{}, err
 	}
 	if r.ID == 0 {
-		return 0, fmt.Errorf("repo %s/%s not registered in woodpecker", owner, repo)
+		return wpRepoInfo{}, fmt.Errorf("repo %s/%s not registered in woodpecker", owner, repo)
+	}
+	return r, nil
+}
+
+// parseRepoSlug splits an explicit --repo owner/name.
+func parseRepoSlug(slug string) (string, string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimSpace(slug), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("--repo wants owner/name, got %q", slug)
+	}
+	return parts[0], parts[1], nil
+}
+
+// wpRepo is one entry of the token owner's repo list.
+type wpRepo struct {
+	ID       int    `json:"id"`
+	FullName string `json:"full_name"`
+	Active   bool   `json:"active"`
+}
+
+// userRepos lists every repo the token can see that is active in Woodpecker.
+func (c *wpClient) userRepos() ([]wpRepo, error) {
+	var all []wpRepo
+	if err := c.getJSON("/api/user/repos", &all); err != nil {
+		return nil, err
+	}
+	var out []wpRepo
+	for _, r := range all {
+		if r.Active {
+			out = append(out, r)
+		}
 	}
-	return r.ID, nil
+	return out, nil
 }
 
 // repoOwnerName derives <owner>/<repo> from the cwd git remote.
@@ -146,7 +397,7 @@ func repoOwnerName() (string, string, error) {
 	cwd, _ := os.Getwd()
 	root, err := gitRepoRoot(cwd)
 	if err != nil {
-		return "", "", fmt.Errorf("not in a git repository: %w", err)
+		return "", "", fmt.Errorf("not in a git repository (or pass --repo owner/name): %w", err)
 	}
 	remote := preferRemote(remotesOrEmpty(root))
 	url, err := gitOutput(root, "remote", "get-url", remote)
@@ -1,6 +1,15 @@
 package main
 
-import "testing"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestParseOwnerRepo(t *testing.T) {
 	cases := []struct{ in, owner, repo string }{
@@ -38,3 +47,248 @@ func TestStatusClassification(t *testing.T) {
 		t.Error("success must not classify as failure")
 	}
 }
+
+func TestDecodeLogEntriesOrdersAndDecodes(t *testing.T) {
+	enc := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	got := decodeLogEntries([]wpLogEntry{
+		{Line: 2, Data: enc("third\n")},
+		{Line: 0, Data: enc("first")},
+		{Line: 1, Data: "not base64!"},
+	})
+	want := []string{"first", "not base64!", "third"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decodeLogEntries = %q, want %q", got, want)
+	}
+}
+
+func TestFailedStepsAndFindStep(t *testing.T) {
+	wfs := []wpWorkflow{
+		{Name: "build", Steps: []wpStep{{ID: 10, PID: 2, Name: "clone", State: "success"}, {ID: 11, PID: 3, Name: "test", State: "failure", ExitCode: 1}}},
+		{Name: "deploy", Steps: []wpStep{{ID: 20, PID: 5, Name: "apply", State: "skipped"}}},
+	}
+	failed := failedSteps(wfs)
+	if len(failed) != 1 || failed[0].Name != "test" || failed[0].ExitCode != 1 {
+		t.Fatalf("failedSteps = %+v, want just the failed test step", failed)
+	}
+	if s, ok := findStep(wfs, "apply"); !ok || s.ID != 20 {
+		t.Errorf("findStep by name = %+v, %v", s, ok)
+	}
+	if s, ok := findStep(wfs, "3"); !ok || s.Name != "test" {
+		t.Errorf("findStep by pid = %+v, %v", s, ok)
+	}
+	if _, ok := findStep(wfs, "nope"); ok {
+		t.Error("findStep should miss an unknown step")
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	lines := []string{"a", "b", "c"}
+	if got := tailLines(lines, 2); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("tailLines(2) = %q", got)
+	}
+	if got := tailLines(lines, 0); len(got) != 3 {
+		t.Errorf("tailLines(0) should keep everything, got %q", got)
+	}
+	if got := tailLines(lines, 10); len(got) != 3 {
+		t.Errorf("tailLines(10) = %q", got)
+	}
+}
+
+func TestPositionalsSkipping(t *testing.T) {
+	got := positionalsSkipping([]string{"--tail", "50", "12", "build"}, map[string]bool{"--tail": true})
+	if !reflect.DeepEqual(got, []string{"12", "build"}) {
+		t.Fatalf("positionalsSkipping = %q", got)
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	got, err := parseVars([]string{"A=1", "B=x=y", "EMPTY="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "1", "B": "x=y", "EMPTY": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseVars = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"novalue", "=v"} {
+		if _, err := parseVars([]string{bad}); err == nil {
+			t.Errorf("parseVars(%q) should fail", bad)
+		}
+	}
+	if got, err := parseVars(nil); got != nil || err != nil {
+		t.Errorf("parseVars(nil) = %v, %v", got, err)
+	}
+}
+
+func TestFlagValuesRepeatable(t *testing.T) {
+	got := flagValues([]string{"--var", "A=1", "12", "--var=B=2", "--var"}, "--var")
+	if !reflect.DeepEqual(got, []string{"A=1", "B=2"}) {
+		t.Fatalf("flagValues = %q", got)
+	}
+}
+
+func TestFailedWorkflowNames(t *testing.T) {
+	got := failedWorkflowNames([]wpWorkflow{{Name: "build", State: "success"}, {Name: "deploy", State: "failure"}, {Name: "lint", State: "killed"}})
+	if !reflect.DeepEqual(got, []string{"deploy", "lint"}) {
+		t.Fatalf("failedWorkflowNames = %q", got)
+	}
+}
+
+func TestCIWriteVerbsAreWriteTier(t *testing.T) {
+	tiers := map[string]Tier{}
+	for _, c := range ciCommands() {
+		tiers[c.name()] = c.Tier
+	}
+	for _, v := range []string{"ci restart", "ci cancel", "ci trigger"} {
+		if tiers[v] != TierWrite {
+			t.Errorf("%s tier = %q, want write", v, tiers[v])
+		}
+	}
+	if tiers["ci logs"] != TierRead {
+		t.Errorf("ci logs tier = %q, want read", tiers["ci logs"])
+	}
+}
+
+// cancel returns an empty 204: that is success for a write, not the transient
+// "empty body" getJSON retries on (which would cost 4 pointless 2s sleeps).
+func TestSendJSONEmptyWriteResponseIsSuccess(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Method != "POST" || r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("unexpected request %s auth=%q", r.Method, r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	c := &wpClient{base: srv.URL, token: "tok", http: srv.Client()}
+	if err := c.cancelPipeline(1, 42); err != nil {
+		t.Fatalf("cancelPipeline: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected exactly one request, got %d", calls)
+	}
+}
+
+// A 5xx on a write may come after Woodpecker already started the pipeline,
+// so it must not be replayed.
+func TestSendJSONDoesNotRetryWrites(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	c := &wpClient{base: srv.URL, token: "tok", http: srv.Client()}
+	if _, err := c.triggerManual(7, "master", nil); err == nil {
+		t.Fatal("a 502 on trigger should fail")
+	}
+	if calls != 1 {
+		t.Fatalf("expected exactly one request, got %d", calls)
+	}
+}
+
+func TestTriggerManualSendsBranchAndVariables(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body wpManualReq
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if r.URL.Path != "/api/repos/7/pipelines" || body.Branch != "master" || body.Variables["DRY_RUN"] != "1" {
+			t.Errorf("unexpected trigger %s %+v", r.URL.Path, body)
+		}
+		w.Write([]byte(`{"number":99,"status":"pending","event":"manual"}`))
+	}))
+	defer srv.Close()
+	c := &wpClient{base: srv.URL, token: "tok", http: srv.Client()}
+	p, err := c.triggerManual(7, "master", map[string]string{"DRY_RUN": "1"})
+	if err != nil || p.Number != 99 || p.Event != "manual" {
+		t.Fatalf("triggerManual = %+v, %v", p, err)
+	}
+}
+
+func TestCILogsHintKeepsRepo(t *testing.T) {
+	if got := ciLogsHint(12, "build", ""); got != "homelab ci logs 12 build" {
+		t.Errorf("cwd repo hint = %q", got)
+	}
+	if got := ciLogsHint(12, "build", "ops/homelab"); got != "homelab ci logs 12 build --repo ops/homelab" {
+		t.Errorf("--repo hint = %q", got)
+	}
+}
+
+func TestLookupRepoReadsDefaultBranch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/repos/lookup/ops/homelab" {
+			t.Errorf("unexpected lookup %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"id":7,"full_name":"ops/homelab","default_branch":"main"}`))
+	}))
+	defer srv.Close()
+	c := &wpClient{base: srv.URL, token: "tok", http: srv.Client()}
+	r, err := c.lookupRepo("ops/homelab")
+	if err != nil || r.ID != 7 || r.DefaultBranch != "main" {
+		t.Fatalf("lookupRepo = %+v, %v", r, err)
+	}
+}
+
+func TestParseRepoSlug(t *testing.T) {
+	if o, r, err := parseRepoSlug("ViktorBarzin/infra"); err != nil || o != "ViktorBarzin" || r != "infra" {
+		t.Errorf("parseRepoSlug = (%q, %q, %v)", o, r, err)
+	}
+	for _, bad := range []string{"", "infra", "a/b/c", "/infra"} {
+		if _, _, err := parseRepoSlug(bad); err == nil {
+			t.Errorf("parseRepoSlug(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCIPositionalsSkipRepoValue(t *testing.T) {
+	got := ciPositionals([]string{"--repo", "viktor/infra", "abc123"})
+	if !reflect.DeepEqual(got, []string{"abc123"}) {
+		t.Fatalf("ciPositionals = %q, want the commit only", got)
+	}
+	n, err := ciPipelineNumber([]string{"--repo", "viktor/infra", "#42"}, "usage")
+	if err != nil || n != 42 {
+		t.Fatalf("ciPipelineNumber = %d, %v", n, err)
+	}
+}
+
+func TestSortDashboardPutsFailuresFirst(t *testing.T) {
+	rows := []dashboardRow{
+		{Repo: "a/green", Pipeline: &wpPipeline{Status: "success"}},
+		{Repo: "a/never"},
+		{Repo: "b/red", Pipeline: &wpPipeline{Status: "failure"}},
+		{Repo: "a/busy", Pipeline: &wpPipeline{Status: "running"}},
+		{Repo: "a/broken", Err: "503"},
+		{Repo: "a/red", Pipeline: &wpPipeline{Status: "error"}},
+	}
+	sortDashboard(rows)
+	var got []string
+	for _, r := range rows {
+		got = append(got, r.Repo)
+	}
+	want := []string{"a/red", "b/red", "a/busy", "a/broken", "a/green", "a/never"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sortDashboard order = %q, want %q", got, want)
+	}
+	out := formatDashboard(rows, time.Now())
+	if !strings.Contains(out, "6 repos, 2 failing") {
+		t.Errorf("summary line missing: %s", out)
+	}
+}
+
+func TestAgeSince(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	cases := map[int64]string{
+		0:                   "",
+		1_000_000 - 30:      "just now",
+		1_000_000 - 600:     "10m ago",
+		1_000_000 - 7200:    "2h ago",
+		1_000_000 - 86400*3: "3d ago",
+	}
+	for in, want := range cases {
+		if got := ageSince(in, now); got != want {
+			t.Errorf("ageSince(%d) = %q, want %q", in, got, want)
+		}
+	}
+}